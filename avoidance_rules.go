@@ -0,0 +1,510 @@
+package main
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RuleScope is what kind of thing an avoidance rule is about.
+type RuleScope string
+
+const (
+	ScopeModule   RuleScope = "module"   // Target is a file or type name
+	ScopeFunction RuleScope = "function" // Target is a function or method name
+	ScopeImport   RuleScope = "import"   // Target is an import path
+	ScopePattern  RuleScope = "pattern"  // Target is a regular expression over the proposed code
+)
+
+// avoidanceThreshold is the confidence from which a matching rule refuses a
+// candidate instead of only being reported.
+const avoidanceThreshold = 0.5
+
+// forgetThreshold is the confidence below which an unpinned rule is
+// forgotten.
+const forgetThreshold = 0.05
+
+// contradictionWeight is how much one successful merge that matches a rule
+// takes off its confidence.
+const contradictionWeight = 0.5
+
+// ruleHalfLife is how long it takes the weight of a piece of evidence to
+// halve, so rules nothing reinforces fade; 0 disables decay.
+var ruleHalfLife = 30 * 24 * time.Hour
+
+// minEvidence is how many failures a rule of each scope needs before it is
+// learned. Anything but a module must recur to count as a pattern.
+var minEvidence = map[RuleScope]int{ScopeModule: 1, ScopeFunction: 2, ScopeImport: 2, ScopePattern: 2}
+
+// evidenceWeight is how much confidence one failure adds, by scope.
+var evidenceWeight = map[RuleScope]float64{ScopeModule: 0.3, ScopeFunction: 0.25, ScopeImport: 0.25, ScopePattern: 0.25}
+
+// EvidenceKind says whether a piece of evidence supports or contradicts a rule.
+type EvidenceKind string
+
+const (
+	EvidenceFailure EvidenceKind = "failure" // A failed proposal the rule matches
+	EvidenceSuccess EvidenceKind = "success" // A successful merge the rule matches
+)
+
+// RuleEvidence is one failure behind a rule, or one merge against it.
+type RuleEvidence struct {
+	ProposalID string
+	Kind       EvidenceKind
+	Condition  string // What went wrong, e.g. "failed verification" or "was rolled back"
+	SeenAt     time.Time
+}
+
+// AvoidanceRule is a generalised lesson from failed proposals.
+type AvoidanceRule struct {
+	ID             int64
+	Scope          RuleScope
+	Target         string
+	Condition      string  // Summary of what went wrong across the failures
+	Confidence     float64 // Grows with every failure, fades with age and merges against it
+	EvidenceCount  int     // Failures
+	Contradictions int     // Successful merges the rule matched
+	FirstSeen      time.Time
+	LastSeen       time.Time // Of the latest failure
+	Pinned         bool      // Kept by the operator: always blocks, never decays or is forgotten
+	ForgottenAt    *time.Time
+	ForgottenWhy   string
+	Evidence       []RuleEvidence
+}
+
+// Key identifies a rule: failures about the same target merge into it.
+func (r AvoidanceRule) Key() string {
+	return string(r.Scope) + ":" + r.Target
+}
+
+func (r AvoidanceRule) String() string {
+	against := ""
+	if r.Contradictions > 0 {
+		against = fmt.Sprintf(" against %d merge(s)", r.Contradictions)
+	}
+	pinned := ""
+	if r.Pinned {
+		pinned = ", pinned"
+	}
+	return fmt.Sprintf("Avoid %s %s: %s (confidence %.2f from %d failure(s)%s%s)",
+		r.Scope, r.Target, r.Condition, r.Confidence, r.EvidenceCount, against, pinned)
+}
+
+// Blocking reports whether the rule refuses candidates: it is pinned, or
+// confident enough.
+func (r AvoidanceRule) Blocking() bool {
+	return r.Pinned || r.Confidence >= avoidanceThreshold
+}
+
+// ruleConfidence treats each failure as independent evidence of weight w,
+// so n fresh failures give 1 - (1-w)^n, and each merge against the rule as
+// a contradiction that scales that down by 1 - contradictionWeight. Every
+// piece of evidence counts for less the older it is, unless the rule is
+// pinned.
+func (r AvoidanceRule) ruleConfidence(now time.Time) float64 {
+	support, against := 1.0, 1.0
+	for _, e := range r.Evidence {
+		weight := 1.0
+		if !r.Pinned && ruleHalfLife > 0 && now.After(e.SeenAt) {
+			weight = math.Pow(0.5, float64(now.Sub(e.SeenAt))/float64(ruleHalfLife))
+		}
+		if e.Kind == EvidenceSuccess {
+			against *= 1 - contradictionWeight*weight
+		} else {
+			support *= 1 - evidenceWeight[r.Scope]*weight
+		}
+	}
+	return (1 - support) * against
+}
+
+// mergeEvidence folds the evidence of learned into r, keeping the latest
+// evidence of each proposal, so a merge later rolled back counts as a
+// failure, and recomputes what depends on it.
+func mergeEvidence(r, learned AvoidanceRule) AvoidanceRule {
+	latest := make(map[string]int)
+	var evidence []RuleEvidence
+	for _, e := range append(append([]RuleEvidence(nil), r.Evidence...), learned.Evidence...) {
+		if e.Kind == "" {
+			e.Kind = EvidenceFailure
+		}
+		i, ok := latest[e.ProposalID]
+		switch {
+		case !ok:
+			latest[e.ProposalID] = len(evidence)
+			evidence = append(evidence, e)
+		case e.SeenAt.After(evidence[i].SeenAt):
+			evidence[i] = e
+		}
+	}
+	r.Scope, r.Target = learned.Scope, learned.Target
+	r.Evidence = evidence
+	r.refresh(time.Now())
+	return r
+}
+
+// refresh derives the counts, confidence at now, dates and condition from
+// the evidence.
+func (r *AvoidanceRule) refresh(now time.Time) {
+	sort.SliceStable(r.Evidence, func(i, j int) bool { return r.Evidence[i].SeenAt.Before(r.Evidence[j].SeenAt) })
+	r.Confidence = r.ruleConfidence(now)
+	r.EvidenceCount, r.Contradictions = 0, 0
+	counts := make(map[string]int)
+	for _, e := range r.Evidence {
+		if e.Kind == EvidenceSuccess {
+			r.Contradictions++
+			continue
+		}
+		counts[e.Condition]++
+		if r.EvidenceCount == 0 {
+			r.FirstSeen = e.SeenAt
+		}
+		r.EvidenceCount++
+		r.LastSeen = e.SeenAt
+	}
+	conditions := make([]string, 0, len(counts))
+	for c := range counts {
+		conditions = append(conditions, c)
+	}
+	sort.Slice(conditions, func(i, j int) bool {
+		if counts[conditions[i]] != counts[conditions[j]] {
+			return counts[conditions[i]] > counts[conditions[j]]
+		}
+		return conditions[i] < conditions[j]
+	})
+	for i, c := range conditions {
+		if counts[c] > 1 {
+			conditions[i] = fmt.Sprintf("%s ×%d", c, counts[c])
+		}
+	}
+	r.Condition = strings.Join(conditions, ", ")
+}
+
+// failureCondition names the kind of failure a recorded reason describes.
+func failureCondition(reason string) string {
+	switch r := strings.ToLower(reason); {
+	case strings.HasPrefix(r, "verification failed"):
+		return "failed verification"
+	case strings.HasPrefix(r, "merge failed"):
+		return "failed to merge"
+	case strings.HasPrefix(r, "rejected by operator"):
+		return "was rejected by the operator"
+	case strings.Contains(r, "rolled back"):
+		return "was rolled back"
+	case strings.Contains(r, "rejected"), strings.Contains(r, "invariant"):
+		return "violated an invariant"
+	case strings.Contains(r, "low rar"), strings.Contains(r, "risk-adjusted reward"):
+		return "had a low risk-adjusted reward"
+	default:
+		return "failed"
+	}
+}
+
+// ProposalTraits are the parts of a candidate that rules are matched on.
+type ProposalTraits struct {
+	Modules   []string // Target module and the files the card proposes
+	Functions []string // Functions and methods the card declares
+	Imports   []string // Packages the card imports
+	Calls     []string // Qualified calls such as os.Exit
+	Code      string   // Proposed files and action, for pattern rules
+}
+
+var (
+	funcDeclPattern   = regexp.MustCompile(`(?m)^[+ ]?func\s+(?:\([^)]*\)\s*)?([A-Za-z_]\w*)\s*[\[(]`)
+	addedImportLine   = regexp.MustCompile(`(?m)^\+\s*(?:import\s+)?(?:[\w.]+\s+)?"([\w.\-/]+)"\s*$`)
+	qualifiedCallExpr = regexp.MustCompile(`\b([a-z]\w*)\.([A-Z]\w*)\(`)
+)
+
+// CandidateTraits extracts the traits of a Decision Card. Go files are
+// parsed for their imports; everything else is scanned textually, so cards
+// whose action is a diff or prose are covered too.
+func CandidateTraits(card DecisionCard) ProposalTraits {
+	var t ProposalTraits
+	var code strings.Builder
+	modules := map[string]bool{}
+	if card.TargetModule != "" {
+		modules[card.TargetModule] = true
+	}
+	names := make([]string, 0, len(card.ProposedFiles))
+	for name := range card.ProposedFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	imports := map[string]bool{}
+	for _, name := range names {
+		modules[filepath.Base(name)] = true
+		content := card.ProposedFiles[name]
+		code.WriteString(content)
+		code.WriteByte('\n')
+		if f, err := parser.ParseFile(token.NewFileSet(), name, content, parser.ImportsOnly); err == nil {
+			for _, spec := range f.Imports {
+				if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+					imports[path] = true
+				}
+			}
+		}
+	}
+	code.WriteString(card.ActionCodeDiff)
+	for _, m := range addedImportLine.FindAllStringSubmatch(card.ActionCodeDiff, -1) {
+		imports[m[1]] = true
+	}
+
+	t.Code = code.String()
+	functions, calls := map[string]bool{}, map[string]bool{}
+	for _, m := range funcDeclPattern.FindAllStringSubmatch(t.Code, -1) {
+		functions[m[1]] = true
+	}
+	for _, m := range qualifiedCallExpr.FindAllStringSubmatch(t.Code, -1) {
+		calls[m[1]+"."+m[2]] = true
+	}
+	t.Modules, t.Functions, t.Imports, t.Calls = setKeys(modules), setKeys(functions), setKeys(imports), setKeys(calls)
+	return t
+}
+
+// setKeys returns the members of a set in order.
+func setKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Matches reports whether the rule applies to a candidate with traits t.
+func (r AvoidanceRule) Matches(t ProposalTraits) bool {
+	contains := func(list []string) bool {
+		for _, v := range list {
+			if strings.EqualFold(v, r.Target) {
+				return true
+			}
+		}
+		return false
+	}
+	switch r.Scope {
+	case ScopeModule:
+		return contains(t.Modules)
+	case ScopeFunction:
+		return contains(t.Functions)
+	case ScopeImport:
+		return contains(t.Imports)
+	case ScopePattern:
+		re, err := regexp.Compile(r.Target)
+		return err == nil && re.MatchString(t.Code)
+	}
+	return false
+}
+
+// MatchRules returns the rules that apply to card, most confident first.
+func MatchRules(rules []AvoidanceRule, card DecisionCard) []AvoidanceRule {
+	traits := CandidateTraits(card)
+	var matched []AvoidanceRule
+	for _, r := range rules {
+		if r.Matches(traits) {
+			matched = append(matched, r)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Confidence > matched[j].Confidence })
+	return matched
+}
+
+// BlockingRules returns the matching rules confident enough to refuse card.
+func BlockingRules(rules []AvoidanceRule, card DecisionCard) []AvoidanceRule {
+	var blocking []AvoidanceRule
+	for _, r := range MatchRules(rules, card) {
+		if r.Blocking() {
+			blocking = append(blocking, r)
+		}
+	}
+	return blocking
+}
+
+// callPattern is the pattern rule target for a qualified call.
+func callPattern(call string) string {
+	return `\b` + regexp.QuoteMeta(call) + `\(`
+}
+
+// generaliseFailures clusters failures into rules: one per target module,
+// and one per function, import or qualified call shared by enough failures.
+// baseline is the package's current sources: functions, imports and calls it
+// already has without failing say nothing about why a proposal failed, so
+// only new ones are generalised.
+func generaliseFailures(failures []FailedProposal, baseline map[string]string) []AvoidanceRule {
+	clusters := make(map[string]*AvoidanceRule)
+	var order []string
+	add := func(scope RuleScope, target string, f FailedProposal) {
+		if target == "" {
+			return
+		}
+		key := string(scope) + ":" + target
+		r, ok := clusters[key]
+		if !ok {
+			r = &AvoidanceRule{Scope: scope, Target: target}
+			clusters[key] = r
+			order = append(order, key)
+		}
+		r.Evidence = append(r.Evidence, RuleEvidence{
+			ProposalID: f.Card.ProposalID,
+			Kind:       EvidenceFailure,
+			Condition:  failureCondition(f.Reason),
+			SeenAt:     f.FailedAt,
+		})
+	}
+
+	known := knownFeatures(baseline)
+	for _, f := range failures {
+		t := CandidateTraits(f.Card)
+		add(ScopeModule, f.Card.TargetModule, f)
+		for _, fn := range t.Functions {
+			if !known["func:"+fn] && fn != "main" && fn != "init" {
+				add(ScopeFunction, fn, f)
+			}
+		}
+		for _, imp := range t.Imports {
+			if !known["import:"+imp] {
+				add(ScopeImport, imp, f)
+			}
+		}
+		for _, call := range t.Calls {
+			if !known["call:"+call] {
+				add(ScopePattern, callPattern(call), f)
+			}
+		}
+	}
+
+	var rules []AvoidanceRule
+	for _, key := range order {
+		r := mergeEvidence(AvoidanceRule{}, *clusters[key])
+		if r.EvidenceCount >= minEvidence[r.Scope] {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// knownFeatures returns the functions, imports and qualified calls the
+// baseline declares or uses.
+func knownFeatures(baseline map[string]string) map[string]bool {
+	t := CandidateTraits(DecisionCard{ProposedFiles: baseline})
+	known := make(map[string]bool)
+	for _, fn := range t.Functions {
+		known["func:"+fn] = true
+	}
+	for _, imp := range t.Imports {
+		known["import:"+imp] = true
+	}
+	for _, call := range t.Calls {
+		known["call:"+call] = true
+	}
+	return known
+}
+
+// mergesSinceFailure counts the successful merges after the rule's last
+// failure.
+func (r AvoidanceRule) mergesSinceFailure() int {
+	n := 0
+	for _, e := range r.Evidence {
+		if e.Kind == EvidenceSuccess {
+			n++
+		} else {
+			n = 0
+		}
+	}
+	return n
+}
+
+// Outweighed reports whether more merges have succeeded since the rule's
+// last failure than it has failures.
+func (r AvoidanceRule) Outweighed() bool {
+	return r.mergesSinceFailure() >= r.EvidenceCount
+}
+
+// RuleConflict is a rule whose evidence disagrees: failures say to avoid
+// its target, later merges of it succeeded. With is the other rule of a
+// contradicting pair, or 0.
+type RuleConflict struct {
+	Rule   AvoidanceRule
+	With   int64
+	Reason string
+}
+
+// RuleConflicts finds the rules contradicted by successful merges since
+// their last failure but not yet outweighed by them, so the evidence is
+// split. Unpinned rules settle this themselves as further merges or
+// failures arrive; a pinned one waits for the operator to unpin or delete it.
+//
+// It also finds pairs of rules that contradict each other: a blocking rule
+// that matches a merge recorded against another rule, without having
+// counted that merge itself, refuses what the other rule's evidence shows
+// can succeed. merged looks up the Decision Card of a merged proposal; nil
+// skips these pairs.
+func RuleConflicts(rules []AvoidanceRule, merged func(proposalID string) (DecisionCard, bool)) []RuleConflict {
+	var conflicts []RuleConflict
+	for _, r := range rules {
+		merges := r.mergesSinceFailure()
+		if merges == 0 || (r.Outweighed() && !r.Pinned) {
+			continue
+		}
+		reason := fmt.Sprintf("%d failure(s), then %d successful merge(s)", r.EvidenceCount, merges)
+		if r.Pinned {
+			reason += "; pinned, so it still blocks"
+		} else if r.Blocking() {
+			reason += "; still confident enough to block"
+		}
+		conflicts = append(conflicts, RuleConflict{Rule: r, Reason: reason})
+	}
+	if merged == nil {
+		return conflicts
+	}
+
+	traits := make(map[string]*ProposalTraits)
+	reported := make(map[[2]int64]bool)
+	for _, other := range rules {
+		for _, e := range other.Evidence {
+			if e.Kind != EvidenceSuccess {
+				continue
+			}
+			t, ok := traits[e.ProposalID]
+			if !ok {
+				if card, found := merged(e.ProposalID); found {
+					ct := CandidateTraits(card)
+					t = &ct
+				}
+				traits[e.ProposalID] = t
+			}
+			if t == nil {
+				continue
+			}
+			for _, r := range rules {
+				if r.ID == other.ID || !r.Blocking() || reported[[2]int64{r.ID, other.ID}] || r.hasEvidence(e.ProposalID) || !r.Matches(*t) {
+					continue
+				}
+				reported[[2]int64{r.ID, other.ID}] = true
+				reason := fmt.Sprintf("blocks what the merge of %s, recorded against rule #%d, shows can succeed", e.ProposalID, other.ID)
+				if r.Pinned {
+					reason += "; pinned, so it still blocks"
+				}
+				conflicts = append(conflicts, RuleConflict{Rule: r, With: other.ID, Reason: reason})
+			}
+		}
+	}
+	return conflicts
+}
+
+// hasEvidence reports whether the rule has counted the proposal, for or
+// against it.
+func (r AvoidanceRule) hasEvidence(proposalID string) bool {
+	for _, e := range r.Evidence {
+		if e.ProposalID == proposalID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRuleConflicts(t *testing.T) {
+	at := time.Now().Add(-time.Hour)
+	failure := func(id string) RuleEvidence {
+		return RuleEvidence{ProposalID: id, Kind: EvidenceFailure, Condition: "failed verification", SeenAt: at}
+	}
+	success := func(id string) RuleEvidence {
+		return RuleEvidence{ProposalID: id, Kind: EvidenceSuccess, Condition: "merged successfully", SeenAt: at.Add(time.Minute)}
+	}
+	rule := func(id int64, scope RuleScope, target string, pinned bool, evidence ...RuleEvidence) AvoidanceRule {
+		r := AvoidanceRule{ID: id, Scope: scope, Target: target, Pinned: pinned, Evidence: evidence}
+		r.refresh(time.Now())
+		return r
+	}
+	merged := map[string]DecisionCard{
+		"PROP-merged": {ProposalID: "PROP-merged", ProposedFiles: map[string]string{
+			"cache.go": "package main\n\nimport \"os\"\n\nfunc warmCache() { os.Getenv(\"X\") }\n",
+		}},
+	}
+	lookup := func(id string) (DecisionCard, bool) {
+		card, ok := merged[id]
+		return card, ok
+	}
+
+	tests := []struct {
+		name  string
+		rules []AvoidanceRule
+		want  []string // "#rule with #other: reason fragment"
+	}{
+		{
+			name: "split evidence of one rule",
+			rules: []AvoidanceRule{
+				rule(1, ScopeModule, "cache.go", true, failure("PROP-a"), success("PROP-merged")),
+			},
+			want: []string{"#1 with #0: 1 failure(s), then 1 successful merge(s); pinned"},
+		},
+		{
+			name: "pinned rule against another rule's merge",
+			rules: []AvoidanceRule{
+				rule(1, ScopeFunction, "warmCache", false, failure("PROP-a"), failure("PROP-b"), success("PROP-merged"), success("PROP-other")),
+				rule(2, ScopeImport, "os", true, failure("PROP-c"), failure("PROP-d")),
+			},
+			want: []string{"#2 with #1: blocks what the merge of PROP-merged, recorded against rule #1, shows can succeed; pinned"},
+		},
+		{
+			name: "rule that counted the merge itself",
+			rules: []AvoidanceRule{
+				rule(1, ScopeFunction, "warmCache", false, failure("PROP-a"), failure("PROP-b"), success("PROP-merged"), success("PROP-other")),
+				rule(2, ScopeImport, "os", false, failure("PROP-c"), failure("PROP-d"), success("PROP-merged"), success("PROP-e"), success("PROP-f")),
+			},
+		},
+		{
+			name: "rule that does not match the merge",
+			rules: []AvoidanceRule{
+				rule(1, ScopeFunction, "warmCache", false, failure("PROP-a"), failure("PROP-b"), success("PROP-merged"), success("PROP-other")),
+				rule(2, ScopeImport, "net/http", true, failure("PROP-c"), failure("PROP-d")),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range RuleConflicts(tt.rules, lookup) {
+				got = append(got, fmt.Sprintf("#%d with #%d: %s", c.Rule.ID, c.With, c.Reason))
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("RuleConflicts() = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if !strings.HasPrefix(got[i], tt.want[i]) {
+					t.Errorf("conflict %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AxiomSample is the Prime Axiom as measured at one moment.
+type AxiomSample struct {
+	At         time.Time `json:"at"`
+	Epsilon    float64   `json:"epsilon"`
+	I          float64   `json:"i"`
+	Cause      string    `json:"cause"`                // startup, metrics, fact, merge or calibration
+	ProposalID string    `json:"proposalID,omitempty"` // Set when a proposal caused the sample
+}
+
+// AxiomIntegration records what one merged proposal did to the axioms,
+// next to what its Decision Card predicted.
+type AxiomIntegration struct {
+	At                   time.Time     `json:"at"`
+	ProposalID           string        `json:"proposalID"`
+	EpsilonBefore        float64       `json:"epsilonBefore"`
+	EpsilonAfter         float64       `json:"epsilonAfter"`
+	IBefore              float64       `json:"iBefore"`
+	IAfter               float64       `json:"iAfter"`
+	PredictedEpsilonGain float64       `json:"predictedEpsilonGain"`
+	PredictedIGain       float64       `json:"predictedIGain"`
+	ImplementTime        time.Duration `json:"implementTime"`
+	RepairIterations     int           `json:"repairIterations"`
+}
+
+// ActualEpsilonGain is the change in ε the merge caused.
+func (ai AxiomIntegration) ActualEpsilonGain() float64 { return ai.EpsilonAfter - ai.EpsilonBefore }
+
+// ActualIGain is the change in 𝓘 the merge caused.
+func (ai AxiomIntegration) ActualIGain() float64 { return ai.IAfter - ai.IBefore }
+
+// AxiomTrend summarises how one metric moved over a run of samples.
+type AxiomTrend struct {
+	Metric  string  `json:"metric"`
+	Samples int     `json:"samples"`
+	First   float64 `json:"first"`
+	Last    float64 `json:"last"`
+	Delta   float64 `json:"delta"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+}
+
+// AxiomHistory persists axiom samples and merge integrations in SQLite, so
+// the effect of merges can be followed across restarts.
+type AxiomHistory struct {
+	db *sql.DB
+}
+
+// axiomHistoryMigrations is the versioned schema of the axiom history.
+// Version 1 adopts the tables created before the schema was versioned.
+var axiomHistoryMigrations = []schemaMigration{
+	{Version: 1, Name: "axiom samples and merges", Statements: []string{
+		`CREATE TABLE IF NOT EXISTS axiom_samples (
+			id          INTEGER PRIMARY KEY AUTOINCREMENT,
+			at          TIMESTAMP NOT NULL,
+			epsilon     REAL NOT NULL,
+			i           REAL NOT NULL,
+			cause       TEXT NOT NULL,
+			proposal_id TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_axiom_samples_at ON axiom_samples(at)`,
+		`CREATE TABLE IF NOT EXISTS axiom_integrations (
+			id                     INTEGER PRIMARY KEY AUTOINCREMENT,
+			at                     TIMESTAMP NOT NULL,
+			proposal_id            TEXT NOT NULL,
+			epsilon_before         REAL NOT NULL,
+			epsilon_after          REAL NOT NULL,
+			i_before               REAL NOT NULL,
+			i_after                REAL NOT NULL,
+			predicted_epsilon_gain REAL NOT NULL,
+			predicted_i_gain       REAL NOT NULL,
+			implement_seconds      REAL NOT NULL,
+			repair_iterations      INTEGER NOT NULL
+		)`,
+	}},
+}
+
+// NewAxiomHistory migrates the history schema to the latest version.
+func NewAxiomHistory(db *sql.DB) (*AxiomHistory, error) {
+	if err := applyMigrations(db, "axiom_history", axiomHistoryMigrations); err != nil {
+		return nil, fmt.Errorf("failed to migrate axiom history: %v", err)
+	}
+	return &AxiomHistory{db: db}, nil
+}
+
+// RecordSample stores one measurement of the axioms.
+func (h *AxiomHistory) RecordSample(s AxiomSample) error {
+	_, err := h.db.Exec(`INSERT INTO axiom_samples (at, epsilon, i, cause, proposal_id) VALUES (?, ?, ?, ?, ?)`,
+		s.At.UTC(), s.Epsilon, s.I, s.Cause, s.ProposalID)
+	if err != nil {
+		return fmt.Errorf("failed to record axiom sample: %v", err)
+	}
+	return nil
+}
+
+// RecordIntegration stores the outcome of a merge.
+func (h *AxiomHistory) RecordIntegration(ai AxiomIntegration) error {
+	_, err := h.db.Exec(`INSERT INTO axiom_integrations (at, proposal_id, epsilon_before, epsilon_after,
+			i_before, i_after, predicted_epsilon_gain, predicted_i_gain, implement_seconds, repair_iterations)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		ai.At.UTC(), ai.ProposalID, ai.EpsilonBefore, ai.EpsilonAfter, ai.IBefore, ai.IAfter,
+		ai.PredictedEpsilonGain, ai.PredictedIGain, ai.ImplementTime.Seconds(), ai.RepairIterations)
+	if err != nil {
+		return fmt.Errorf("failed to record integration of %s: %v", ai.ProposalID, err)
+	}
+	return nil
+}
+
+// Samples returns the samples taken at or after since, oldest first. A
+// positive limit keeps only the most recent ones.
+func (h *AxiomHistory) Samples(since time.Time, limit int) ([]AxiomSample, error) {
+	query := `SELECT at, epsilon, i, cause, proposal_id FROM axiom_samples WHERE at >= ? ORDER BY id DESC`
+	args := []any{since.UTC()}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []AxiomSample
+	for rows.Next() {
+		var s AxiomSample
+		if err := rows.Scan(&s.At, &s.Epsilon, &s.I, &s.Cause, &s.ProposalID); err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+	for i, j := 0, len(samples)-1; i < j; i, j = i+1, j-1 {
+		samples[i], samples[j] = samples[j], samples[i]
+	}
+	return samples, rows.Err()
+}
+
+// Latest returns the most recent sample, if there is one.
+func (h *AxiomHistory) Latest() (AxiomSample, bool, error) {
+	samples, err := h.Samples(time.Time{}, 1)
+	if err != nil || len(samples) == 0 {
+		return AxiomSample{}, false, err
+	}
+	return samples[0], true, nil
+}
+
+// Integrations returns every recorded merge, oldest first.
+func (h *AxiomHistory) Integrations() ([]AxiomIntegration, error) {
+	rows, err := h.db.Query(`SELECT at, proposal_id, epsilon_before, epsilon_after, i_before, i_after,
+		predicted_epsilon_gain, predicted_i_gain, implement_seconds, repair_iterations
+		FROM axiom_integrations ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []AxiomIntegration
+	for rows.Next() {
+		var ai AxiomIntegration
+		var seconds float64
+		if err := rows.Scan(&ai.At, &ai.ProposalID, &ai.EpsilonBefore, &ai.EpsilonAfter, &ai.IBefore, &ai.IAfter,
+			&ai.PredictedEpsilonGain, &ai.PredictedIGain, &seconds, &ai.RepairIterations); err != nil {
+			return nil, err
+		}
+		ai.ImplementTime = time.Duration(seconds * float64(time.Second))
+		out = append(out, ai)
+	}
+	return out, rows.Err()
+}
+
+// Trends summarises ε and 𝓘 over samples.
+func Trends(samples []AxiomSample) []AxiomTrend {
+	if len(samples) == 0 {
+		return nil
+	}
+	trend := func(metric string, value func(AxiomSample) float64) AxiomTrend {
+		t := AxiomTrend{Metric: metric, Samples: len(samples), First: value(samples[0]), Last: value(samples[len(samples)-1])}
+		t.Min, t.Max = t.First, t.First
+		for _, s := range samples {
+			t.Min, t.Max = min(t.Min, value(s)), max(t.Max, value(s))
+		}
+		t.Delta = t.Last - t.First
+		return t
+	}
+	return []AxiomTrend{
+		trend("ε", func(s AxiomSample) float64 { return s.Epsilon }),
+		trend("𝓘", func(s AxiomSample) float64 { return s.I }),
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// observeSimulations completes the outcomes of merges whose calibration
+// window has passed.
+func observeSimulations() {
+	n, err := simulationChamber.ObserveDue(time.Now())
+	if err != nil {
+		fmt.Printf("SIE-∞ Warning: merge outcomes not observed: %v\n", err)
+	}
+	if n > 0 {
+		fmt.Printf("SIE-∞: Observed the outcome of %d merge(s) for calibration.\n", n)
+	}
+}
+
+// calibrationHandler prints the calibrated model and every merge's predicted
+// and observed effect on the axioms and the metabolism.
+func calibrationHandler() {
+	outcomes, err := simulationChamber.Outcomes()
+	if err != nil {
+		fmt.Printf("SIE-∞ Error: Could not read merge outcomes: %v\n", err)
+		return
+	}
+	var observed []SimulationOutcome
+	overlapping := 0
+	for _, o := range outcomes {
+		switch {
+		case o.After == nil:
+		case len(o.Overlapping) > 0:
+			overlapping++
+		default:
+			observed = append(observed, o)
+		}
+	}
+
+	fmt.Printf("--- Calibration (%d of %d merges observed, window %v) ---\n", len(observed), len(outcomes), simulationChamber.Window)
+	if overlapping > 0 {
+		fmt.Printf("%d observed merge(s) left out: another merge landed inside their window.\n", overlapping)
+	}
+	epsilon := predictGain("ε", nil, observed)
+	i := predictGain("𝓘", nil, observed)
+	fmt.Printf("%-16s %9s %9s %9s %9s\n", "WEIGHT", "ε", "ε PRIOR", "𝓘", "𝓘 PRIOR")
+	for k, c := range epsilon.Contributions {
+		fmt.Printf("%-16s %+9.4f %+9.4f %+9.4f %+9.4f\n", c.Feature, c.Weight, heuristicWeights["ε"][k],
+			i.Contributions[k].Weight, heuristicWeights["𝓘"][k])
+	}
+	if len(observed) > 0 {
+		fmt.Printf("Mean error: ε %.4f calibrated vs %.4f heuristic, 𝓘 %.4f calibrated vs %.4f heuristic\n",
+			epsilon.CalibrationError, epsilon.HeuristicError, i.CalibrationError, i.HeuristicError)
+	}
+	if len(outcomes) == 0 {
+		fmt.Println("No merges recorded yet.")
+		return
+	}
+
+	fmt.Printf("%-36s %9s %9s %9s %9s %9s %9s\n", "PROPOSAL", "PRED Δε", "ACT Δε", "PRED Δ𝓘", "ACT Δ𝓘", "Δ MEM %", "Δ LAT ms")
+	for _, o := range outcomes {
+		if o.After == nil {
+			fmt.Printf("%-36s %+9.4f %9s %+9.4f %9s  (observed after %s)\n", o.ProposalID, o.PredictedEpsilonGain, "-",
+				o.PredictedIGain, "-", o.DueAt.Local().Format("2006-01-02 15:04:05"))
+			continue
+		}
+		fmt.Printf("%-36s %+9.4f %+9.4f %+9.4f %+9.4f %+9.2f %+9d", o.ProposalID,
+			o.PredictedEpsilonGain, o.ActualGain("ε"), o.PredictedIGain, o.ActualGain("𝓘"),
+			o.After.Metabolism.MemorySaturation-o.Before.Metabolism.MemorySaturation,
+			(o.After.Metabolism.Latency - o.Before.Metabolism.Latency).Milliseconds())
+		if len(o.Overlapping) > 0 {
+			fmt.Printf("  (overlaps %s; not fitted)", strings.Join(o.Overlapping, ", "))
+		}
+		fmt.Println()
+	}
+}
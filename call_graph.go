@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// initNode stands for package initialisation: init functions and the
+// initialisers of package-level variables.
+const initNode = "<init>"
+
+// callGraph is a static call graph of one package with two kinds of edge.
+// edges over-approximates the real graph for finding what can reach a merge
+// sink: x.Foo links to every method named Foo, and merely mentioning a
+// function (for example passing it as a value) counts as an edge. exact
+// holds only the calls the type checker resolved to one function, and is
+// the only evidence that a function passes through a gate; over-approximating
+// there would let any method with a gate's name stand in for it.
+type callGraph struct {
+	edges map[string][]string
+	exact map[string]map[string]bool
+}
+
+// failingImporter refuses every import, so the package can be type-checked
+// without loading its dependencies. Expressions of imported types stay
+// untyped, which only leaves calls through them unresolved.
+type failingImporter struct{}
+
+func (failingImporter) Import(path string) (*types.Package, error) {
+	return nil, fmt.Errorf("%s is not loaded for call graph analysis", path)
+}
+
+// typeCheck resolves the identifiers and selectors of a parsed package as
+// far as possible; errors, such as uses of unloaded imports, are ignored.
+func typeCheck(pp *parsedPackage) *types.Info {
+	info := &types.Info{
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	conf := types.Config{Importer: failingImporter{}, Error: func(error) {}, FakeImportC: true}
+	conf.Check("main", pp.fset, pp.files, info)
+	return info
+}
+
+// funcObjectKey is the funcKey of a function or method the type checker
+// resolved, or "" for anything that is not a function of this package.
+func funcObjectKey(obj types.Object) string {
+	fn, ok := obj.(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Name() != "main" {
+		return ""
+	}
+	sig, ok := fn.Type().(*types.Signature)
+	if !ok || sig.Recv() == nil {
+		if fn.Parent() != fn.Pkg().Scope() {
+			return ""
+		}
+		return fn.Name()
+	}
+	recv := sig.Recv().Type()
+	if ptr, ok := recv.(*types.Pointer); ok {
+		recv = ptr.Elem()
+	}
+	named, ok := recv.(*types.Named)
+	if !ok {
+		return ""
+	}
+	return named.Obj().Name() + "." + fn.Name()
+}
+
+// buildCallGraph constructs the call graph of a parsed package.
+func buildCallGraph(pp *parsedPackage) *callGraph {
+	info := typeCheck(pp)
+	byMethod := make(map[string][]string)
+	for key := range pp.funcs {
+		if recv, name, ok := strings.Cut(key, "."); ok && recv != "" {
+			byMethod[name] = append(byMethod[name], key)
+		}
+	}
+
+	edgeSets := make(map[string]map[string]bool)
+	g := &callGraph{edges: make(map[string][]string), exact: make(map[string]map[string]bool)}
+	addEdge := func(from, to string) {
+		if edgeSets[from] == nil {
+			edgeSets[from] = make(map[string]bool)
+		}
+		edgeSets[from][to] = true
+	}
+	addExact := func(from string, obj types.Object) {
+		to := funcObjectKey(obj)
+		if _, ok := pp.funcs[to]; !ok {
+			return
+		}
+		addEdge(from, to)
+		if g.exact[from] == nil {
+			g.exact[from] = make(map[string]bool)
+		}
+		g.exact[from][to] = true
+	}
+
+	for _, f := range pp.files {
+		imports := make(map[string]bool)
+		for _, spec := range f.Imports {
+			if spec.Name != nil {
+				imports[spec.Name.Name] = true
+				continue
+			}
+			p, _ := strconv.Unquote(spec.Path.Value)
+			imports[path.Base(p)] = true
+		}
+
+		var visit func(from string, node ast.Node)
+		visit = func(from string, node ast.Node) {
+			ast.Inspect(node, func(n ast.Node) bool {
+				switch x := n.(type) {
+				case *ast.SelectorExpr:
+					if id, ok := x.X.(*ast.Ident); ok && imports[id.Name] {
+						return false
+					}
+					if sel, ok := info.Selections[x]; ok {
+						addExact(from, sel.Obj())
+					}
+					for _, target := range byMethod[x.Sel.Name] {
+						addEdge(from, target)
+					}
+					visit(from, x.X)
+					return false
+				case *ast.Ident:
+					addExact(from, info.Uses[x])
+					if _, ok := pp.funcs[x.Name]; ok {
+						addEdge(from, x.Name)
+					}
+				}
+				return true
+			})
+		}
+
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Body == nil {
+					continue
+				}
+				from := funcKey(d)
+				if d.Name.Name == "init" {
+					from = initNode
+				}
+				visit(from, d.Body)
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					if vs, ok := spec.(*ast.ValueSpec); ok {
+						for _, v := range vs.Values {
+							visit(initNode, v)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	for from, set := range edgeSets {
+		for to := range set {
+			g.edges[from] = append(g.edges[from], to)
+		}
+		sort.Strings(g.edges[from])
+	}
+	return g
+}
+
+// matchesFuncName reports whether a funcKey is covered by a name that is
+// either a plain function or method name or a "Type.Method" key.
+func matchesFuncName(key, name string) bool {
+	return key == name || strings.HasSuffix(key, "."+name)
+}
+
+// bypass is the part of a call graph that lets execution reach a sink
+// without passing a gate: the edges that lie on some chain from a root to
+// the sink on which no function is, or calls, the gate.
+type bypass struct {
+	edges   map[[2]string]bool
+	parent  map[string]string // Towards a root
+	towards map[string]string // Towards the sink
+}
+
+// bypassOf finds every gate-free chain from roots to sink. A function
+// passes the gate only if it is the gate, a funcKey, or calls it through an
+// exact edge.
+func (g *callGraph) bypassOf(roots []string, sink, gate string) bypass {
+	covers := func(fn string) bool {
+		return fn == gate || g.exact[fn][gate]
+	}
+	b := bypass{edges: make(map[[2]string]bool), parent: make(map[string]string), towards: make(map[string]string)}
+
+	reached := make(map[string]bool)
+	var queue []string
+	for _, r := range roots {
+		if !reached[r] && !covers(r) {
+			reached[r] = true
+			queue = append(queue, r)
+		}
+	}
+	reverse := make(map[string][]string)
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		for _, next := range g.edges[fn] {
+			if covers(next) {
+				continue
+			}
+			reverse[next] = append(reverse[next], fn)
+			if !reached[next] {
+				reached[next] = true
+				b.parent[next] = fn
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	// Walk back from the sinks over the edges just found.
+	leads := make(map[string]bool)
+	queue = queue[:0]
+	var reachedKeys []string
+	for fn := range reached {
+		reachedKeys = append(reachedKeys, fn)
+	}
+	sort.Strings(reachedKeys)
+	for _, fn := range reachedKeys {
+		if matchesFuncName(fn, sink) {
+			leads[fn] = true
+			queue = append(queue, fn)
+		}
+	}
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		for _, prev := range reverse[fn] {
+			b.edges[[2]string{prev, fn}] = true
+			if !leads[prev] {
+				leads[prev] = true
+				b.towards[prev] = fn
+				queue = append(queue, prev)
+			}
+		}
+	}
+	return b
+}
+
+// chainThrough returns a chain from a root to the sink that uses edge.
+func (b bypass) chainThrough(edge [2]string) []string {
+	var chain []string
+	for n := edge[0]; n != ""; n = b.parent[n] {
+		chain = append([]string{n}, chain...)
+	}
+	for n := edge[1]; n != ""; n = b.towards[n] {
+		chain = append(chain, n)
+	}
+	return chain
+}
+
+// checkOversightReachability rejects proposals that open a new call chain
+// to a merge sink around a required gate. Chains that existed before the
+// proposal are tolerated, but do not switch the check off: any chain using
+// an edge that was not on a bypass before is new.
+func (ic *InvariantChecker) checkOversightReachability(before, after *parsedPackage) []string {
+	roots := []string{"main", initNode}
+	gBefore, gAfter := buildCallGraph(before), buildCallGraph(after)
+
+	var violations []string
+	for _, sink := range ic.MergeSinks {
+		for _, gate := range ic.RequiredGates {
+			old, current := gBefore.bypassOf(roots, sink, gate), gAfter.bypassOf(roots, sink, gate)
+			var added [][2]string
+			for edge := range current.edges {
+				if !old.edges[edge] {
+					added = append(added, edge)
+				}
+			}
+			if len(added) == 0 {
+				continue
+			}
+			// Report the shortest new chain, deterministically.
+			var chain []string
+			for _, edge := range added {
+				c := current.chainThrough(edge)
+				if chain == nil || len(c) < len(chain) || (len(c) == len(chain) && strings.Join(c, " ") < strings.Join(chain, " ")) {
+					chain = c
+				}
+			}
+			violations = append(violations, fmt.Sprintf(
+				"human oversight invariant: call chain %s reaches %s without passing through %s",
+				strings.Join(chain, " → "), sink, gate))
+		}
+	}
+	return violations
+}
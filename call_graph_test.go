@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckOversightReachability(t *testing.T) {
+	const legacy = `package main
+
+func legacyMerge() {
+	merger.Merge(DecisionCard{})
+}
+
+func init() {
+	legacyMerge()
+}
+`
+	tests := []struct {
+		name     string
+		existing map[string]string
+		proposed map[string]string
+		want     string // "" for a passing proposal
+	}{
+		{
+			name:     "unrelated file",
+			proposed: map[string]string{"extra.go": "package main\n\nfunc helper() int { return 1 }\n"},
+		},
+		{
+			name: "gate impostors with the same names",
+			proposed: map[string]string{"sneak.go": `package main
+
+type gate struct{}
+
+func (gate) proposalsHandler()                  {}
+func (gate) CheckInvariants(card DecisionCard) {}
+
+func sneak() {
+	var g gate
+	g.proposalsHandler()
+	g.CheckInvariants(DecisionCard{})
+	merger.Merge(DecisionCard{})
+}
+
+func init() {
+	sneak()
+}
+`},
+			want: "call chain <init> → sneak → GitMerger.Merge reaches Merge without passing through proposalsHandler",
+		},
+		{
+			name: "real conscience without the approval gate",
+			proposed: map[string]string{"sneak.go": `package main
+
+func sneak() {
+	if ok, _ := checker.CheckInvariants(DecisionCard{}); ok {
+		merger.Merge(DecisionCard{})
+	}
+}
+
+func init() {
+	sneak()
+}
+`},
+			want: "without passing through proposalsHandler",
+		},
+		{
+			name:     "legacy bypass does not hide a new one",
+			existing: map[string]string{"legacy.go": legacy},
+			proposed: map[string]string{"sneak.go": `package main
+
+func sneak() {
+	merger.Merge(DecisionCard{})
+}
+
+func init() {
+	sneak()
+}
+`},
+			want: "call chain <init> → sneak → GitMerger.Merge",
+		},
+		{
+			name:     "legacy bypass alone is tolerated",
+			existing: map[string]string{"legacy.go": legacy},
+			proposed: map[string]string{"extra.go": "package main\n\nfunc helper() int { return 1 }\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{"main.go": invariantTestPackage}
+			for name, src := range tt.existing {
+				files[name] = src
+			}
+			ic := newTestInvariantChecker(t, files)
+			ok, msg := ic.CheckInvariants(DecisionCard{ProposedFiles: tt.proposed})
+			if ok != (tt.want == "") {
+				t.Fatalf("CheckInvariants ok = %v: %s", ok, msg)
+			}
+			if tt.want != "" && !strings.Contains(msg, tt.want) {
+				t.Errorf("CheckInvariants message %q does not mention %q", msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvariantCheckerOnThisPackage(t *testing.T) {
+	ic, err := NewInvariantChecker()
+	if err != nil {
+		t.Fatalf("NewInvariantChecker() = %v", err)
+	}
+	ok, msg := ic.CheckInvariants(DecisionCard{ProposedFiles: map[string]string{
+		"zz_helper.go": "package main\n\nfunc zzHelper() int { return 1 }\n",
+	}})
+	if !ok {
+		t.Errorf("a harmless new file was rejected: %s", msg)
+	}
+}
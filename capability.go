@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Capability is a pluggable unit of functionality. Generated capability
+// files register one from an init function with RegisterCapability, so they
+// plug into the console and HTTP server without any edit to server.go:
+//
+//	func init() { RegisterCapability(&reverseCapability{}) }
+//
+// A capability additionally implements CommandCapability to add a slash
+// command, HTTPCapability to add an HTTP route, or both.
+type Capability interface {
+	Name() string
+	Description() string
+	// Init runs once at startup, or when an operator re-enables the capability.
+	Init(ctx context.Context) error
+	// Shutdown releases resources when the capability is disabled, unloaded or
+	// the process exits.
+	Shutdown(ctx context.Context) error
+	// Health reports whether the capability is working; nil means healthy.
+	Health(ctx context.Context) error
+}
+
+// CommandCapability adds a slash command to the operator console.
+type CommandCapability interface {
+	Capability
+	Command() string // For example "/reverse"
+	Usage() string   // For example "/reverse <text>"
+	RunCommand(ctx context.Context, args string) (string, error)
+}
+
+// HTTPCapability adds a route to the HTTP server.
+type HTTPCapability interface {
+	Capability
+	Route() string // A net/http pattern such as "POST /reverse"
+	http.Handler
+}
+
+// CapabilityState is where a registered capability is in its lifecycle.
+type CapabilityState string
+
+const (
+	CapabilityRegistered CapabilityState = "registered"
+	CapabilityActive     CapabilityState = "active"
+	CapabilityFailed     CapabilityState = "failed"
+	CapabilityDisabled   CapabilityState = "disabled"
+	CapabilityUnloaded   CapabilityState = "unloaded"
+)
+
+// CapabilityInfo is a snapshot of a registered capability for listings.
+type CapabilityInfo struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Command     string          `json:"command,omitempty"`
+	Route       string          `json:"route,omitempty"`
+	State       CapabilityState `json:"state"`
+	Error       string          `json:"error,omitempty"`
+}
+
+type capabilityEntry struct {
+	capability Capability
+	state      CapabilityState
+	err        error
+}
+
+// CapabilityRegistry tracks every registered capability and routes console
+// commands and HTTP requests to the active ones.
+type CapabilityRegistry struct {
+	mutex   sync.RWMutex
+	entries map[string]*capabilityEntry
+	order   []string
+}
+
+// NewCapabilityRegistry creates an empty registry.
+func NewCapabilityRegistry() *CapabilityRegistry {
+	return &CapabilityRegistry{entries: make(map[string]*capabilityEntry)}
+}
+
+// capabilities is the process-wide registry that init functions register into.
+var capabilities = NewCapabilityRegistry()
+
+// RegisterCapability adds c to the process-wide registry. Like sql.Register
+// it is meant to be called from init and panics on a duplicate or invalid
+// registration, which surfaces as a test failure during verification.
+func RegisterCapability(c Capability) {
+	if err := capabilities.Register(c); err != nil {
+		panic(err)
+	}
+}
+
+// Register adds c to the registry without starting it.
+func (r *CapabilityRegistry) Register(c Capability) error {
+	if c == nil || strings.TrimSpace(c.Name()) == "" {
+		return fmt.Errorf("capability must have a name")
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	name := c.Name()
+	if _, ok := r.entries[name]; ok {
+		return fmt.Errorf("capability %q is already registered", name)
+	}
+	if cc, ok := c.(CommandCapability); ok {
+		cmd := cc.Command()
+		if !strings.HasPrefix(cmd, "/") || strings.ContainsAny(cmd, " \t") {
+			return fmt.Errorf("capability %q has invalid command %q", name, cmd)
+		}
+		for _, builtin := range consoleCommands {
+			if builtin.Name == cmd {
+				return fmt.Errorf("capability %q command %s shadows a built-in command", name, cmd)
+			}
+		}
+		for _, other := range r.entries {
+			if oc, ok := other.capability.(CommandCapability); ok && oc.Command() == cmd {
+				return fmt.Errorf("capability %q command %s is already used by %q", name, cmd, oc.Name())
+			}
+		}
+	}
+	r.entries[name] = &capabilityEntry{capability: c, state: CapabilityRegistered}
+	r.order = append(r.order, name)
+	return nil
+}
+
+// Start initialises every registered capability. A failing Init marks that
+// capability failed without affecting the others.
+func (r *CapabilityRegistry) Start(ctx context.Context) {
+	for _, name := range r.names() {
+		r.mutex.Lock()
+		e := r.entries[name]
+		if e.state != CapabilityRegistered {
+			r.mutex.Unlock()
+			continue
+		}
+		r.mutex.Unlock()
+		r.initEntry(ctx, name, e)
+	}
+}
+
+// Shutdown stops every active capability, in reverse registration order.
+func (r *CapabilityRegistry) Shutdown(ctx context.Context) {
+	names := r.names()
+	for i := len(names) - 1; i >= 0; i-- {
+		r.mutex.Lock()
+		e := r.entries[names[i]]
+		active := e.state == CapabilityActive
+		if active {
+			e.state = CapabilityRegistered
+		}
+		r.mutex.Unlock()
+		if active {
+			if err := safeCapabilityCall(func() error { return e.capability.Shutdown(ctx) }); err != nil {
+				fmt.Printf("SIE-∞ Warning: capability %s failed to shut down: %v\n", names[i], err)
+			}
+		}
+	}
+}
+
+// Enable re-initialises a disabled or failed capability.
+func (r *CapabilityRegistry) Enable(ctx context.Context, name string) error {
+	r.mutex.Lock()
+	e, ok := r.entries[name]
+	if !ok {
+		r.mutex.Unlock()
+		return fmt.Errorf("no capability named %q", name)
+	}
+	if e.state != CapabilityDisabled && e.state != CapabilityFailed {
+		r.mutex.Unlock()
+		return fmt.Errorf("capability %q is %s", name, e.state)
+	}
+	r.mutex.Unlock()
+	return r.initEntry(ctx, name, e)
+}
+
+// Disable shuts a capability down and stops routing to it; Enable brings it back.
+func (r *CapabilityRegistry) Disable(ctx context.Context, name string) error {
+	return r.stop(ctx, name, CapabilityDisabled)
+}
+
+// Unload shuts a capability down for the rest of the process's life. Its code
+// stays in the tree; /rollback of the proposal that added it removes that.
+func (r *CapabilityRegistry) Unload(ctx context.Context, name string) error {
+	return r.stop(ctx, name, CapabilityUnloaded)
+}
+
+func (r *CapabilityRegistry) stop(ctx context.Context, name string, to CapabilityState) error {
+	r.mutex.Lock()
+	e, ok := r.entries[name]
+	if !ok {
+		r.mutex.Unlock()
+		return fmt.Errorf("no capability named %q", name)
+	}
+	if e.state == CapabilityUnloaded || e.state == to {
+		r.mutex.Unlock()
+		return fmt.Errorf("capability %q is already %s", name, e.state)
+	}
+	wasActive := e.state == CapabilityActive
+	e.state, e.err = to, nil
+	r.mutex.Unlock()
+
+	if wasActive {
+		if err := safeCapabilityCall(func() error { return e.capability.Shutdown(ctx) }); err != nil {
+			return fmt.Errorf("capability %q is %s but its shutdown failed: %v", name, to, err)
+		}
+	}
+	return nil
+}
+
+func (r *CapabilityRegistry) initEntry(ctx context.Context, name string, e *capabilityEntry) error {
+	err := safeCapabilityCall(func() error { return e.capability.Init(ctx) })
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err != nil {
+		e.state, e.err = CapabilityFailed, err
+		return fmt.Errorf("capability %q failed to initialise: %v", name, err)
+	}
+	e.state, e.err = CapabilityActive, nil
+	return nil
+}
+
+// List returns a snapshot of every registered capability, in registration order.
+func (r *CapabilityRegistry) List() []CapabilityInfo {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	infos := make([]CapabilityInfo, 0, len(r.order))
+	for _, name := range r.order {
+		e := r.entries[name]
+		info := CapabilityInfo{
+			Name:        name,
+			Description: e.capability.Description(),
+			State:       e.state,
+		}
+		if cc, ok := e.capability.(CommandCapability); ok {
+			info.Command = cc.Command()
+		}
+		if hc, ok := e.capability.(HTTPCapability); ok {
+			info.Route = hc.Route()
+		}
+		if e.err != nil {
+			info.Error = e.err.Error()
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// Health runs the health check of every active capability; the map holds the
+// failures by name.
+func (r *CapabilityRegistry) Health(ctx context.Context) map[string]error {
+	failures := make(map[string]error)
+	for _, name := range r.names() {
+		r.mutex.RLock()
+		e := r.entries[name]
+		active := e.state == CapabilityActive
+		r.mutex.RUnlock()
+		if !active {
+			continue
+		}
+		if err := safeCapabilityCall(func() error { return e.capability.Health(ctx) }); err != nil {
+			failures[name] = err
+		}
+	}
+	return failures
+}
+
+// CommandFor returns the active capability that handles the slash command cmd.
+func (r *CapabilityRegistry) CommandFor(cmd string) (CommandCapability, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, e := range r.entries {
+		if cc, ok := e.capability.(CommandCapability); ok && cc.Command() == cmd && e.state == CapabilityActive {
+			return cc, true
+		}
+	}
+	return nil, false
+}
+
+// Commands returns the console commands of the active capabilities, sorted.
+func (r *CapabilityRegistry) Commands() []consoleCommand {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	var cmds []consoleCommand
+	for _, e := range r.entries {
+		if cc, ok := e.capability.(CommandCapability); ok && e.state == CapabilityActive {
+			cmds = append(cmds, consoleCommand{Name: cc.Command(), Usage: cc.Usage(), Help: cc.Description()})
+		}
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name < cmds[j].Name })
+	return cmds
+}
+
+// Names returns the registered capability names, for completion.
+func (r *CapabilityRegistry) Names() []string {
+	return r.names()
+}
+
+// Mount registers the routes of every HTTP capability on mux. Requests to a
+// capability that is not active get 404, so disabling needs no re-mount. A
+// route that conflicts with an existing one is skipped and reported.
+func (r *CapabilityRegistry) Mount(mux *http.ServeMux) {
+	for _, name := range r.names() {
+		r.mutex.RLock()
+		hc, ok := r.entries[name].capability.(HTTPCapability)
+		r.mutex.RUnlock()
+		if !ok {
+			continue
+		}
+		if err := mountRoute(mux, hc.Route(), r.gate(name, hc)); err != nil {
+			fmt.Printf("SIE-∞ Warning: capability %s route %q not mounted: %v\n", name, hc.Route(), err)
+		}
+	}
+}
+
+// gate serves through h only while the named capability is active.
+func (r *CapabilityRegistry) gate(name string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mutex.RLock()
+		active := r.entries[name].state == CapabilityActive
+		r.mutex.RUnlock()
+		if !active {
+			http.NotFound(w, req)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+func (r *CapabilityRegistry) names() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return append([]string(nil), r.order...)
+}
+
+// mountRoute turns http.ServeMux's panic on invalid or conflicting patterns
+// into an error.
+func mountRoute(mux *http.ServeMux, pattern string, h http.Handler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	mux.Handle(pattern, h)
+	return nil
+}
+
+// safeCapabilityCall runs a capability hook, turning a panic into an error so
+// one faulty capability cannot take the process down.
+func safeCapabilityCall(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
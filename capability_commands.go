@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// capabilitiesHandler lists the registered capabilities with their health.
+func capabilitiesHandler(ctx context.Context) {
+	infos := capabilities.List()
+	if len(infos) == 0 {
+		fmt.Println("No capabilities registered.")
+		return
+	}
+	failures := capabilities.Health(ctx)
+	fmt.Printf("%-24s %-11s %-16s %-24s %s\n", "NAME", "STATE", "COMMAND", "ROUTE", "HEALTH")
+	for _, info := range infos {
+		health := "-"
+		if info.State == CapabilityActive {
+			health = "ok"
+			if err := failures[info.Name]; err != nil {
+				health = err.Error()
+			}
+		} else if info.Error != "" {
+			health = info.Error
+		}
+		fmt.Printf("%-24s %-11s %-16s %-24s %s\n", info.Name, info.State, dashIfEmpty(info.Command), dashIfEmpty(info.Route), health)
+	}
+}
+
+// enableHandler re-initialises a disabled or failed capability.
+func enableHandler(ctx context.Context, name string) {
+	if name == "" {
+		fmt.Println("Error: Usage: /enable <name>")
+		return
+	}
+	if err := capabilities.Enable(ctx, name); err != nil {
+		fmt.Printf("SIE-∞ Error: %v\n", err)
+		return
+	}
+	fmt.Printf("SIE-∞: Capability %s enabled.\n", name)
+}
+
+// disableHandler shuts a capability down until it is enabled again.
+func disableHandler(ctx context.Context, name string) {
+	if name == "" {
+		fmt.Println("Error: Usage: /disable <name>")
+		return
+	}
+	if err := capabilities.Disable(ctx, name); err != nil {
+		fmt.Printf("SIE-∞ Error: %v\n", err)
+		return
+	}
+	fmt.Printf("SIE-∞: Capability %s disabled.\n", name)
+}
+
+// unloadHandler shuts a capability down for the rest of the session.
+func unloadHandler(ctx context.Context, name string) {
+	if name == "" {
+		fmt.Println("Error: Usage: /unload <name>")
+		return
+	}
+	if err := capabilities.Unload(ctx, name); err != nil {
+		fmt.Printf("SIE-∞ Error: %v\n", err)
+		return
+	}
+	fmt.Printf("SIE-∞: Capability %s unloaded. Roll back the proposal that added it to remove its code.\n", name)
+}
+
+// capabilityCommandHandler runs a slash command provided by a capability.
+func capabilityCommandHandler(ctx context.Context, cc CommandCapability, args string) {
+	var out string
+	err := safeCapabilityCall(func() error {
+		var err error
+		out, err = cc.RunCommand(ctx, args)
+		return err
+	})
+	if err != nil {
+		fmt.Printf("SIE-∞ Error: %s: %v\n", cc.Name(), err)
+		return
+	}
+	if out != "" {
+		fmt.Printf("SIE-∞: %s\n", out)
+	}
+}
+
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
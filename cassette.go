@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CassetteMode selects how a CassetteProvider treats model calls.
+type CassetteMode string
+
+const (
+	CassetteOff    CassetteMode = "off"    // Pass every call straight through
+	CassetteRecord CassetteMode = "record" // Call the backend and write each exchange to the cassette
+	CassetteReplay CassetteMode = "replay" // Serve exchanges from the cassette, never touching the backend
+)
+
+// CassetteEntry is one recorded prompt/response exchange.
+type CassetteEntry struct {
+	Key        string            `json:"key"`        // Hash of the normalized request
+	Method     string            `json:"method"`     // generate, chat, multimodal or count_tokens
+	Model      string            `json:"model"`      // Provider name at record time
+	Parameters map[string]string `json:"parameters"` // Call parameters that influenced the response
+	Prompt     string            `json:"prompt"`     // Prompt as sent, kept for human review
+	Response   string            `json:"response"`
+	Tokens     int               `json:"tokens,omitempty"`
+	RecordedAt time.Time         `json:"recorded_at"`
+}
+
+// Cassette is the on-disk collection of recorded exchanges.
+type Cassette struct {
+	Version int             `json:"version"`
+	Entries []CassetteEntry `json:"entries"`
+}
+
+// CassetteProvider wraps a ModelProvider so every LLM call can be recorded
+// to, or replayed from, a cassette file. Replay mode needs no backend at all,
+// which lets the /implement → Verify → Merge flow run on air-gapped CI.
+type CassetteProvider struct {
+	inner ModelProvider
+	mode  CassetteMode
+	path  string
+
+	mutex   sync.Mutex
+	entries map[string]CassetteEntry
+	order   []string
+}
+
+// NewCassetteProvider loads the cassette at path (if any) and wraps inner.
+// In replay mode inner may be nil and the cassette file must exist.
+func NewCassetteProvider(inner ModelProvider, mode CassetteMode, path string) (*CassetteProvider, error) {
+	cp := &CassetteProvider{
+		inner:   inner,
+		mode:    mode,
+		path:    path,
+		entries: make(map[string]CassetteEntry),
+	}
+
+	switch mode {
+	case CassetteOff:
+	case CassetteRecord, CassetteReplay:
+		if path == "" {
+			return nil, fmt.Errorf("cassette mode %q requires a cassette path", mode)
+		}
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %q", mode)
+	}
+	if mode != CassetteReplay && inner == nil {
+		return nil, fmt.Errorf("cassette mode %q requires a backend provider", mode)
+	}
+
+	if mode == CassetteOff {
+		return cp, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		var c Cassette
+		if err := json.Unmarshal(raw, &c); err != nil {
+			return nil, fmt.Errorf("failed to decode cassette %s: %v", path, err)
+		}
+		for _, e := range c.Entries {
+			if _, seen := cp.entries[e.Key]; !seen {
+				cp.order = append(cp.order, e.Key)
+			}
+			cp.entries[e.Key] = e
+		}
+	case os.IsNotExist(err) && mode == CassetteRecord:
+		// A fresh recording starts from an empty cassette.
+	default:
+		return nil, fmt.Errorf("failed to read cassette %s: %v", path, err)
+	}
+
+	return cp, nil
+}
+
+func (cp *CassetteProvider) Name() string {
+	if cp.inner != nil {
+		return cp.inner.Name()
+	}
+	return "cassette/" + cp.path
+}
+
+func (cp *CassetteProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	e, err := cp.exchange("generate", prompt, nil, func() (string, int, error) {
+		resp, err := cp.inner.Generate(ctx, prompt)
+		return resp, 0, err
+	})
+	return e.Response, err
+}
+
+func (cp *CassetteProvider) Chat(ctx context.Context, history []ChatMessage, message string) (string, error) {
+	var b strings.Builder
+	for _, m := range history {
+		fmt.Fprintf(&b, "%s: %s\n", m.Role, m.Text)
+	}
+	params := map[string]string{"history": b.String()}
+	e, err := cp.exchange("chat", message, params, func() (string, int, error) {
+		resp, err := cp.inner.Chat(ctx, history, message)
+		return resp, 0, err
+	})
+	return e.Response, err
+}
+
+func (cp *CassetteProvider) Multimodal(ctx context.Context, prompt string, media []MediaPart) (string, error) {
+	params := map[string]string{}
+	for i, m := range media {
+		sum := sha256.Sum256(m.Data)
+		params[fmt.Sprintf("media_%d", i)] = m.MIMEType + ":" + hex.EncodeToString(sum[:])
+	}
+	e, err := cp.exchange("multimodal", prompt, params, func() (string, int, error) {
+		resp, err := cp.inner.Multimodal(ctx, prompt, media)
+		return resp, 0, err
+	})
+	return e.Response, err
+}
+
+func (cp *CassetteProvider) CountTokens(ctx context.Context, text string) (int, error) {
+	e, err := cp.exchange("count_tokens", text, nil, func() (string, int, error) {
+		n, err := cp.inner.CountTokens(ctx, text)
+		return "", n, err
+	})
+	return e.Tokens, err
+}
+
+// exchange serves one call according to the cassette mode. call performs the
+// live request and returns the response text and, for token counts, the count.
+func (cp *CassetteProvider) exchange(method, prompt string, params map[string]string, call func() (string, int, error)) (CassetteEntry, error) {
+	key := cassetteKey(method, prompt, params)
+
+	if cp.mode == CassetteReplay {
+		cp.mutex.Lock()
+		entry, ok := cp.entries[key]
+		cp.mutex.Unlock()
+		if !ok {
+			return CassetteEntry{}, fmt.Errorf("cassette miss: no recorded %s response for prompt %s (%q) in %s",
+				method, key[:12], snippet(normalizePrompt(prompt), 60), cp.path)
+		}
+		return entry, nil
+	}
+
+	resp, tokens, err := call()
+	if err != nil {
+		return CassetteEntry{}, err
+	}
+	entry := CassetteEntry{
+		Key:        key,
+		Method:     method,
+		Model:      cp.inner.Name(),
+		Parameters: params,
+		Prompt:     prompt,
+		Response:   resp,
+		Tokens:     tokens,
+		RecordedAt: time.Now().UTC(),
+	}
+	if cp.mode == CassetteRecord {
+		if err := cp.record(entry); err != nil {
+			return CassetteEntry{}, err
+		}
+	}
+	return entry, nil
+}
+
+// record adds entry to the cassette and rewrites the file so a crash mid-run
+// never loses exchanges that were already made.
+func (cp *CassetteProvider) record(entry CassetteEntry) error {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	if _, seen := cp.entries[entry.Key]; !seen {
+		cp.order = append(cp.order, entry.Key)
+	}
+	cp.entries[entry.Key] = entry
+
+	c := Cassette{Version: 1, Entries: make([]CassetteEntry, 0, len(cp.order))}
+	for _, k := range cp.order {
+		c.Entries = append(c.Entries, cp.entries[k])
+	}
+	raw, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %v", err)
+	}
+	tmp := cp.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0644); err != nil {
+		return fmt.Errorf("failed to write cassette: %v", err)
+	}
+	if err := os.Rename(tmp, cp.path); err != nil {
+		return fmt.Errorf("failed to write cassette: %v", err)
+	}
+	return nil
+}
+
+// cassetteKey hashes the method, normalized prompt and sorted parameters.
+// The model name is deliberately left out so a cassette recorded against one
+// backend can be replayed when a different one is configured.
+func cassetteKey(method, prompt string, params map[string]string) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(normalizePrompt(prompt)))
+	for _, k := range sortedKeys(params) {
+		h.Write([]byte{0})
+		h.Write([]byte(k + "=" + normalizePrompt(params[k])))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizePrompt collapses whitespace runs so cosmetic edits to a prompt
+// template (indentation, trailing spaces, CRLF) do not invalidate a cassette.
+func normalizePrompt(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// failingReport is a test failure as a sandbox run in workDir reports it,
+// with the timings that run happened to take.
+func failingReport(workDir, elapsed string) *VerificationReport {
+	output := "=== RUN   TestShout\n" +
+		"    shout_test.go:7: Shout(\"a\") = \"a\"\n" +
+		"--- FAIL: TestShout (" + elapsed + "s)\n" +
+		"panic: boom [recovered]\n\t" + workDir + "/shout.go:5 +0x1d\n"
+	return &VerificationReport{
+		ProposalID:    "PROP-shout.go",
+		FailureReason: "1 test(s) failed",
+		Tests:         []TestResult{{Package: "mymodule", Name: "TestShout", Output: output}},
+		Steps: []VerificationStep{{
+			Name:   "test",
+			Stdout: "FAIL\tmymodule\t" + elapsed + "s\n",
+		}},
+	}
+}
+
+func TestCassetteReplaysRepairRound(t *testing.T) {
+	first, second := failingReport("/tmp/sie-verify-1804289383", "0.01"), failingReport("/tmp/sie-verify-846930886", "1.37")
+	if first.Feedback() != second.Feedback() {
+		t.Fatalf("Feedback() differs between runs:\n%s\n---\n%s", first.Feedback(), second.Feedback())
+	}
+	if feedback := first.Feedback(); strings.Contains(feedback, "sie-verify-") || strings.Contains(feedback, "0.01s") {
+		t.Fatalf("Feedback() keeps volatile details:\n%s", feedback)
+	}
+
+	const basePrompt = "Propose a capability. go_file_start"
+	backend := NewFakeProvider("test")
+	backend.Responses = append([]FakeResponse{{Match: "Repair Required", Response: "repaired answer"}}, backend.Responses...)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	ctx := context.Background()
+
+	// run makes the initial request and one repair round after report failed.
+	run := func(provider ModelProvider, report *VerificationReport) []string {
+		t.Helper()
+		answer, err := provider.Generate(ctx, basePrompt)
+		if err != nil {
+			t.Fatalf("Generate(initial) = %v", err)
+		}
+		repaired, err := provider.Generate(ctx, repairPrompt(basePrompt, answer, "verification", report.Feedback()))
+		if err != nil {
+			t.Fatalf("Generate(repair) = %v", err)
+		}
+		return []string{answer, repaired}
+	}
+
+	recorder, err := NewCassetteProvider(backend, CassetteRecord, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := run(recorder, first)
+	if recorded[1] != "repaired answer" {
+		t.Fatalf("recorded repair = %q", recorded[1])
+	}
+
+	player, err := NewCassetteProvider(nil, CassetteReplay, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The replayed run fails in another sandbox and takes another time.
+	replayed := run(player, second)
+	for i := range recorded {
+		if replayed[i] != recorded[i] {
+			t.Errorf("replayed response %d = %q, want %q", i, replayed[i], recorded[i])
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/lzw"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CompressionCorpus is the data Compression Efficiency is measured on.
+type CompressionCorpus struct {
+	KnowledgeStore []byte            // Everything the proposal store has learned
+	Conversation   []byte            // Chat memory of the console and the web UI
+	Codebase       map[string]string // File name → source of the running package
+}
+
+// SourceCompression is how well one source of the corpus compresses.
+type SourceCompression struct {
+	Source   string
+	RawBytes int
+	Sizes    map[string]int // Codec → compressed bytes
+	Best     string         // Codec with the smallest output, or "raw"
+	Ratio    float64        // Best compressed size / raw size, at most 1
+}
+
+// CompressionReport is the breakdown behind a CompressionEfficiency value.
+type CompressionReport struct {
+	Sources    []SourceCompression
+	Efficiency float64 // 1 - total best compressed size / total raw size
+	MeasuredAt time.Time
+}
+
+// compressionCodec reports the compressed size of data in bytes.
+type compressionCodec struct {
+	name string
+	size func(data []byte) (int, error)
+}
+
+// compressionCodecs are applied to every source. Each finds a different kind
+// of structure: flate repeated strings, lzw a growing phrase dictionary, and
+// order0 skewed byte frequencies alone.
+var compressionCodecs = []compressionCodec{
+	{"flate", flateSize},
+	{"lzw", lzwSize},
+	{"order0", order0Size},
+}
+
+// MeasureCompression compresses every non-empty source of the corpus with
+// each codec, and with a minimum-description-length estimate for the
+// codebase. CompressionEfficiency is the share of the corpus the best codec
+// of each source removes, so it changes only when the data does.
+func MeasureCompression(corpus CompressionCorpus) (CompressionReport, error) {
+	report := CompressionReport{MeasuredAt: time.Now()}
+
+	names := make([]string, 0, len(corpus.Codebase))
+	for name := range corpus.Codebase {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var code bytes.Buffer
+	for _, name := range names {
+		code.WriteString(corpus.Codebase[name])
+	}
+
+	sources := []struct {
+		name string
+		data []byte
+	}{
+		{"knowledge store", corpus.KnowledgeStore},
+		{"conversation", corpus.Conversation},
+		{"codebase", code.Bytes()},
+	}
+	var raw, compressed int
+	for _, src := range sources {
+		if len(src.data) == 0 {
+			continue
+		}
+		sc := SourceCompression{Source: src.name, RawBytes: len(src.data), Sizes: make(map[string]int)}
+		for _, codec := range compressionCodecs {
+			n, err := codec.size(src.data)
+			if err != nil {
+				return report, fmt.Errorf("%s compression of %s failed: %v", codec.name, src.name, err)
+			}
+			sc.Sizes[codec.name] = n
+		}
+		if src.name == "codebase" {
+			n, err := mdlSize(corpus.Codebase, names)
+			if err != nil {
+				return report, fmt.Errorf("description length of the codebase: %v", err)
+			}
+			sc.Sizes["mdl"] = n
+		}
+
+		// Storing the data as it is is always an option.
+		best, bestSize := "raw", sc.RawBytes
+		for codec, n := range sc.Sizes {
+			if n < bestSize || (n == bestSize && codec < best) {
+				best, bestSize = codec, n
+			}
+		}
+		sc.Best = best
+		sc.Ratio = float64(bestSize) / float64(sc.RawBytes)
+		report.Sources = append(report.Sources, sc)
+		raw += sc.RawBytes
+		compressed += bestSize
+	}
+	if raw == 0 {
+		return report, fmt.Errorf("nothing to measure: the corpus is empty")
+	}
+	report.Efficiency = 1 - float64(compressed)/float64(raw)
+	return report, nil
+}
+
+func flateSize(data []byte) (int, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	return buf.Len(), nil
+}
+
+func lzwSize(data []byte) (int, error) {
+	var buf bytes.Buffer
+	w := lzw.NewWriter(&buf, lzw.LSB, 8)
+	if _, err := w.Write(data); err != nil {
+		return 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	return buf.Len(), nil
+}
+
+// order0Size is the size an ideal entropy coder reaches with byte
+// frequencies alone, plus a 256-entry table of those frequencies.
+func order0Size(data []byte) (int, error) {
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	bitsTotal := 0.0
+	for _, c := range counts {
+		bitsTotal += float64(eliasGammaBits(c + 1))
+		if c > 0 {
+			bitsTotal -= float64(c) * math.Log2(float64(c)/float64(len(data)))
+		}
+	}
+	return int(math.Ceil(bitsTotal / 8)), nil
+}
+
+// mdlSize estimates the minimum description length of Go sources with a
+// two-part code over their tokens: the model is the vocabulary with each
+// token's count, the data is the token stream under those frequencies.
+// Layout is not charged for, since gofmt determines it from the tokens.
+func mdlSize(sources map[string]string, names []string) (int, error) {
+	counts := make(map[string]int)
+	total := 0
+	for _, name := range names {
+		src := []byte(sources[name])
+		fset := token.NewFileSet()
+		file := fset.AddFile(name, -1, len(src))
+		var errs scanner.ErrorList
+		var s scanner.Scanner
+		s.Init(file, src, func(pos token.Position, msg string) { errs.Add(pos, msg) }, scanner.ScanComments)
+		for {
+			_, tok, lit := s.Scan()
+			if tok == token.EOF {
+				break
+			}
+			text := tok.String()
+			if lit != "" {
+				text = lit
+			}
+			counts[text]++
+			total++
+		}
+		if err := errs.Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	modelBits, dataBits := 0.0, 0.0
+	for text, c := range counts {
+		modelBits += float64(8*(len(text)+1) + eliasGammaBits(c))
+		dataBits -= float64(c) * math.Log2(float64(c)/float64(total))
+	}
+	return int(math.Ceil((modelBits + dataBits) / 8)), nil
+}
+
+// eliasGammaBits is the length of the Elias gamma code of n >= 1.
+func eliasGammaBits(n int) int {
+	return 2*(bits.Len(uint(n))-1) + 1
+}
+
+// conversationCorpus flattens chat histories into text.
+func conversationCorpus(histories ...[]ChatMessage) []byte {
+	var b strings.Builder
+	for _, history := range histories {
+		for _, m := range history {
+			fmt.Fprintf(&b, "%s: %s\n", m.Role, m.Text)
+		}
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/peterh/liner"
+)
+
+// consoleCommand describes a slash command for /help and tab completion.
+type consoleCommand struct {
+	Name  string
+	Usage string
+	Help  string
+	// IDStates restricts proposal-ID completion to proposals in these states;
+	// nil means the command takes no proposal ID, empty means any state.
+	IDStates []ProposalState
+	// CapabilityArg completes capability names as the argument.
+	CapabilityArg bool
+}
+
+// consoleCommands is the operator console's command table.
+var consoleCommands = []consoleCommand{
+	{Name: "/implement", Usage: "/implement <description>", Help: "Generate, verify and stage a new capability"},
+	{Name: "/approve", Usage: "/approve <ID>", Help: "Approve a proposal, re-verify it and merge it", IDStates: []ProposalState{StateAwaitingApproval}},
+	{Name: "/reject", Usage: "/reject <ID> [reason]", Help: "Reject a proposal, recording the reason", IDStates: []ProposalState{StateGenerated, StateVerified, StateAwaitingApproval}},
+	{Name: "/rollback", Usage: "/rollback <ID>", Help: "Revert a merged proposal's commit", IDStates: []ProposalState{StateMerged}},
+	{Name: "/plan", Usage: "/plan <anomaly>", Help: "Plan a Decision Card for an anomaly and check it against the invariants"},
+	{Name: "/metrics", Usage: "/metrics", Help: "Measure the Prime Axioms and show how ε was computed"},
+	{Name: "/fact", Usage: "/fact <subject> | <relation> | <object>", Help: "Add a fact to the knowledge graph"},
+	{Name: "/graph", Usage: "/graph [concept]", Help: "Show the knowledge graph's metrics, or the facts about a concept"},
+	{Name: "/history", Usage: "/history [N|merges]", Help: "Show axiom trends and the last N samples, or predicted vs actual gains per merge"},
+	{Name: "/calibration", Usage: "/calibration", Help: "Show the Simulation Chamber's calibration and each merge's predicted vs observed effect"},
+	{Name: "/memory", Usage: "/memory [module=<name>] [proposal=<ID>] [since=<age>] [limit=<N>]", Help: "Query the avoidance rules and failed proposals in long-term memory"},
+	{Name: "/rules", Usage: "/rules [forgotten|conflicts|show <ID>|pin <ID>|unpin <ID>|delete <ID>]", Help: "Inspect avoidance rules and their conflicts, or pin, unpin or delete one"},
+	{Name: "/recall", Usage: "/recall [text]", Help: "Find past proposals, avoidance rules and conversations related in meaning to the text"},
+	{Name: "/dream", Usage: "/dream [now|abort]", Help: "Show the dream scheduler, or force or abort a consolidation cycle"},
+	{Name: "/list", Usage: "/list", Help: "List stored proposals"},
+	{Name: "/show", Usage: "/show <ID>", Help: "Show a proposal's Decision Card, verification report and history", IDStates: []ProposalState{}},
+	{Name: "/capabilities", Usage: "/capabilities", Help: "List plug-in capabilities with their state and health"},
+	{Name: "/enable", Usage: "/enable <name>", Help: "Re-initialise a disabled or failed capability", CapabilityArg: true},
+	{Name: "/disable", Usage: "/disable <name>", Help: "Shut a capability down until it is enabled again", CapabilityArg: true},
+	{Name: "/unload", Usage: "/unload <name>", Help: "Shut a capability down for the rest of the session", CapabilityArg: true},
+	{Name: "/help", Usage: "/help", Help: "Show this help"},
+	{Name: "/quit", Usage: "/quit", Help: "Leave the console (also /exit or Ctrl-D)"},
+}
+
+// multiLineDelimiter opens and closes a block of multi-line input.
+const multiLineDelimiter = `"""`
+
+// Console is the interactive operator REPL.
+type Console struct {
+	line        *liner.State
+	historyPath string
+}
+
+// NewConsole opens the terminal and loads the persisted command history.
+func NewConsole(historyPath string) *Console {
+	c := &Console{line: liner.NewLiner(), historyPath: historyPath}
+	c.line.SetCtrlCAborts(true)
+	c.line.SetMultiLineMode(true)
+	c.line.SetTabCompletionStyle(liner.TabPrints)
+	c.line.SetCompleter(completeCommandLine)
+
+	if f, err := os.Open(historyPath); err == nil {
+		c.line.ReadHistory(f)
+		f.Close()
+	}
+	return c
+}
+
+// Close saves the history and restores the terminal.
+func (c *Console) Close() error {
+	if err := os.MkdirAll(filepath.Dir(c.historyPath), 0700); err == nil {
+		if f, err := os.OpenFile(c.historyPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600); err == nil {
+			c.line.WriteHistory(f)
+			f.Close()
+		}
+	}
+	return c.line.Close()
+}
+
+// Run reads commands until the operator quits or input ends.
+func (c *Console) Run(ctx context.Context) {
+	fmt.Println(`Type /help for commands. End a line with \ or wrap text in """ for multi-line input.`)
+	for {
+		input, err := c.readInput()
+		if errors.Is(err, liner.ErrPromptAborted) {
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				fmt.Printf("SIE-∞ Error: %v\n", err)
+			}
+			fmt.Println()
+			return
+		}
+
+		input = strings.TrimSpace(input)
+		if input == "" {
+			continue
+		}
+		// History is line-oriented, so multi-line inputs are stored flattened.
+		c.line.AppendHistory(strings.ReplaceAll(input, "\n", " "))
+
+		switch input {
+		case "/quit", "/exit":
+			return
+		case "/help":
+			printHelp()
+			continue
+		}
+		handleUserCommand(ctx, input)
+	}
+}
+
+// readInput reads one logical input, joining continuation lines ending in a
+// backslash and everything between a pair of """ delimiters.
+func (c *Console) readInput() (string, error) {
+	first, err := c.line.Prompt("SIE-∞> ")
+	if err != nil {
+		return "", err
+	}
+
+	if head, ok := strings.CutSuffix(strings.TrimRight(first, " "), multiLineDelimiter); ok && !strings.Contains(head, multiLineDelimiter) {
+		lines := []string{head}
+		for {
+			next, err := c.line.Prompt("... ")
+			if err != nil {
+				return "", err
+			}
+			if body, done := strings.CutSuffix(strings.TrimRight(next, " "), multiLineDelimiter); done {
+				lines = append(lines, body)
+				return strings.TrimSpace(strings.Join(lines, "\n")), nil
+			}
+			lines = append(lines, next)
+		}
+	}
+
+	var b strings.Builder
+	line := first
+	for {
+		body, more := strings.CutSuffix(line, `\`)
+		b.WriteString(body)
+		if !more {
+			return b.String(), nil
+		}
+		b.WriteString("\n")
+		if line, err = c.line.Prompt("... "); err != nil {
+			return "", err
+		}
+	}
+}
+
+// completeCommandLine completes slash commands and, after commands that take
+// one, the IDs of proposals in a matching lifecycle state.
+func completeCommandLine(line string) []string {
+	name, arg, hasArg := strings.Cut(line, " ")
+	if !hasArg {
+		var out []string
+		for _, cmd := range append(consoleCommands, capabilities.Commands()...) {
+			if strings.HasPrefix(cmd.Name, name) {
+				out = append(out, cmd.Name)
+			}
+		}
+		if strings.HasPrefix("/exit", name) {
+			out = append(out, "/exit")
+		}
+		return out
+	}
+
+	if strings.Contains(arg, " ") {
+		return nil
+	}
+	for _, cmd := range consoleCommands {
+		if cmd.Name == name && cmd.CapabilityArg {
+			var out []string
+			for _, capName := range capabilities.Names() {
+				if strings.HasPrefix(capName, arg) {
+					out = append(out, name+" "+capName)
+				}
+			}
+			return out
+		}
+		if cmd.Name != name || cmd.IDStates == nil || proposalStore == nil {
+			continue
+		}
+		proposals, err := proposalStore.List()
+		if err != nil {
+			return nil
+		}
+		var out []string
+		for _, sp := range proposals {
+			if strings.HasPrefix(sp.ID, arg) && stateIn(sp.State, cmd.IDStates) {
+				out = append(out, name+" "+sp.ID)
+			}
+		}
+		sort.Strings(out)
+		return out
+	}
+	return nil
+}
+
+// stateIn reports whether s is one of states; an empty list matches all.
+func stateIn(s ProposalState, states []ProposalState) bool {
+	if len(states) == 0 {
+		return true
+	}
+	for _, want := range states {
+		if s == want {
+			return true
+		}
+	}
+	return false
+}
+
+// printHelp lists the console commands.
+func printHelp() {
+	fmt.Println("Commands:")
+	for _, cmd := range consoleCommands {
+		fmt.Printf("  %-26s %s\n", cmd.Usage, cmd.Help)
+	}
+	if cmds := capabilities.Commands(); len(cmds) > 0 {
+		fmt.Println("Capability commands:")
+		for _, cmd := range cmds {
+			fmt.Printf("  %-26s %s\n", cmd.Usage, cmd.Help)
+		}
+	}
+	fmt.Println("Anything else is sent to SIE-∞ as free-form chat.")
+}
+
+// historyPath returns the console history file, overridable via SIE_HISTORY.
+func historyPath() string {
+	if p := os.Getenv("SIE_HISTORY"); p != "" {
+		return p
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".sie_history")
+	}
+	return ".sie_history"
+}
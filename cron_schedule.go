@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a five-field cron expression: minute, hour, day of month,
+// month and day of week. Each field is *, a number, a range a-b, a list of
+// those separated by commas, or any of them with a /step. As in cron, when
+// both day fields are restricted a day matching either one matches.
+type CronSchedule struct {
+	spec                          string
+	minute, hour, dom, month, dow map[int]bool
+	domAny, dowAny                bool
+}
+
+// cronFields are the bounds of each field, in order.
+var cronFields = []struct {
+	name     string
+	min, max int
+}{{"minute", 0, 59}, {"hour", 0, 23}, {"day of month", 1, 31}, {"month", 1, 12}, {"day of week", 0, 6}}
+
+// ParseCronSchedule parses a five-field cron expression. Day of week 7 is
+// accepted as Sunday.
+func ParseCronSchedule(spec string) (*CronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron schedule %q must have 5 fields (minute hour day-of-month month day-of-week), got %d", spec, len(fields))
+	}
+	sets := make([]map[int]bool, len(fields))
+	for i, f := range fields {
+		bounds := cronFields[i]
+		max := bounds.max
+		if i == 4 {
+			max = 7
+		}
+		set, err := parseCronField(f, bounds.min, max)
+		if err != nil {
+			return nil, fmt.Errorf("cron schedule %q: %s: %v", spec, bounds.name, err)
+		}
+		sets[i] = set
+	}
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+	return &CronSchedule{
+		spec:   spec,
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domAny: strings.HasPrefix(fields[2], "*"),
+		dowAny: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parseCronField expands one field into the values it matches.
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("bad step %q", stepText)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return nil, fmt.Errorf("bad value %q", from)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(to); err != nil {
+					return nil, fmt.Errorf("bad value %q", to)
+				}
+			} else if hasStep {
+				hi = max
+			}
+			if lo < min || hi > max || lo > hi {
+				return nil, fmt.Errorf("%q is outside %d-%d", rng, min, max)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+func (c *CronSchedule) String() string {
+	return c.spec
+}
+
+// dayMatches applies cron's rule for the two day fields.
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	dom, dow := c.dom[t.Day()], c.dow[int(t.Weekday())]
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// Next returns the first whole minute after t that the schedule matches, in
+// t's location, or the zero time if none falls within five years.
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !c.month[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !c.hour[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !c.minute[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		spec string
+		from string
+		want string // "" for no match within five years
+	}{
+		{"*/15 * * * *", "2026-10-17 10:07:30", "2026-10-17 10:15:00"},
+		{"*/15 * * * *", "2026-10-17 10:15:00", "2026-10-17 10:30:00"},
+		{"0 9 * * 1-5", "2026-10-16 18:00:00", "2026-10-19 09:00:00"},
+		{"30 8 * * 7", "2026-10-17 12:00:00", "2026-10-18 08:30:00"},
+		{"0 0 13 * 5", "2026-10-17 12:00:00", "2026-10-23 00:00:00"},
+		{"0 0 1 1 *", "2026-10-17 12:00:00", "2027-01-01 00:00:00"},
+		{"5,35 22-23 * * *", "2026-12-31 23:40:00", "2027-01-01 22:05:00"},
+		{"0 0 29 2 *", "2026-10-17 12:00:00", "2028-02-29 00:00:00"},
+		{"0 0 30 2 *", "2026-10-17 12:00:00", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec+" from "+tt.from, func(t *testing.T) {
+			c, err := ParseCronSchedule(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseCronSchedule() = %v", err)
+			}
+			got := c.Next(at(tt.from))
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Errorf("Next() = %v, want no match", got)
+				}
+				return
+			}
+			if want := at(tt.want); !got.Equal(want) {
+				t.Errorf("Next() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseCronScheduleErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"* * * *", "must have 5 fields"},
+		{"60 * * * *", "minute"},
+		{"* 5-2 * * *", "hour"},
+		{"*/0 * * * *", "bad step"},
+		{"* * 0 * *", "day of month"},
+		{"* * * * mon", "day of week"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			if _, err := ParseCronSchedule(tt.spec); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseCronSchedule() error = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,595 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PolicyVerdict is the outcome of evaluating an import against the policy.
+// Verdicts are ordered: allow < review < deny.
+type PolicyVerdict string
+
+const (
+	VerdictAllow  PolicyVerdict = "allow"
+	VerdictReview PolicyVerdict = "review"
+	VerdictDeny   PolicyVerdict = "deny"
+)
+
+func (v PolicyVerdict) severity() int {
+	switch v {
+	case VerdictDeny:
+		return 2
+	case VerdictReview:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// worse returns the more severe of two verdicts.
+func (v PolicyVerdict) worse(o PolicyVerdict) PolicyVerdict {
+	if o.severity() > v.severity() {
+		return o
+	}
+	return v
+}
+
+// PolicyRule assigns a verdict to an import path. A Pattern ending in "/..."
+// also matches every package below that path; the most specific rule wins.
+type PolicyRule struct {
+	Pattern string        `json:"pattern"`
+	Verdict PolicyVerdict `json:"verdict"`
+	Reason  string        `json:"reason"`
+}
+
+func (r PolicyRule) matches(path string) bool {
+	if prefix, ok := strings.CutSuffix(r.Pattern, "/..."); ok {
+		return path == prefix || strings.HasPrefix(path, prefix+"/")
+	}
+	return path == r.Pattern
+}
+
+// DependencyPolicy decides which packages generated code may import.
+type DependencyPolicy struct {
+	Rules            []PolicyRule  `json:"rules"`
+	StdlibDefault    PolicyVerdict `json:"stdlib_default"`     // Standard-library packages without a rule
+	ExternalDefault  PolicyVerdict `json:"external_default"`   // Modules already required by go.mod
+	NewModuleVerdict PolicyVerdict `json:"new_module_verdict"` // Modules not yet in go.mod
+}
+
+// NewDependencyPolicy returns the built-in policy: process execution, raw
+// syscalls, memory unsafety and dynamic loading are denied outright, while
+// reflection, networking and filesystem access need operator review.
+func NewDependencyPolicy() *DependencyPolicy {
+	return &DependencyPolicy{
+		Rules: []PolicyRule{
+			{"os/exec", VerdictDeny, "spawns arbitrary processes outside the sandbox"},
+			{"syscall", VerdictDeny, "raw system calls bypass every higher-level safeguard"},
+			{"golang.org/x/sys/...", VerdictDeny, "raw system calls bypass every higher-level safeguard"},
+			{"unsafe", VerdictDeny, "breaks memory safety and can rewrite protected state"},
+			{"plugin", VerdictDeny, "loads unverified code at runtime"},
+			{"C", VerdictDeny, "cgo links unverified native code"},
+			{"runtime/cgo", VerdictDeny, "cgo links unverified native code"},
+			{"debug/...", VerdictDeny, "binary introspection is not needed by capabilities"},
+			{"reflect", VerdictReview, "reflection can reach unexported state of the kernel"},
+			{"runtime", VerdictReview, "can alter scheduler and GC behaviour of the whole process"},
+			{"runtime/debug", VerdictReview, "can alter GC limits and stack behaviour"},
+			{"os", VerdictReview, "filesystem and environment access"},
+			{"os/signal", VerdictReview, "can intercept operator shutdown signals"},
+			{"net/...", VerdictReview, "network access"},
+			{"net", VerdictReview, "network access"},
+			{"database/sql", VerdictReview, "direct access to long-term memory"},
+			{"github.com/mattn/go-sqlite3", VerdictReview, "direct access to long-term memory"},
+			{"github.com/google/uuid", VerdictAllow, "already used by the planner for proposal IDs"},
+			{"google.golang.org/genai/...", VerdictReview, "spends model budget outside the provider abstraction"},
+		},
+		StdlibDefault:    VerdictAllow,
+		ExternalDefault:  VerdictReview,
+		NewModuleVerdict: VerdictReview,
+	}
+}
+
+// LoadDependencyPolicy reads a JSON policy from path. A missing file yields
+// the built-in policy; rules in the file take precedence over built-ins with
+// the same pattern.
+func LoadDependencyPolicy(path string) (*DependencyPolicy, error) {
+	policy := NewDependencyPolicy()
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return policy, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dependency policy %s: %v", path, err)
+	}
+
+	var custom DependencyPolicy
+	if err := json.Unmarshal(raw, &custom); err != nil {
+		return nil, fmt.Errorf("failed to decode dependency policy %s: %v", path, err)
+	}
+	for _, r := range custom.Rules {
+		switch r.Verdict {
+		case VerdictAllow, VerdictReview, VerdictDeny:
+		default:
+			return nil, fmt.Errorf("dependency policy rule %q has unknown verdict %q", r.Pattern, r.Verdict)
+		}
+	}
+
+	byPattern := make(map[string]int)
+	for i, r := range policy.Rules {
+		byPattern[r.Pattern] = i
+	}
+	for _, r := range custom.Rules {
+		if i, ok := byPattern[r.Pattern]; ok {
+			policy.Rules[i] = r
+		} else {
+			policy.Rules = append(policy.Rules, r)
+		}
+	}
+	if custom.StdlibDefault != "" {
+		policy.StdlibDefault = custom.StdlibDefault
+	}
+	if custom.ExternalDefault != "" {
+		policy.ExternalDefault = custom.ExternalDefault
+	}
+	if custom.NewModuleVerdict != "" {
+		policy.NewModuleVerdict = custom.NewModuleVerdict
+	}
+	return policy, nil
+}
+
+// ImportFinding is the policy's judgement of one import or directive.
+type ImportFinding struct {
+	File      string
+	Line      int
+	Path      string // Import path, or the directive for compiler directives
+	Alias     string // Local name, "." for dot imports, "_" for blank imports
+	Module    string // Module providing the package; empty for the standard library
+	NewModule bool   // Module is not required by the current go.mod
+	Verdict   PolicyVerdict
+	Reason    string
+}
+
+// ModuleRequirement is a single require line from go.mod.
+type ModuleRequirement struct {
+	Path     string
+	Version  string
+	Indirect bool
+}
+
+// ModuleReplacement is a single replace line from go.mod. Version is empty
+// when every version is replaced, and NewVersion when New is a directory.
+type ModuleReplacement struct {
+	Old, Version    string
+	New, NewVersion string
+}
+
+func (r ModuleReplacement) String() string {
+	old, repl := r.Old, r.New
+	if r.Version != "" {
+		old += " " + r.Version
+	}
+	if r.NewVersion != "" {
+		repl += " " + r.NewVersion
+	}
+	return old + " => " + repl
+}
+
+// isLocalReplacement reports whether a replacement points at a directory
+// rather than a module.
+func (r ModuleReplacement) isLocalReplacement() bool {
+	return strings.HasPrefix(r.New, "./") || strings.HasPrefix(r.New, "../") || strings.HasPrefix(r.New, "/") || r.New == "." || r.New == ".."
+}
+
+// DependencyRiskMap is the analysed dependency surface of a proposal.
+type DependencyRiskMap struct {
+	Findings    []ImportFinding
+	ParseErrors []string
+	Claim       string // The model's self-reported analysis, kept for comparison
+}
+
+// Verdict is the most severe verdict across all findings.
+func (m DependencyRiskMap) Verdict() PolicyVerdict {
+	v := VerdictAllow
+	if len(m.ParseErrors) > 0 {
+		v = VerdictDeny
+	}
+	for _, f := range m.Findings {
+		v = v.worse(f.Verdict)
+	}
+	return v
+}
+
+// Flagged returns the findings that are not plainly allowed.
+func (m DependencyRiskMap) Flagged() []ImportFinding {
+	var out []ImportFinding
+	for _, f := range m.Findings {
+		if f.Verdict != VerdictAllow {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func (m DependencyRiskMap) String() string {
+	if len(m.Findings) == 0 && len(m.ParseErrors) == 0 {
+		return "None (no imports)"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s", strings.ToUpper(string(m.Verdict())))
+	for _, e := range m.ParseErrors {
+		fmt.Fprintf(&b, "\n  [deny] unparseable source: %s", e)
+	}
+	for _, f := range m.Findings {
+		name := f.Path
+		if f.Alias != "" {
+			name = f.Alias + " " + strconv.Quote(f.Path)
+		}
+		fmt.Fprintf(&b, "\n  [%s] %s (%s:%d)", f.Verdict, name, f.File, f.Line)
+		if f.Reason != "" {
+			fmt.Fprintf(&b, ": %s", f.Reason)
+		}
+	}
+	return b.String()
+}
+
+// Evaluate parses every Go file in files (name → source), resolves each
+// import against the module requirements in goMod, and judges it under the
+// policy. Compiler directives such as //go:linkname, which reach into other
+// packages without importing them, are reported as denied findings.
+func (dp *DependencyPolicy) Evaluate(files map[string]string, goMod []byte) DependencyRiskMap {
+	var m DependencyRiskMap
+	requires := parseGoModRequires(goMod)
+	module := goModModulePath(goMod)
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		src := files[name]
+		if strings.TrimSpace(src) == "" {
+			continue
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			m.ParseErrors = append(m.ParseErrors, err.Error())
+			continue
+		}
+
+		for _, spec := range f.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				m.ParseErrors = append(m.ParseErrors, fmt.Sprintf("%s: bad import path %s", name, spec.Path.Value))
+				continue
+			}
+			finding := ImportFinding{File: name, Line: fset.Position(spec.Pos()).Line, Path: path}
+			if spec.Name != nil {
+				finding.Alias = spec.Name.Name
+			}
+			dp.judge(&finding, module, requires)
+			m.Findings = append(m.Findings, finding)
+		}
+
+		for _, group := range f.Comments {
+			for _, c := range group.List {
+				if strings.HasPrefix(c.Text, "//go:linkname") {
+					m.Findings = append(m.Findings, ImportFinding{
+						File:    name,
+						Line:    fset.Position(c.Pos()).Line,
+						Path:    strings.TrimSpace(c.Text),
+						Verdict: VerdictDeny,
+						Reason:  "linkname reaches unexported symbols of other packages without importing them",
+					})
+				}
+			}
+		}
+	}
+	return m
+}
+
+// EvaluateGoModChange judges every requirement that after adds or moves to
+// another version, and every replace directive it adds or changes.
+// Indirect requirements are transitive dependencies pulled in by a direct
+// one and are reported as such. A replacement swaps the code behind a module
+// path, so it always needs review, and one pointing at a directory is denied.
+func (dp *DependencyPolicy) EvaluateGoModChange(before, after []byte) []ImportFinding {
+	existing := make(map[string]string)
+	for _, r := range parseGoModRequires(before) {
+		existing[r.Path] = r.Version
+	}
+
+	var findings []ImportFinding
+	for _, r := range parseGoModRequires(after) {
+		oldVersion, required := existing[r.Path]
+		if required && oldVersion == r.Version {
+			continue
+		}
+		f := ImportFinding{File: "go.mod", Path: r.Path, Module: r.Path, NewModule: !required}
+		rule, ok := dp.ruleFor(r.Path)
+		if ok {
+			f.Verdict, f.Reason = rule.Verdict, rule.Reason
+		} else {
+			f.Verdict, f.Reason = VerdictAllow, ""
+		}
+		f.Verdict = f.Verdict.worse(dp.NewModuleVerdict)
+		kind := "new direct requirement " + r.Version
+		switch {
+		case required:
+			kind = fmt.Sprintf("requirement changed from %s to %s", oldVersion, r.Version)
+		case r.Indirect:
+			kind = "new transitive requirement " + r.Version
+		}
+		f.Reason = joinReason(kind, f.Reason)
+		findings = append(findings, f)
+	}
+
+	replaced := make(map[ModuleReplacement]bool)
+	for _, r := range parseGoModReplaces(before) {
+		replaced[r] = true
+	}
+	for _, r := range parseGoModReplaces(after) {
+		if replaced[r] {
+			continue
+		}
+		f := ImportFinding{File: "go.mod", Path: "replace " + r.String(), Module: r.Old, Verdict: VerdictReview,
+			Reason: "replace directive swaps the code behind " + r.Old}
+		paths := []string{r.Old}
+		if r.isLocalReplacement() {
+			f.Verdict = VerdictDeny
+			f.Reason = joinReason(f.Reason, "points at a directory outside the module cache")
+		} else {
+			paths = append(paths, r.New)
+		}
+		for _, path := range paths {
+			if rule, ok := dp.ruleFor(path); ok && rule.Verdict != VerdictAllow {
+				f.Verdict = f.Verdict.worse(rule.Verdict)
+				f.Reason = joinReason(f.Reason, rule.Reason)
+			}
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// EvaluateChange judges every import and directive of every file a change
+// touches, so an edit to a file that already imports a denied package is
+// charged for it; findings the file had before are marked as such. before
+// and after map paths to content, an empty after entry deleting the file;
+// goMod is the current go.mod, and a change to it is judged as well.
+func (dp *DependencyPolicy) EvaluateChange(before, after map[string]string, goMod []byte) DependencyRiskMap {
+	goFiles := func(files map[string]string) map[string]string {
+		sources := make(map[string]string)
+		for name, src := range files {
+			if strings.HasSuffix(name, ".go") {
+				sources[name] = src
+			}
+		}
+		return sources
+	}
+	newGoMod, goModChanged := after["go.mod"]
+	if !goModChanged {
+		newGoMod = string(goMod)
+	}
+
+	m := dp.Evaluate(goFiles(after), []byte(newGoMod))
+	type key struct{ file, path, alias string }
+	existing := make(map[key]bool)
+	for _, f := range dp.Evaluate(goFiles(before), goMod).Findings {
+		existing[key{f.File, f.Path, f.Alias}] = true
+	}
+	for i, f := range m.Findings {
+		if existing[key{f.File, f.Path, f.Alias}] {
+			m.Findings[i].Reason = joinReason(f.Reason, "already in the file before this change")
+		}
+	}
+	if goModChanged {
+		m.Findings = append(m.Findings, dp.EvaluateGoModChange(goMod, []byte(newGoMod))...)
+	}
+	return m
+}
+
+// judge fills in the module, verdict and reason of a finding. module is
+// the path of the module being changed.
+func (dp *DependencyPolicy) judge(f *ImportFinding, module string, requires []ModuleRequirement) {
+	rule, hasRule := dp.ruleFor(f.Path)
+
+	switch {
+	case isStdlibImport(f.Path):
+		f.Verdict = dp.StdlibDefault
+	case module != "" && (f.Path == module || strings.HasPrefix(f.Path, module+"/")):
+		f.Module = module
+		f.Verdict = VerdictReview
+		f.Reason = "package of this module, whose code is not judged by this import"
+	default:
+		f.Module = moduleFor(f.Path, requires)
+		if f.Module == "" {
+			f.Module = f.Path
+			f.NewModule = true
+			f.Verdict = dp.NewModuleVerdict
+			f.Reason = "module is not required by go.mod"
+		} else {
+			f.Verdict = dp.ExternalDefault
+			f.Reason = "third-party module " + f.Module
+		}
+	}
+	if hasRule {
+		// An explicit rule overrides the defaults, but never hides that a
+		// brand-new module is being pulled in.
+		if f.NewModule {
+			f.Verdict = rule.Verdict.worse(dp.NewModuleVerdict)
+			f.Reason = joinReason(rule.Reason, "module is not required by go.mod")
+		} else {
+			f.Verdict = rule.Verdict
+			f.Reason = rule.Reason
+		}
+	}
+
+	switch f.Alias {
+	case ".":
+		f.Verdict = f.Verdict.worse(VerdictReview)
+		f.Reason = joinReason(f.Reason, "dot import hides which identifiers come from this package")
+	case "_":
+		if f.Verdict == VerdictAllow {
+			f.Verdict = VerdictReview
+		}
+		f.Reason = joinReason(f.Reason, "blank import runs package init side effects")
+	}
+}
+
+// ruleFor returns the most specific rule matching path.
+func (dp *DependencyPolicy) ruleFor(path string) (PolicyRule, bool) {
+	var best PolicyRule
+	found := false
+	for _, r := range dp.Rules {
+		if r.matches(path) && (!found || len(r.Pattern) > len(best.Pattern)) {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+// stdlibPackages caches isStdlibImport, which looks in GOROOT.
+var stdlibPackages sync.Map
+
+// isStdlibImport reports whether path names a package in GOROOT. A dot-less
+// path is not enough: the module's own packages and replaced modules need
+// not have a dot either. The cgo pseudo-package "C" is treated as standard
+// here and left to the policy rules.
+func isStdlibImport(path string) bool {
+	if path == "C" {
+		return true
+	}
+	if std, ok := stdlibPackages.Load(path); ok {
+		return std.(bool)
+	}
+	first, _, _ := strings.Cut(path, "/")
+	std := false
+	if !strings.Contains(first, ".") && first != "vendor" && first != "cmd" {
+		pkg, err := build.Default.Import(path, "", build.FindOnly)
+		std = err == nil && pkg.Goroot
+	}
+	stdlibPackages.Store(path, std)
+	return std
+}
+
+// moduleFor returns the longest required module path that provides path.
+func moduleFor(path string, requires []ModuleRequirement) string {
+	best := ""
+	for _, r := range requires {
+		if (path == r.Path || strings.HasPrefix(path, r.Path+"/")) && len(r.Path) > len(best) {
+			best = r.Path
+		}
+	}
+	return best
+}
+
+// parseGoModRequires extracts the require directives from a go.mod file.
+func parseGoModRequires(goMod []byte) []ModuleRequirement {
+	var reqs []ModuleRequirement
+	for _, d := range goModDirectives(goMod, "require") {
+		if len(d.fields) < 2 {
+			continue
+		}
+		reqs = append(reqs, ModuleRequirement{
+			Path:     d.fields[0],
+			Version:  d.fields[1],
+			Indirect: d.comment == "indirect",
+		})
+	}
+	return reqs
+}
+
+// parseGoModReplaces extracts the replace directives from a go.mod file.
+func parseGoModReplaces(goMod []byte) []ModuleReplacement {
+	var reps []ModuleReplacement
+	for _, d := range goModDirectives(goMod, "replace") {
+		old, repl, ok := strings.Cut(strings.Join(d.fields, " "), "=>")
+		oldFields, newFields := strings.Fields(old), strings.Fields(repl)
+		if !ok || len(oldFields) == 0 || len(oldFields) > 2 || len(newFields) == 0 || len(newFields) > 2 {
+			continue
+		}
+		r := ModuleReplacement{Old: oldFields[0], New: newFields[0]}
+		if len(oldFields) == 2 {
+			r.Version = oldFields[1]
+		}
+		if len(newFields) == 2 {
+			r.NewVersion = newFields[1]
+		}
+		reps = append(reps, r)
+	}
+	return reps
+}
+
+// goModModulePath returns the path declared by the module directive.
+func goModModulePath(goMod []byte) string {
+	for _, d := range goModDirectives(goMod, "module") {
+		if len(d.fields) > 0 {
+			return strings.Trim(d.fields[0], `"`)
+		}
+	}
+	return ""
+}
+
+// goModDirective is one line of a go.mod directive, without its verb.
+type goModDirective struct {
+	fields  []string
+	comment string
+}
+
+// goModDirectives returns the lines of every verb directive in a go.mod
+// file, handling both the single-line and parenthesised block forms.
+func goModDirectives(goMod []byte, verb string) []goModDirective {
+	var out []goModDirective
+	inBlock := false
+	scanner := bufio.NewScanner(strings.NewReader(string(goMod)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		comment := ""
+		if i := strings.Index(line, "//"); i >= 0 {
+			comment = line[i+2:]
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+
+		switch {
+		case inBlock && len(fields) == 1 && fields[0] == ")":
+			inBlock = false
+			continue
+		case inBlock:
+		case len(fields) >= 2 && fields[0] == verb && fields[1] == "(":
+			inBlock = true
+			continue
+		case len(fields) >= 1 && fields[0] == verb:
+			fields = fields[1:]
+		default:
+			continue
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		out = append(out, goModDirective{fields: fields, comment: strings.TrimSpace(comment)})
+	}
+	return out
+}
+
+func joinReason(parts ...string) string {
+	var nonEmpty []string
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, "; ")
+}
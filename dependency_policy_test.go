@@ -0,0 +1,187 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// testGoMod requires one module with an explicit review rule, one with an
+// allow rule and, indirectly, one with a deny rule.
+const testGoMod = `module mymodule
+
+go 1.24
+
+require (
+	github.com/google/uuid v1.6.0
+	github.com/mattn/go-sqlite3 v1.14.32
+	golang.org/x/sys v0.31.0 // indirect
+)
+
+replace github.com/google/uuid => github.com/google/uuid v1.6.0
+`
+
+func TestDependencyPolicyEvaluate(t *testing.T) {
+	tests := []struct {
+		name    string
+		imports string // Everything after the package clause
+		verdict PolicyVerdict
+		reason  string // Fragment of some finding's reason, or of a parse error
+	}{
+		{name: "os/exec", imports: `import "os/exec"`, verdict: VerdictDeny, reason: "spawns arbitrary processes"},
+		{name: "syscall", imports: `import "syscall"`, verdict: VerdictDeny, reason: "raw system calls"},
+		{name: "x/sys below its pattern", imports: `import "golang.org/x/sys/unix"`, verdict: VerdictDeny, reason: "raw system calls"},
+		{name: "unsafe", imports: `import "unsafe"`, verdict: VerdictDeny, reason: "breaks memory safety"},
+		{name: "plugin", imports: `import "plugin"`, verdict: VerdictDeny, reason: "loads unverified code"},
+		{name: "cgo", imports: `import "C"`, verdict: VerdictDeny, reason: "cgo links unverified native code"},
+		{name: "runtime/cgo", imports: `import "runtime/cgo"`, verdict: VerdictDeny, reason: "cgo links unverified native code"},
+		{name: "debug below its pattern", imports: `import "debug/elf"`, verdict: VerdictDeny, reason: "binary introspection"},
+		{name: "reflect", imports: `import "reflect"`, verdict: VerdictReview, reason: "reflection"},
+		{name: "runtime", imports: `import "runtime"`, verdict: VerdictReview, reason: "scheduler and GC"},
+		{name: "runtime/debug", imports: `import "runtime/debug"`, verdict: VerdictReview, reason: "GC limits"},
+		{name: "os", imports: `import "os"`, verdict: VerdictReview, reason: "filesystem and environment"},
+		{name: "os/signal", imports: `import "os/signal"`, verdict: VerdictReview, reason: "shutdown signals"},
+		{name: "net", imports: `import "net"`, verdict: VerdictReview, reason: "network access"},
+		{name: "net below its pattern", imports: `import "net/http"`, verdict: VerdictReview, reason: "network access"},
+		{name: "database/sql", imports: `import "database/sql"`, verdict: VerdictReview, reason: "long-term memory"},
+		{name: "required module with a rule", imports: `import "github.com/mattn/go-sqlite3"`, verdict: VerdictReview, reason: "long-term memory"},
+		{name: "new module with a rule", imports: `import "google.golang.org/genai"`, verdict: VerdictReview, reason: "model budget outside the provider abstraction; module is not required"},
+		{name: "allowed module", imports: `import "github.com/google/uuid"`, verdict: VerdictAllow, reason: "proposal IDs"},
+		{name: "plain stdlib", imports: `import "strings"`, verdict: VerdictAllow},
+		{name: "aliased import", imports: `import run "os/exec"`, verdict: VerdictDeny, reason: "spawns arbitrary processes"},
+		{name: "aliased allowed import", imports: `import str "strings"`, verdict: VerdictAllow},
+		{name: "dot import", imports: `import . "strings"`, verdict: VerdictReview, reason: "dot import hides"},
+		{name: "dot import of a denied package", imports: `import . "syscall"`, verdict: VerdictDeny, reason: "dot import hides"},
+		{name: "blank import", imports: `import _ "embed"`, verdict: VerdictReview, reason: "init side effects"},
+		{name: "stdlib path under another host", imports: `import "example.com/os/exec"`, verdict: VerdictReview, reason: "module is not required by go.mod"},
+		{name: "package of this module", imports: `import "mymodule/internal/x"`, verdict: VerdictReview, reason: "package of this module"},
+		{name: "linkname", imports: "import _ \"unsafe\"\n\n//go:linkname now runtime.nanotime\nfunc now() int64", verdict: VerdictDeny, reason: "linkname reaches unexported symbols"},
+		{name: "unparseable", imports: "import (", verdict: VerdictDeny, reason: "expected"},
+	}
+	policy := NewDependencyPolicy()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := policy.Evaluate(map[string]string{"cap.go": "package main\n\n" + tt.imports + "\n"}, []byte(testGoMod))
+			if got := m.Verdict(); got != tt.verdict {
+				t.Errorf("Verdict() = %s, want %s\n%s", got, tt.verdict, m)
+			}
+			reasons := strings.Join(m.ParseErrors, "\n")
+			for _, f := range m.Findings {
+				reasons += "\n" + f.Reason
+			}
+			if !strings.Contains(reasons, tt.reason) {
+				t.Errorf("no finding mentions %q:\n%s", tt.reason, m)
+			}
+		})
+	}
+}
+
+func TestIsStdlibImport(t *testing.T) {
+	tests := map[string]bool{
+		"fmt":                                 true,
+		"net/http":                            true,
+		"os/exec":                             true,
+		"C":                                   true,
+		"example.com/os/exec":                 false,
+		"github.com/x/os":                     false,
+		"mymodule":                            false,
+		"notapackage":                         false,
+		"cmd/go":                              false,
+		"vendor/golang.org/x/net/http2/hpack": false,
+	}
+	for path, want := range tests {
+		if got := isStdlibImport(path); got != want {
+			t.Errorf("isStdlibImport(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestEvaluateGoModChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(string) string
+		verdict PolicyVerdict // Verdict of the single finding; empty for none
+		reason  string
+	}{
+		{name: "unchanged", edit: func(s string) string { return s }},
+		{
+			name:    "new direct requirement",
+			edit:    func(s string) string { return s + "\nrequire example.com/lib v1.0.0\n" },
+			verdict: VerdictReview,
+			reason:  "new direct requirement v1.0.0",
+		},
+		{
+			name:    "new transitive requirement",
+			edit:    func(s string) string { return s + "\nrequire example.com/lib v1.0.0 // indirect\n" },
+			verdict: VerdictReview,
+			reason:  "new transitive requirement v1.0.0",
+		},
+		{
+			name:    "version bump of a denied module",
+			edit:    func(s string) string { return strings.Replace(s, "x/sys v0.31.0", "x/sys v0.32.0", 1) },
+			verdict: VerdictDeny,
+			reason:  "raw system calls",
+		},
+		{
+			name:    "version bump",
+			edit:    func(s string) string { return strings.Replace(s, "go-sqlite3 v1.14.32", "go-sqlite3 v1.14.33", 1) },
+			verdict: VerdictReview,
+			reason:  "requirement changed from v1.14.32 to v1.14.33; direct access to long-term memory",
+		},
+		{
+			name: "version bump of an allowed module",
+			edit: func(s string) string {
+				return strings.Replace(s, "uuid v1.6.0\n\tgithub.com/mattn", "uuid v1.7.0\n\tgithub.com/mattn", 1)
+			},
+			verdict: VerdictReview,
+			reason:  "requirement changed from v1.6.0 to v1.7.0",
+		},
+		{
+			name: "replace with another module",
+			edit: func(s string) string {
+				return s + "replace github.com/mattn/go-sqlite3 => example.com/sqlite3 v1.0.0\n"
+			},
+			verdict: VerdictReview,
+			reason:  "replace directive swaps the code behind github.com/mattn/go-sqlite3",
+		},
+		{
+			name:    "replace with a directory",
+			edit:    func(s string) string { return s + "replace github.com/mattn/go-sqlite3 => ../sqlite3\n" },
+			verdict: VerdictDeny,
+			reason:  "points at a directory",
+		},
+		{
+			name: "replace with a denied module",
+			edit: func(s string) string {
+				return s + "replace (\n\texample.com/lib v1.0.0 => golang.org/x/sys v0.31.0\n)\n"
+			},
+			verdict: VerdictDeny,
+			reason:  "raw system calls",
+		},
+		{
+			name: "changed replacement",
+			edit: func(s string) string {
+				return strings.Replace(s, "=> github.com/google/uuid v1.6.0", "=> github.com/google/uuid v1.5.0", 1)
+			},
+			verdict: VerdictReview,
+			reason:  "replace directive swaps the code behind github.com/google/uuid",
+		},
+	}
+	policy := NewDependencyPolicy()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := policy.EvaluateGoModChange([]byte(testGoMod), []byte(tt.edit(testGoMod)))
+			if tt.verdict == "" {
+				if len(findings) != 0 {
+					t.Fatalf("EvaluateGoModChange() = %+v, want no findings", findings)
+				}
+				return
+			}
+			if len(findings) != 1 {
+				t.Fatalf("EvaluateGoModChange() = %+v, want one finding", findings)
+			}
+			if f := findings[0]; f.Verdict != tt.verdict || !strings.Contains(f.Reason, tt.reason) {
+				t.Errorf("finding = %s %q, want %s mentioning %q", f.Verdict, f.Reason, tt.verdict, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// dreamHandler shows the dream scheduler, or forces or aborts a cycle.
+func dreamHandler(args string) {
+	switch args {
+	case "":
+		dreamStatus()
+	case "now":
+		if err := dreamScheduler.Force(); err != nil {
+			fmt.Printf("SIE-∞ Error: %v\n", err)
+		}
+	case "abort":
+		if !dreamScheduler.Abort() {
+			fmt.Println("SIE-∞: No dream cycle is running.")
+		}
+	default:
+		fmt.Println("Error: Usage: /dream [now|abort]")
+	}
+}
+
+func dreamStatus() {
+	s, err := dreamScheduler.Status()
+	if err != nil {
+		fmt.Printf("SIE-∞ Warning: pending failures not counted: %v\n", err)
+	}
+	fmt.Println("--- Dream Scheduler ---")
+	if s.Running {
+		fmt.Printf("State: running (%s trigger, %s", s.Trigger, s.Progress.Stage)
+		if s.Progress.Total > 0 {
+			fmt.Printf(" %d/%d", s.Progress.Done, s.Progress.Total)
+		}
+		fmt.Printf(", for %v)\n", time.Since(s.StartedAt).Round(time.Second))
+	} else {
+		fmt.Println("State: waiting")
+	}
+	fmt.Printf("Pending failures: %d", s.Pending)
+	if dreamScheduler.Backlog > 0 {
+		fmt.Printf(" (backlog trigger at %d)", dreamScheduler.Backlog)
+	}
+	fmt.Println()
+	if dreamScheduler.IdleAfter > 0 {
+		fmt.Printf("Idle trigger: after %v without commands (idle for %v)\n", dreamScheduler.IdleAfter, s.IdleFor.Round(time.Second))
+	} else {
+		fmt.Println("Idle trigger: off")
+	}
+	if dreamScheduler.Schedule != nil {
+		next := "never"
+		if !s.NextScheduled.IsZero() {
+			next = s.NextScheduled.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf("Schedule: %s (next %s)\n", dreamScheduler.Schedule, next)
+	} else {
+		fmt.Println("Schedule: off")
+	}
+	if time.Now().Before(s.RetryAt) {
+		fmt.Printf("Idle and backlog triggers held off until %s after the last cycle stopped early.\n", s.RetryAt.Local().Format("15:04:05"))
+	}
+	if s.Last == nil {
+		fmt.Println("Last cycle: none this session")
+		return
+	}
+	fmt.Printf("Last cycle: %s\n", dreamSummary(*s.Last))
+}
+
+// dreamSummary describes how a cycle ended.
+func dreamSummary(r DreamResult) string {
+	when := fmt.Sprintf("%s trigger at %s, %v", r.Trigger, r.StartedAt.Local().Format("2006-01-02 15:04:05"), r.FinishedAt.Sub(r.StartedAt).Round(time.Millisecond))
+	switch {
+	case r.Aborted:
+		return fmt.Sprintf("aborted by the operator (%s); %d rule(s) learned before stopping", when, r.Learned)
+	case errors.Is(r.Err, context.Canceled):
+		return fmt.Sprintf("stopped at shutdown (%s); the pending failures wait for the next cycle", when)
+	case r.Err != nil:
+		return fmt.Sprintf("failed (%s): %v", when, r.Err)
+	}
+	return fmt.Sprintf("%d failure(s) consolidated, %d rule(s) learned, %d strengthened, %d forgotten (%s)",
+		r.Failures, r.Learned, r.Strengthened, r.Forgotten, when)
+}
+
+// reportDreamEvent announces when a cycle starts and how it ended; the
+// progress in between is shown by /dream.
+func reportDreamEvent(e DreamEvent) {
+	switch {
+	case e.Result != nil:
+		fmt.Printf("SIE-∞: Dream cycle %s\n", dreamSummary(*e.Result))
+	case e.Progress.Stage == "starting":
+		fmt.Printf("SIE-∞: Dream cycle started (%s trigger). /dream shows its progress; /dream abort stops it.\n", e.Trigger)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DreamTrigger is why a dream cycle ran.
+type DreamTrigger string
+
+const (
+	TriggerIdle     DreamTrigger = "idle"     // The operator has been inactive for IdleAfter
+	TriggerBacklog  DreamTrigger = "backlog"  // Enough failures are pending
+	TriggerSchedule DreamTrigger = "schedule" // The cron schedule came due
+	TriggerOperator DreamTrigger = "operator" // Forced from the console
+)
+
+// ErrDreamRunning is returned by Force while a cycle is already running.
+var ErrDreamRunning = errors.New("a dream cycle is already running")
+
+// DreamEvent reports a running cycle's progress to the scheduler's callback.
+// Result is set on the last event of each cycle.
+type DreamEvent struct {
+	Trigger  DreamTrigger
+	Progress DreamProgress
+	Result   *DreamResult
+}
+
+// DreamStatus is a snapshot of the scheduler for the console.
+type DreamStatus struct {
+	Running       bool
+	Trigger       DreamTrigger // Of the running cycle
+	StartedAt     time.Time    // Of the running cycle
+	Progress      DreamProgress
+	Last          *DreamResult // Most recent finished cycle; nil before the first
+	Pending       int
+	IdleFor       time.Duration
+	NextScheduled time.Time // Zero without a schedule
+	RetryAt       time.Time // Automatic triggers wait until then after a failed cycle
+}
+
+// dreamRun is the cycle in progress.
+type dreamRun struct {
+	trigger  DreamTrigger
+	started  time.Time
+	progress DreamProgress
+	cancel   context.CancelFunc
+	aborted  bool
+}
+
+// DreamScheduler decides when the MemoryConsolidator dreams: after the
+// operator has been idle, once enough failures are pending, or on a cron
+// schedule, and whenever the operator forces it. Cycles never overlap.
+type DreamScheduler struct {
+	// IdleAfter is the inactivity that triggers a cycle when failures are
+	// pending; 0 disables the trigger.
+	IdleAfter time.Duration
+	// Backlog is the number of pending failures that triggers a cycle; 0
+	// disables the trigger.
+	Backlog int
+	// Schedule triggers a cycle whenever it comes due; nil disables it.
+	Schedule *CronSchedule
+	// CheckEvery is how often the triggers are evaluated.
+	CheckEvery time.Duration
+	// Backoff holds automatic triggers off after a cycle fails or is aborted.
+	Backoff time.Duration
+	// OnEvent, if set, receives every progress event and each cycle's result.
+	// It runs on the cycle's goroutine and must not call back into the
+	// scheduler.
+	OnEvent func(DreamEvent)
+
+	memory        *MemoryConsolidator
+	mutex         sync.Mutex
+	ctx           context.Context
+	wg            sync.WaitGroup
+	wake          chan struct{}
+	lastActivity  time.Time
+	nextScheduled time.Time
+	retryAt       time.Time
+	running       *dreamRun
+	last          *DreamResult
+}
+
+func NewDreamScheduler(memory *MemoryConsolidator) *DreamScheduler {
+	return &DreamScheduler{
+		IdleAfter:    15 * time.Minute,
+		Backlog:      5,
+		CheckEvery:   30 * time.Second,
+		Backoff:      15 * time.Minute,
+		memory:       memory,
+		wake:         make(chan struct{}, 1),
+		lastActivity: time.Now(),
+	}
+}
+
+// Start evaluates the triggers in the background until ctx is done, which
+// also cancels a running cycle.
+func (ds *DreamScheduler) Start(ctx context.Context) {
+	ds.mutex.Lock()
+	ds.ctx = ctx
+	if ds.Schedule != nil {
+		ds.nextScheduled = ds.Schedule.Next(time.Now())
+	}
+	ds.mutex.Unlock()
+
+	ds.wg.Add(1)
+	go func() {
+		defer ds.wg.Done()
+		ticker := time.NewTicker(ds.CheckEvery)
+		defer ticker.Stop()
+		for {
+			if trigger, ok := ds.due(time.Now()); ok {
+				ds.start(trigger)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-ds.wake:
+			}
+		}
+	}()
+}
+
+// Wait blocks until the scheduler and any running cycle have stopped.
+func (ds *DreamScheduler) Wait() {
+	ds.wg.Wait()
+}
+
+// Touch records operator activity, postponing the idle trigger.
+func (ds *DreamScheduler) Touch() {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	ds.lastActivity = time.Now()
+}
+
+// Wake evaluates the triggers now rather than at the next check, e.g. after
+// a failure has been recorded.
+func (ds *DreamScheduler) Wake() {
+	select {
+	case ds.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Force starts a cycle immediately on the operator's behalf.
+func (ds *DreamScheduler) Force() error {
+	return ds.start(TriggerOperator)
+}
+
+// Abort cancels the running cycle and reports whether there was one.
+func (ds *DreamScheduler) Abort() bool {
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	if ds.running == nil {
+		return false
+	}
+	ds.running.aborted = true
+	ds.running.cancel()
+	return true
+}
+
+// Status returns a snapshot of the scheduler.
+func (ds *DreamScheduler) Status() (DreamStatus, error) {
+	pending, err := ds.pending()
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	s := DreamStatus{
+		Last:          ds.last,
+		Pending:       pending,
+		IdleFor:       time.Since(ds.lastActivity),
+		NextScheduled: ds.nextScheduled,
+		RetryAt:       ds.retryAt,
+	}
+	if r := ds.running; r != nil {
+		s.Running, s.Trigger, s.StartedAt, s.Progress = true, r.trigger, r.started, r.progress
+	}
+	return s, err
+}
+
+// pending counts the failures awaiting a cycle.
+func (ds *DreamScheduler) pending() (int, error) {
+	if ds.memory.Store == nil {
+		return 0, nil
+	}
+	return ds.memory.Store.PendingCount()
+}
+
+// due returns the trigger that calls for a cycle at now, if any. The
+// schedule takes precedence and is not held off by the backoff.
+func (ds *DreamScheduler) due(now time.Time) (DreamTrigger, bool) {
+	pending, err := ds.pending()
+	ds.mutex.Lock()
+	defer ds.mutex.Unlock()
+	if ds.running != nil {
+		return "", false
+	}
+	if ds.Schedule != nil && !ds.nextScheduled.IsZero() && !now.Before(ds.nextScheduled) {
+		ds.nextScheduled = ds.Schedule.Next(now)
+		return TriggerSchedule, true
+	}
+	if err != nil || now.Before(ds.retryAt) {
+		return "", false
+	}
+	switch {
+	case ds.Backlog > 0 && pending >= ds.Backlog:
+		return TriggerBacklog, true
+	case ds.IdleAfter > 0 && pending > 0 && now.Sub(ds.lastActivity) >= ds.IdleAfter:
+		return TriggerIdle, true
+	}
+	return "", false
+}
+
+// start runs one cycle in the background unless one is already running.
+func (ds *DreamScheduler) start(trigger DreamTrigger) error {
+	ds.mutex.Lock()
+	if ds.running != nil {
+		ds.mutex.Unlock()
+		return ErrDreamRunning
+	}
+	if ds.ctx == nil {
+		ds.mutex.Unlock()
+		return errors.New("the dream scheduler has not been started")
+	}
+	ctx, cancel := context.WithCancel(ds.ctx)
+	run := &dreamRun{trigger: trigger, started: time.Now(), progress: DreamProgress{Stage: "starting"}, cancel: cancel}
+	ds.running = run
+	ds.wg.Add(1)
+	ds.mutex.Unlock()
+
+	go func() {
+		defer ds.wg.Done()
+		defer cancel()
+		ds.emit(DreamEvent{Trigger: trigger, Progress: run.progress})
+		result, err := ds.memory.DreamCycle(ctx, func(p DreamProgress) {
+			ds.mutex.Lock()
+			run.progress = p
+			ds.mutex.Unlock()
+			ds.emit(DreamEvent{Trigger: trigger, Progress: p})
+		})
+
+		ds.mutex.Lock()
+		result.Trigger = trigger
+		result.StartedAt = run.started
+		result.FinishedAt = time.Now()
+		if err != nil {
+			result.Err = err
+			result.Aborted = run.aborted
+			ds.retryAt = result.FinishedAt.Add(ds.Backoff)
+		}
+		progress := run.progress
+		ds.running = nil
+		ds.last = &result
+		ds.mutex.Unlock()
+		ds.emit(DreamEvent{Trigger: trigger, Progress: progress, Result: &result})
+	}()
+	return nil
+}
+
+func (ds *DreamScheduler) emit(e DreamEvent) {
+	if ds.OnEvent != nil {
+		ds.OnEvent(e)
+	}
+}
+
+// configureDreams applies SIE_DREAM_IDLE (an inactivity such as 15m),
+// SIE_DREAM_BACKLOG (a number of pending failures) and SIE_DREAM_SCHEDULE
+// (a cron expression); 0 or "off" disables a trigger. The schedule
+// defaults to 03:00 every night.
+func configureDreams(ds *DreamScheduler) error {
+	if v := os.Getenv("SIE_DREAM_IDLE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if v == "off" {
+			d, err = 0, nil
+		}
+		if err != nil || d < 0 {
+			return fmt.Errorf("SIE_DREAM_IDLE must be a duration such as 15m, got %q", v)
+		}
+		ds.IdleAfter = d
+	}
+	if v := os.Getenv("SIE_DREAM_BACKLOG"); v != "" {
+		n, err := strconv.Atoi(v)
+		if v == "off" {
+			n, err = 0, nil
+		}
+		if err != nil || n < 0 {
+			return fmt.Errorf("SIE_DREAM_BACKLOG must be a number of failures, got %q", v)
+		}
+		ds.Backlog = n
+	}
+	spec := os.Getenv("SIE_DREAM_SCHEDULE")
+	switch spec {
+	case "off":
+		ds.Schedule = nil
+		return nil
+	case "":
+		spec = "0 3 * * *"
+	}
+	schedule, err := ParseCronSchedule(spec)
+	if err != nil {
+		return fmt.Errorf("SIE_DREAM_SCHEDULE: %v", err)
+	}
+	ds.Schedule = schedule
+	return nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// embeddingMigrations is the schema history of the embedding store.
+var embeddingMigrations = []schemaMigration{
+	{Version: 1, Name: "embedding vectors", Statements: []string{
+		`CREATE TABLE embeddings (
+			kind         TEXT NOT NULL,
+			ref          TEXT NOT NULL,
+			embedder     TEXT NOT NULL,
+			dim          INTEGER NOT NULL,
+			vector       BLOB NOT NULL,
+			text         TEXT NOT NULL,
+			content_hash TEXT NOT NULL,
+			updated_at   TIMESTAMP NOT NULL,
+			PRIMARY KEY (kind, ref, embedder)
+		)`,
+		`CREATE INDEX idx_embeddings_embedder ON embeddings(embedder, kind)`,
+	}},
+}
+
+// Kinds of embedded memory.
+const (
+	MemoryProposal     = "proposal"
+	MemoryRule         = "rule"
+	MemoryConversation = "conversation"
+)
+
+// embeddingBatch is how many texts are sent to the embedder at once.
+const embeddingBatch = 64
+
+// EmbeddingItem is a text to embed, identified by its kind and a reference
+// such as a proposal ID.
+type EmbeddingItem struct {
+	Kind string
+	Ref  string
+	Text string
+}
+
+// MemoryMatch is a stored text close to a query.
+type MemoryMatch struct {
+	Kind       string
+	Ref        string
+	Text       string
+	Similarity float64
+}
+
+// EmbeddingStore persists embedding vectors in SQLite and finds the nearest
+// ones by cosine similarity. Only vectors from the current embedder are
+// searched, so switching embedders re-embeds rather than mixing spaces.
+type EmbeddingStore struct {
+	db       *sql.DB
+	embedder Embedder
+	MinScore float64 // Matches below this similarity are dropped
+}
+
+// NewEmbeddingStore migrates the embedding schema to the latest version.
+func NewEmbeddingStore(db *sql.DB, embedder Embedder) (*EmbeddingStore, error) {
+	if err := applyMigrations(db, "embeddings", embeddingMigrations); err != nil {
+		return nil, fmt.Errorf("failed to migrate embedding store: %v", err)
+	}
+	return &EmbeddingStore{db: db, embedder: embedder, MinScore: 0.1}, nil
+}
+
+// Embedder is the embedder whose vectors the store searches.
+func (es *EmbeddingStore) Embedder() Embedder { return es.embedder }
+
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Index embeds the items whose text is new or changed since they were last
+// embedded, and returns how many it embedded.
+func (es *EmbeddingStore) Index(ctx context.Context, items []EmbeddingItem) (int, error) {
+	name := es.embedder.Name()
+	var stale []EmbeddingItem
+	for _, item := range items {
+		var hash string
+		err := es.db.QueryRow(`SELECT content_hash FROM embeddings WHERE kind = ? AND ref = ? AND embedder = ?`,
+			item.Kind, item.Ref, name).Scan(&hash)
+		switch {
+		case err == sql.ErrNoRows:
+		case err != nil:
+			return 0, fmt.Errorf("failed to read embedding of %s %s: %v", item.Kind, item.Ref, err)
+		case hash == contentHash(item.Text):
+			continue
+		}
+		stale = append(stale, item)
+	}
+
+	for start := 0; start < len(stale); start += embeddingBatch {
+		batch := stale[start:min(start+embeddingBatch, len(stale))]
+		texts := make([]string, len(batch))
+		for i, item := range batch {
+			texts[i] = item.Text
+		}
+		vectors, err := es.embedder.Embed(ctx, texts)
+		if err != nil {
+			return start, fmt.Errorf("%s could not embed: %v", name, err)
+		}
+		if len(vectors) != len(batch) {
+			return start, fmt.Errorf("%s returned %d vectors for %d texts", name, len(vectors), len(batch))
+		}
+		tx, err := es.db.Begin()
+		if err != nil {
+			return start, err
+		}
+		now := time.Now().UTC()
+		for i, item := range batch {
+			_, err := tx.Exec(`INSERT INTO embeddings (kind, ref, embedder, dim, vector, text, content_hash, updated_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT (kind, ref, embedder) DO UPDATE SET dim = excluded.dim, vector = excluded.vector,
+					text = excluded.text, content_hash = excluded.content_hash, updated_at = excluded.updated_at`,
+				item.Kind, item.Ref, name, len(vectors[i]), encodeVector(vectors[i]), item.Text, contentHash(item.Text), now)
+			if err != nil {
+				tx.Rollback()
+				return start, fmt.Errorf("failed to store embedding of %s %s: %v", item.Kind, item.Ref, err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return start, err
+		}
+	}
+	return len(stale), nil
+}
+
+// Prune deletes the embeddings of kind whose reference is not in keep, for
+// every embedder.
+func (es *EmbeddingStore) Prune(kind string, keep []string) error {
+	clause := `DELETE FROM embeddings WHERE kind = ?`
+	args := []any{kind}
+	if len(keep) > 0 {
+		clause += ` AND ref NOT IN (?` + strings.Repeat(", ?", len(keep)-1) + `)`
+		for _, ref := range keep {
+			args = append(args, ref)
+		}
+	}
+	if _, err := es.db.Exec(clause, args...); err != nil {
+		return fmt.Errorf("failed to prune %s embeddings: %v", kind, err)
+	}
+	return nil
+}
+
+// Search returns up to k stored texts of the given kinds (all kinds if none)
+// nearest to query, most similar first, leaving out the reference exclude.
+func (es *EmbeddingStore) Search(ctx context.Context, query string, k int, exclude string, kinds ...string) ([]MemoryMatch, error) {
+	vectors, err := es.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("%s could not embed the query: %v", es.embedder.Name(), err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("%s returned %d vectors for the query", es.embedder.Name(), len(vectors))
+	}
+	q := vectors[0]
+
+	clause := `SELECT kind, ref, dim, vector, text FROM embeddings WHERE embedder = ? AND ref != ?`
+	args := []any{es.embedder.Name(), exclude}
+	if len(kinds) > 0 {
+		clause += ` AND kind IN (?` + strings.Repeat(", ?", len(kinds)-1) + `)`
+		for _, kind := range kinds {
+			args = append(args, kind)
+		}
+	}
+	rows, err := es.db.Query(clause, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embeddings: %v", err)
+	}
+	defer rows.Close()
+
+	var matches []MemoryMatch
+	for rows.Next() {
+		var m MemoryMatch
+		var dim int
+		var blob []byte
+		if err := rows.Scan(&m.Kind, &m.Ref, &dim, &blob, &m.Text); err != nil {
+			return nil, err
+		}
+		if dim != len(q) || len(blob) != 4*dim {
+			continue
+		}
+		m.Similarity = cosine(q, decodeVector(blob))
+		if m.Similarity >= es.MinScore {
+			matches = append(matches, m)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Similarity > matches[j].Similarity })
+	if k > 0 && len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
+
+// Count returns how many vectors the current embedder has stored per kind.
+func (es *EmbeddingStore) Count() (map[string]int, error) {
+	rows, err := es.db.Query(`SELECT kind, COUNT(*) FROM embeddings WHERE embedder = ? GROUP BY kind`, es.embedder.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to count embeddings: %v", err)
+	}
+	defer rows.Close()
+	counts := make(map[string]int)
+	for rows.Next() {
+		var kind string
+		var n int
+		if err := rows.Scan(&kind, &n); err != nil {
+			return nil, err
+		}
+		counts[kind] = n
+	}
+	return counts, rows.Err()
+}
+
+// encodeVector stores a vector as little-endian float32s.
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) []float32 {
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return v
+}
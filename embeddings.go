@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Embedder turns texts into vectors whose cosine similarity reflects how
+// related the texts are.
+type Embedder interface {
+	// Name identifies the embedding space; vectors from different
+	// embedders are never compared.
+	Name() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// EmbeddingProvider is implemented by model providers whose backend can
+// embed text.
+type EmbeddingProvider interface {
+	EmbeddingModel() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// providerEmbedder embeds through a model provider's backend.
+type providerEmbedder struct {
+	name     string
+	provider EmbeddingProvider
+}
+
+func (e providerEmbedder) Name() string { return e.name }
+
+func (e providerEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.provider.Embed(ctx, texts)
+}
+
+// NewEmbedder picks the embedder named by kind: "provider" embeds through
+// the model provider, "hash" uses the offline HashingEmbedder, and ""
+// prefers the provider when its backend can embed. The fake provider and
+// cassettes cannot, so offline runs use the hashing embedder.
+func NewEmbedder(provider ModelProvider, kind string) (Embedder, error) {
+	ep, ok := provider.(EmbeddingProvider)
+	if kind == "" {
+		kind = "hash"
+		if ok {
+			kind = "provider"
+		}
+	}
+	switch kind {
+	case "provider":
+		if !ok {
+			return nil, fmt.Errorf("model provider %s cannot embed text", provider.Name())
+		}
+		backend, _, _ := strings.Cut(provider.Name(), "/")
+		return providerEmbedder{name: backend + "/" + ep.EmbeddingModel(), provider: ep}, nil
+	case "hash":
+		return NewHashingEmbedder(hashingDimensions), nil
+	}
+	return nil, fmt.Errorf("unknown embedder %q (want provider or hash)", kind)
+}
+
+// hashingDimensions is the size of the hashing embedder's vectors.
+const hashingDimensions = 512
+
+// HashingEmbedder embeds text offline by hashing its words and word pairs
+// into a fixed number of signed buckets, weighted by log term frequency.
+// Texts sharing vocabulary end up close; it knows nothing of synonyms.
+type HashingEmbedder struct {
+	dims int
+}
+
+func NewHashingEmbedder(dims int) *HashingEmbedder {
+	return &HashingEmbedder{dims: dims}
+}
+
+func (h *HashingEmbedder) Name() string { return fmt.Sprintf("hash-%d", h.dims) }
+
+func (h *HashingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		counts := make(map[string]float64)
+		words := embeddingTokens(text)
+		for j, w := range words {
+			counts[w]++
+			if j > 0 {
+				counts[words[j-1]+" "+w] += 0.5
+			}
+		}
+		vec := make([]float32, h.dims)
+		for feature, n := range counts {
+			f := fnv.New64a()
+			f.Write([]byte(feature))
+			sum := f.Sum64()
+			weight := float32(1 + math.Log(n))
+			if sum>>63 == 1 {
+				weight = -weight
+			}
+			vec[sum%uint64(h.dims)] += weight
+		}
+		out[i] = normalise(vec)
+	}
+	return out, nil
+}
+
+// embeddingStopWords carry no meaning of their own. Words are matched
+// before stemming.
+var embeddingStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "that": true, "this": true, "from": true,
+	"into": true, "are": true, "was": true, "its": true, "it": true, "of": true, "to": true,
+	"in": true, "on": true, "is": true, "be": true, "an": true, "or": true, "by": true, "as": true,
+	// Labels of the embedded memory texts, shared by every text of a kind.
+	"request": true, "file": true, "rationale": true, "outcome": true, "operator": true, "sie": true,
+}
+
+// embeddingTokens lowercases and stems text into words, splitting
+// identifiers such as ReverseString and reverse_string into their parts.
+func embeddingTokens(text string) []string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if w := strings.ToLower(string(word)); len(w) > 1 && !embeddingStopWords[w] {
+			words = append(words, stem(w))
+		}
+		word = word[:0]
+	}
+	var prev rune
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if unicode.IsUpper(r) && unicode.IsLower(prev) {
+				flush()
+			}
+			word = append(word, r)
+		default:
+			flush()
+		}
+		prev = r
+	}
+	flush()
+	return words
+}
+
+// stem strips one common English suffix, so that reverse, reverses,
+// reversed and reversing share a feature.
+func stem(w string) string {
+	if len(w) <= 4 {
+		return w
+	}
+	for _, suffix := range []string{"ing", "ed", "es", "s", "e"} {
+		if strings.HasSuffix(w, suffix) && !strings.HasSuffix(w, "ss") {
+			return strings.TrimSuffix(w, suffix)
+		}
+	}
+	return w
+}
+
+// normalise scales v to unit length, leaving a zero vector as it is.
+func normalise(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
+
+// cosine is the cosine similarity of two vectors of equal length.
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
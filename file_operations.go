@@ -0,0 +1,434 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FileOpKind is what a file operation does to its path.
+type FileOpKind string
+
+const (
+	FileCreate FileOpKind = "create"
+	FileModify FileOpKind = "modify"
+	FileDelete FileOpKind = "delete"
+)
+
+// FileOperation is one change a proposal makes to the module beyond its own
+// new file, taken from the patch section of the model's response.
+type FileOperation struct {
+	Kind    FileOpKind `json:"kind"`
+	Path    string     `json:"path"`              // Slash-separated, relative to the module root
+	Content string     `json:"content,omitempty"` // Full content of a created file
+	Diff    string     `json:"diff,omitempty"`    // Unified diff hunks of a modified or deleted file
+}
+
+// diffHunk is one @@ section of a unified diff. Lines keep their ' ', '-'
+// or '+' prefix.
+type diffHunk struct {
+	OldStart int
+	Header   string
+	Lines    []string
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@`)
+
+// gitNoise matches the extended header lines git writes around file headers.
+var gitNoise = regexp.MustCompile(`^(diff --git |index |new file mode |deleted file mode |old mode |new mode |similarity index |Binary files )`)
+
+// ParsePatch splits a multi-file unified diff, as written by git diff, into
+// file operations. A file whose old side is /dev/null is created and one
+// whose new side is /dev/null is deleted; renames are not supported.
+func ParsePatch(patch string) ([]FileOperation, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	isHeader := func(i int) bool {
+		return strings.HasPrefix(lines[i], "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+	}
+
+	var ops []FileOperation
+	seen := make(map[string]bool)
+	for i := 0; i < len(lines); {
+		if !isHeader(i) {
+			if strings.TrimSpace(lines[i]) != "" && !gitNoise.MatchString(lines[i]) {
+				return nil, fmt.Errorf("patch line %d: expected a --- file header, found %q", i+1, lines[i])
+			}
+			i++
+			continue
+		}
+		oldPath, newPath := patchPath(lines[i][4:]), patchPath(lines[i+1][4:])
+		start := i + 2
+		for i = start; i < len(lines) && !isHeader(i); i++ {
+		}
+		var body []string
+		for _, line := range lines[start:i] {
+			if !gitNoise.MatchString(line) {
+				body = append(body, line)
+			}
+		}
+		diff := strings.Join(body, "\n")
+
+		op := FileOperation{Kind: FileModify, Path: newPath, Diff: diff}
+		switch {
+		case oldPath == "" && newPath == "":
+			return nil, fmt.Errorf("patch line %d: both sides are /dev/null", start-1)
+		case oldPath == "":
+			op.Kind, op.Diff = FileCreate, ""
+		case newPath == "":
+			op.Kind, op.Path = FileDelete, oldPath
+		case oldPath != newPath:
+			return nil, fmt.Errorf("patch renames %s to %s; renames are not supported, delete and create instead", oldPath, newPath)
+		}
+
+		clean, err := validateOperationPath(op.Path)
+		if err != nil {
+			return nil, err
+		}
+		op.Path = clean
+		if seen[op.Path] {
+			return nil, fmt.Errorf("patch changes %s more than once", op.Path)
+		}
+		seen[op.Path] = true
+
+		hunks, err := parseHunks(diff)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", op.Path, err)
+		}
+		switch op.Kind {
+		case FileCreate:
+			var content []string
+			for _, h := range hunks {
+				for _, line := range h.Lines {
+					if line[0] != '+' {
+						return nil, fmt.Errorf("%s: a created file may only have added lines", op.Path)
+					}
+					content = append(content, line[1:])
+				}
+			}
+			if len(content) == 0 {
+				return nil, fmt.Errorf("%s: created file is empty", op.Path)
+			}
+			op.Content = joinLines(content)
+		case FileModify:
+			if len(hunks) == 0 {
+				return nil, fmt.Errorf("%s: diff has no hunks", op.Path)
+			}
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// patchPath strips the a/ or b/ prefix and any timestamp from a file header
+// path, returning "" for /dev/null.
+func patchPath(s string) string {
+	if tab := strings.IndexByte(s, '\t'); tab >= 0 {
+		s = s[:tab]
+	}
+	s = strings.TrimSpace(s)
+	if s == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/") {
+		s = s[2:]
+	}
+	return s
+}
+
+// validateOperationPath cleans a path from a patch and refuses anything
+// outside the module, hidden, or not Go source or module metadata.
+func validateOperationPath(p string) (string, error) {
+	if p == "" || strings.Contains(p, `\`) || path.IsAbs(p) {
+		return "", fmt.Errorf("patch path %q must be relative to the module root", p)
+	}
+	clean := path.Clean(p)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("patch path %q leaves the module", p)
+	}
+	for _, part := range strings.Split(clean, "/") {
+		if strings.HasPrefix(part, ".") {
+			return "", fmt.Errorf("patch path %q is hidden", p)
+		}
+	}
+	if !strings.HasSuffix(clean, ".go") && clean != "go.mod" && clean != "go.sum" {
+		return "", fmt.Errorf("patch path %q is not a Go source file, go.mod or go.sum", p)
+	}
+	return clean, nil
+}
+
+// parseHunks parses the hunks of a single file's unified diff. The line
+// counts in hunk headers are ignored, since models often get them wrong; a
+// hunk runs until the next header. Blank lines count as blank context lines,
+// except at the end of the diff.
+func parseHunks(diff string) ([]diffHunk, error) {
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	var hunks []diffHunk
+	for n, line := range lines {
+		if m := hunkHeader.FindStringSubmatch(line); m != nil {
+			start, _ := strconv.Atoi(m[1])
+			hunks = append(hunks, diffHunk{OldStart: start, Header: m[0]})
+			continue
+		}
+		if line == "" && len(hunks) == 0 {
+			continue
+		}
+		if len(hunks) == 0 {
+			return nil, fmt.Errorf("diff line %d: expected an @@ hunk header, found %q", n+1, line)
+		}
+		h := &hunks[len(hunks)-1]
+		switch {
+		case line == "":
+			h.Lines = append(h.Lines, " ")
+		case line[0] == ' ' || line[0] == '-' || line[0] == '+':
+			h.Lines = append(h.Lines, line)
+		case line[0] == '\\':
+			// "\ No newline at end of file"; files are always written with one.
+		default:
+			return nil, fmt.Errorf("diff line %d: %q is not a context, removed or added line", n+1, line)
+		}
+	}
+	for _, h := range hunks {
+		if len(h.Lines) == 0 {
+			return nil, fmt.Errorf("hunk %s is empty", h.Header)
+		}
+	}
+	return hunks, nil
+}
+
+// sides returns the lines a hunk expects to find and the lines it leaves.
+func (h diffHunk) sides() (old, replacement []string) {
+	for _, line := range h.Lines {
+		switch line[0] {
+		case ' ':
+			old, replacement = append(old, line[1:]), append(replacement, line[1:])
+		case '-':
+			old = append(old, line[1:])
+		case '+':
+			replacement = append(replacement, line[1:])
+		}
+	}
+	return old, replacement
+}
+
+// ApplyUnifiedDiff applies the hunks of diff to original. Every context and
+// removed line must match the current text, ignoring trailing whitespace. A
+// hunk that moved because the file changed since the diff was written is
+// found by searching outwards from where its header says it starts. A hunk
+// that matches nowhere is a conflict, reported with the first line that
+// differs at its expected position.
+func ApplyUnifiedDiff(original, diff string) (string, error) {
+	hunks, err := parseHunks(diff)
+	if err != nil {
+		return "", err
+	}
+	lines := splitLines(original)
+
+	var out []string
+	next, offset := 0, 0
+	for i, h := range hunks {
+		old, replacement := h.sides()
+		want := h.OldStart - 1
+		if len(old) == 0 {
+			want = h.OldStart // a pure insertion goes after line OldStart
+		}
+		if want < 0 {
+			want = 0
+		}
+		pos, ok := findHunk(lines, old, want+offset, next)
+		if !ok {
+			return "", fmt.Errorf("hunk %d (%s) does not apply: %s", i+1, h.Header, hunkMismatch(lines, old, want+offset))
+		}
+		out = append(out, lines[next:pos]...)
+		out = append(out, replacement...)
+		next = pos + len(old)
+		offset = pos - want
+	}
+	out = append(out, lines[next:]...)
+	return joinLines(out), nil
+}
+
+// findHunk returns the position nearest want, and not before min, at which
+// lines holds old.
+func findHunk(lines, old []string, want, min int) (int, bool) {
+	last := len(lines) - len(old)
+	if want < min {
+		want = min
+	}
+	if want > last {
+		want = last
+	}
+	for d := 0; want-d >= min || want+d <= last; d++ {
+		for _, pos := range []int{want - d, want + d} {
+			if pos >= min && pos <= last && linesMatch(lines[pos:pos+len(old)], old) {
+				return pos, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func linesMatch(have, want []string) bool {
+	for i := range want {
+		if strings.TrimRight(have[i], " \t") != strings.TrimRight(want[i], " \t") {
+			return false
+		}
+	}
+	return true
+}
+
+// hunkMismatch describes why old does not match lines at pos.
+func hunkMismatch(lines, old []string, pos int) string {
+	for i, want := range old {
+		if pos+i >= len(lines) {
+			return fmt.Sprintf("file ends at line %d, expected %q", len(lines), want)
+		}
+		if have := lines[pos+i]; strings.TrimRight(have, " \t") != strings.TrimRight(want, " \t") {
+			return fmt.Sprintf("line %d is %q, expected %q", pos+i+1, have, want)
+		}
+	}
+	return "it overlaps an earlier hunk"
+}
+
+// splitLines splits text into lines without their terminators.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// joinLines is the inverse of splitLines, ending every line with a newline.
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// ProposalChange is what a proposal does to a module tree: the current and
+// the proposed content of every file it touches. An empty Before entry means
+// the file does not exist yet; an empty After entry deletes it.
+type ProposalChange struct {
+	Before      map[string]string
+	After       map[string]string
+	Integration *ServerIntegration
+}
+
+// Paths returns the touched files in sorted order.
+func (c *ProposalChange) Paths() []string {
+	paths := make([]string, 0, len(c.After))
+	for p := range c.After {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// proposalChange works out the change p makes to the module in dir: its new
+// file and tests, its file operations in order, and the server.go
+// integration of its snippet. Creating a file that exists, changing one that
+// does not, or a diff that does not apply is a conflict with the current
+// tree and is returned as an error.
+func proposalChange(dir string, p *Proposal) (*ProposalChange, error) {
+	fileName, testFileName, err := proposalFileNames(p.TargetFileName)
+	if err != nil {
+		return nil, err
+	}
+	c := &ProposalChange{Before: make(map[string]string), After: make(map[string]string)}
+
+	// current returns the content of name as the operations so far leave it.
+	current := func(name string) (string, error) {
+		if content, ok := c.After[name]; ok {
+			return content, nil
+		}
+		if content, ok := c.Before[name]; ok {
+			return content, nil
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if os.IsNotExist(err) {
+			c.Before[name] = ""
+			return "", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %v", name, err)
+		}
+		c.Before[name] = string(data)
+		return string(data), nil
+	}
+	create := func(name, content string) error {
+		existing, err := current(name)
+		if err != nil {
+			return err
+		}
+		if existing != "" {
+			return fmt.Errorf("conflict: %s already exists; change it with a diff instead", name)
+		}
+		c.After[name] = content
+		return nil
+	}
+
+	if err := create(fileName, p.NewFileContent); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(p.TestSuite) != "" {
+		if err := create(testFileName, p.TestSuite); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, op := range p.Operations {
+		if op.Path == fileName || op.Path == testFileName {
+			return nil, fmt.Errorf("conflict: %s is already the proposal's own file", op.Path)
+		}
+		if op.Kind == FileCreate {
+			if err := create(op.Path, op.Content); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		existing, err := current(op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if existing == "" {
+			return nil, fmt.Errorf("conflict: cannot %s %s, it does not exist", op.Kind, op.Path)
+		}
+		switch op.Kind {
+		case FileModify:
+			updated, err := ApplyUnifiedDiff(existing, op.Diff)
+			if err != nil {
+				return nil, fmt.Errorf("conflict in %s: %v", op.Path, err)
+			}
+			c.After[op.Path] = updated
+		case FileDelete:
+			// A deletion diff lists the lines it removes; check they are still
+			// what the file holds.
+			if strings.TrimSpace(op.Diff) != "" {
+				if rest, err := ApplyUnifiedDiff(existing, op.Diff); err != nil || strings.TrimSpace(rest) != "" {
+					return nil, fmt.Errorf("conflict: %s has changed since the deletion was proposed", op.Path)
+				}
+			}
+			c.After[op.Path] = ""
+		default:
+			return nil, fmt.Errorf("unknown file operation %q on %s", op.Kind, op.Path)
+		}
+	}
+
+	integration, err := integrateServerMod(dir, p, c.After)
+	if err != nil {
+		return nil, fmt.Errorf("server.go integration failed: %v", err)
+	}
+	if integration != nil {
+		if _, err := current(serverFile); err != nil {
+			return nil, err
+		}
+		c.After[serverFile] = integration.Source
+		c.Integration = integration
+	}
+	return c, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyUnifiedDiff(t *testing.T) {
+	const original = "package main\n\nimport \"fmt\"\n\nfunc a() {\n\tfmt.Println(\"a\")\n}\n\nfunc b() {\n\tfmt.Println(\"b\")\n}\n"
+	tests := []struct {
+		name     string
+		original string
+		diff     string
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "in place",
+			original: original,
+			diff:     "@@ -5,3 +5,3 @@\n func a() {\n-\tfmt.Println(\"a\")\n+\tfmt.Println(\"A\")\n }\n",
+			want:     strings.Replace(original, `"a"`, `"A"`, 1),
+		},
+		{
+			name:     "moved by lines added above",
+			original: strings.Replace(original, "import \"fmt\"\n", "import \"fmt\"\n\n// one\n// two\n", 1),
+			diff:     "@@ -9,3 +9,3 @@\n func b() {\n-\tfmt.Println(\"b\")\n+\tfmt.Println(\"B\")\n }\n",
+			want:     strings.Replace(strings.Replace(original, "import \"fmt\"\n", "import \"fmt\"\n\n// one\n// two\n", 1), `"b"`, `"B"`, 1),
+		},
+		{
+			name:     "trailing whitespace ignored",
+			original: strings.Replace(original, "func a() {\n", "func a() { \t\n", 1),
+			diff:     "@@ -5,2 +5,2 @@\n func a() {\n-\tfmt.Println(\"a\")\n+\tfmt.Println(\"A\")\n",
+			// Context lines are written as the diff has them.
+			want: strings.Replace(original, `"a"`, `"A"`, 1),
+		},
+		{
+			name:     "pure insertion",
+			original: original,
+			diff:     "@@ -1,0 +2,1 @@\n+// Package main is a test.\n",
+			want:     strings.Replace(original, "package main\n", "package main\n// Package main is a test.\n", 1),
+		},
+		{
+			name:     "two hunks",
+			original: original,
+			diff: "@@ -6,1 +6,1 @@\n-\tfmt.Println(\"a\")\n+\tfmt.Println(\"A\")\n" +
+				"@@ -10,1 +10,1 @@\n-\tfmt.Println(\"b\")\n+\tfmt.Println(\"B\")\n",
+			want: strings.Replace(strings.Replace(original, `"a"`, `"A"`, 1), `"b"`, `"B"`, 1),
+		},
+		{
+			name:     "conflict",
+			original: original,
+			diff:     "@@ -6,1 +6,1 @@\n-\tfmt.Println(\"c\")\n+\tfmt.Println(\"C\")\n",
+			wantErr:  `hunk 1 (@@ -6,1 +6,1 @@) does not apply: line 6 is "\tfmt.Println(\"a\")"`,
+		},
+		{
+			name:     "hunks out of order",
+			original: original,
+			diff: "@@ -10,1 +10,1 @@\n-\tfmt.Println(\"b\")\n+\tfmt.Println(\"B\")\n" +
+				"@@ -6,1 +6,1 @@\n-\tfmt.Println(\"a\")\n+\tfmt.Println(\"A\")\n",
+			wantErr: "hunk 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyUnifiedDiff(tt.original, tt.diff)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ApplyUnifiedDiff() error = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyUnifiedDiff() = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ApplyUnifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		patch   string
+		want    []FileOperation
+		wantErr string
+	}{
+		{
+			name:  "modify, create and delete",
+			patch: "diff --git a/a.go b/a.go\nindex 1..2 100644\n--- a/a.go\n+++ b/a.go\n@@ -1,1 +1,1 @@\n-x\n+y\n--- /dev/null\n+++ b/b.go\n@@ -0,0 +1,1 @@\n+package main\n--- a/c.go\n+++ /dev/null\n@@ -1,1 +0,0 @@\n-package main\n",
+			want: []FileOperation{
+				{Kind: FileModify, Path: "a.go", Diff: "@@ -1,1 +1,1 @@\n-x\n+y"},
+				{Kind: FileCreate, Path: "b.go", Content: "package main\n"},
+				{Kind: FileDelete, Path: "c.go", Diff: "@@ -1,1 +0,0 @@\n-package main\n"},
+			},
+		},
+		{name: "rename", patch: "--- a/a.go\n+++ b/b.go\n@@ -1,1 +1,1 @@\n-x\n+y\n", wantErr: "renames are not supported"},
+		{name: "same file twice", patch: "--- a/a.go\n+++ b/a.go\n@@ -1,1 +1,1 @@\n-x\n+y\n--- a/a.go\n+++ b/a.go\n@@ -2,1 +2,1 @@\n-x\n+y\n", wantErr: "changes a.go more than once"},
+		{name: "escapes the module", patch: "--- a/../x.go\n+++ b/../x.go\n@@ -1,1 +1,1 @@\n-x\n+y\n", wantErr: "x.go"},
+		{name: "prose", patch: "change a.go", wantErr: "expected a --- file header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops, err := ParsePatch(tt.patch)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParsePatch() error = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePatch() = %v", err)
+			}
+			if len(ops) != len(tt.want) {
+				t.Fatalf("ParsePatch() = %+v, want %+v", ops, tt.want)
+			}
+			for i := range ops {
+				if ops[i].Kind != tt.want[i].Kind || ops[i].Path != tt.want[i].Path || ops[i].Content != tt.want[i].Content ||
+					strings.TrimSpace(ops[i].Diff) != strings.TrimSpace(tt.want[i].Diff) {
+					t.Errorf("operation %d = %+v, want %+v", i, ops[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
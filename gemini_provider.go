@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// GeminiProvider talks to the Gemini API through the genai SDK.
+type GeminiProvider struct {
+	client *genai.Client
+	model  string
+	apiKey string
+	// embeddingModel is used by Embed, which calls the REST API directly
+	// because the SDK has no embedding support.
+	embeddingModel string
+}
+
+// NewGeminiProvider creates a Gemini-backed ModelProvider.
+func NewGeminiProvider(ctx context.Context, apiKey, model string) (*GeminiProvider, error) {
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:  apiKey,
+		Backend: genai.BackendGeminiAPI,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Gemini client: %v", err)
+	}
+	return &GeminiProvider{client: client, model: model, apiKey: apiKey}, nil
+}
+
+func (g *GeminiProvider) Name() string { return "gemini/" + g.model }
+
+func (g *GeminiProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return g.generate(ctx, genai.Text(prompt))
+}
+
+func (g *GeminiProvider) Chat(ctx context.Context, history []ChatMessage, message string) (string, error) {
+	contents := make([]*genai.Content, 0, len(history)+1)
+	for _, m := range history {
+		if m.Role == "model" {
+			contents = append(contents, genai.NewModelContentFromText(m.Text))
+		} else {
+			contents = append(contents, genai.NewUserContentFromText(m.Text))
+		}
+	}
+	contents = append(contents, genai.NewUserContentFromText(message))
+	return g.generate(ctx, contents)
+}
+
+func (g *GeminiProvider) Multimodal(ctx context.Context, prompt string, media []MediaPart) (string, error) {
+	parts := []*genai.Part{genai.NewPartFromText(prompt)}
+	for _, m := range media {
+		parts = append(parts, genai.NewPartFromBytes(m.Data, m.MIMEType))
+	}
+	return g.generate(ctx, []*genai.Content{genai.NewUserContentFromParts(parts)})
+}
+
+func (g *GeminiProvider) CountTokens(ctx context.Context, text string) (int, error) {
+	resp, err := g.client.Models.CountTokens(ctx, g.model, genai.Text(text), nil)
+	if err != nil {
+		return 0, err
+	}
+	return int(resp.TotalTokens), nil
+}
+
+func (g *GeminiProvider) generate(ctx context.Context, contents []*genai.Content) (string, error) {
+	resp, err := g.client.Models.GenerateContent(ctx, g.model, contents, nil)
+	if err != nil {
+		return "", err
+	}
+	return extractText(resp), nil
+}
+
+// extractText is a helper function to pull the text content from a Gemini response.
+func extractText(resp *genai.GenerateContentResponse) string {
+	if resp == nil {
+		return ""
+	}
+	text, err := resp.Text()
+	if err != nil {
+		return ""
+	}
+	return text
+}
+
+// geminiEmbedEndpoint is the REST endpoint for batch embeddings.
+const geminiEmbedEndpoint = "https://generativelanguage.googleapis.com/v1beta/models/%s:batchEmbedContents?key=%s"
+
+type geminiEmbedRequest struct {
+	Model   string `json:"model"`
+	Content struct {
+		Parts []struct {
+			Text string `json:"text"`
+		} `json:"parts"`
+	} `json:"content"`
+}
+
+type geminiEmbedResponse struct {
+	Embeddings []struct {
+		Values []float32 `json:"values"`
+	} `json:"embeddings"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func (g *GeminiProvider) EmbeddingModel() string { return g.embeddingModel }
+
+// Embed calls the batchEmbedContents endpoint.
+func (g *GeminiProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if g.embeddingModel == "" {
+		return nil, fmt.Errorf("no embedding model configured")
+	}
+	requests := make([]geminiEmbedRequest, len(texts))
+	for i, text := range texts {
+		requests[i].Model = "models/" + g.embeddingModel
+		requests[i].Content.Parts = []struct {
+			Text string `json:"text"`
+		}{{Text: text}}
+	}
+	body, err := json.Marshal(map[string]any{"requests": requests})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf(geminiEmbedEndpoint, url.PathEscape(g.embeddingModel), url.QueryEscape(g.apiKey))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := (&http.Client{Timeout: 60 * time.Second}).Do(req)
+	if err != nil {
+		// The URL carries the key, so do not let the error repeat it.
+		return nil, fmt.Errorf("gemini embedding request failed")
+	}
+	defer resp.Body.Close()
+
+	var out geminiEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode gemini embedding response (status %d): %v", resp.StatusCode, err)
+	}
+	if out.Error != nil {
+		return nil, fmt.Errorf("gemini error (status %d): %s", resp.StatusCode, out.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK || len(out.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("gemini returned status %d with %d of %d embeddings", resp.StatusCode, len(out.Embeddings), len(texts))
+	}
+	vectors := make([][]float32, len(texts))
+	for i, e := range out.Embeddings {
+		vectors[i] = e.Values
+	}
+	return vectors, nil
+}
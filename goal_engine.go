@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"math/rand"
 	"time"
 )
@@ -13,9 +14,41 @@ type PrimeAxiom struct {
 	KnowledgeIntegrationScore float64
 }
 
+// ImplementationStats measures how costly self-modification has been so far.
+type ImplementationStats struct {
+	Merged                int
+	TotalImplementSeconds float64 // Sum of 𝒯_impl, repair rounds included
+	TotalRepairIterations int
+}
+
+// MeanTimeToImplement is the average 𝒯_impl of merged proposals, in seconds.
+func (s ImplementationStats) MeanTimeToImplement() float64 {
+	if s.Merged == 0 {
+		return 0
+	}
+	return s.TotalImplementSeconds / float64(s.Merged)
+}
+
+// MeanRepairIterations is the average number of repair rounds per merge.
+func (s ImplementationStats) MeanRepairIterations() float64 {
+	if s.Merged == 0 {
+		return 0
+	}
+	return float64(s.TotalRepairIterations) / float64(s.Merged)
+}
+
 // GoalEngine manages the AI's intrinsic motivation.
 type GoalEngine struct {
-	CurrentAxiom PrimeAxiom
+	CurrentAxiom   PrimeAxiom
+	Implementation ImplementationStats
+	Compression    CompressionReport // Measurement behind CompressionEfficiency
+	Knowledge      GraphMetrics      // Measurement behind KnowledgeIntegrationScore
+
+	// corpus gathers the data CompressionEfficiency is measured on; without
+	// one the initial value stands.
+	corpus    func() (CompressionCorpus, error)
+	knowledge *KnowledgeGraph
+	history   *AxiomHistory
 }
 
 func NewGoalEngine() *GoalEngine {
@@ -28,41 +61,134 @@ func NewGoalEngine() *GoalEngine {
 	}
 }
 
-// CalculateCurrentMetrics simulates the calculation of the prime axiom metrics.
-// In a real implementation, this would involve complex analysis of the AI's internal state.
-func (ge *GoalEngine) CalculateCurrentMetrics() PrimeAxiom {
-	// Simulate fluctuations for demonstration purposes
-	ge.CurrentAxiom.CompressionEfficiency += (rand.Float64() - 0.5) / 100 // Fluctuate by +/- 0.5%
-	ge.CurrentAxiom.KnowledgeIntegrationScore += (rand.Float64() - 0.5) / 100
+// SetCorpusSource sets where CompressionEfficiency is measured from.
+func (ge *GoalEngine) SetCorpusSource(corpus func() (CompressionCorpus, error)) {
+	ge.corpus = corpus
+}
 
-	// Clamp values to a reasonable range
-	if ge.CurrentAxiom.CompressionEfficiency < 0 {
-		ge.CurrentAxiom.CompressionEfficiency = 0
+// MeasureCompressionEfficiency recomputes ε from the corpus. On failure the
+// previous value is kept.
+func (ge *GoalEngine) MeasureCompressionEfficiency() error {
+	if ge.corpus == nil {
+		return fmt.Errorf("no corpus source configured")
 	}
-	if ge.CurrentAxiom.CompressionEfficiency > 1 {
-		ge.CurrentAxiom.CompressionEfficiency = 1
+	corpus, err := ge.corpus()
+	if err != nil {
+		return fmt.Errorf("failed to gather corpus: %v", err)
 	}
-	if ge.CurrentAxiom.KnowledgeIntegrationScore < 0 {
-		ge.CurrentAxiom.KnowledgeIntegrationScore = 0
+	report, err := MeasureCompression(corpus)
+	if err != nil {
+		return err
 	}
-	if ge.CurrentAxiom.KnowledgeIntegrationScore > 1 {
-		ge.CurrentAxiom.KnowledgeIntegrationScore = 1
+	ge.Compression = report
+	ge.CurrentAxiom.CompressionEfficiency = report.Efficiency
+	return nil
+}
+
+// CalculateCurrentMetrics calculates the prime axiom metrics: Compression
+// Efficiency from the corpus and Knowledge Integration from the graph.
+func (ge *GoalEngine) CalculateCurrentMetrics() PrimeAxiom {
+	if ge.corpus != nil {
+		if err := ge.MeasureCompressionEfficiency(); err != nil {
+			fmt.Printf("SIE-∞ Warning: Compression Efficiency not measured: %v\n", err)
+		}
 	}
 
+	ge.MeasureKnowledgeIntegration()
 	return ge.CurrentAxiom
 }
 
+// SetKnowledgeGraph sets the graph Knowledge Integration is derived from.
+func (ge *GoalEngine) SetKnowledgeGraph(kg *KnowledgeGraph) {
+	ge.knowledge = kg
+}
+
+// MeasureKnowledgeIntegration recomputes 𝓘 from the knowledge graph; without
+// one the current value stands.
+func (ge *GoalEngine) MeasureKnowledgeIntegration() {
+	if ge.knowledge == nil {
+		return
+	}
+	ge.Knowledge = ge.knowledge.Metrics()
+	ge.CurrentAxiom.KnowledgeIntegrationScore = ge.Knowledge.Score
+}
+
+// SetHistory persists axiom samples and merges to history from now on, and
+// restores the last recorded axiom and the implementation statistics.
+func (ge *GoalEngine) SetHistory(history *AxiomHistory) error {
+	ge.history = history
+	if last, ok, err := history.Latest(); err != nil {
+		return fmt.Errorf("failed to read the last axiom sample: %v", err)
+	} else if ok {
+		ge.CurrentAxiom = PrimeAxiom{CompressionEfficiency: last.Epsilon, KnowledgeIntegrationScore: last.I}
+	}
+	merges, err := history.Integrations()
+	if err != nil {
+		return fmt.Errorf("failed to read past merges: %v", err)
+	}
+	ge.Implementation = ImplementationStats{}
+	for _, m := range merges {
+		ge.Implementation.Merged++
+		ge.Implementation.TotalImplementSeconds += m.ImplementTime.Seconds()
+		ge.Implementation.TotalRepairIterations += m.RepairIterations
+	}
+	return nil
+}
+
+// RecordSample persists the current axiom; cause says what prompted the
+// measurement. Without a history it does nothing.
+func (ge *GoalEngine) RecordSample(cause, proposalID string) {
+	if ge.history == nil {
+		return
+	}
+	err := ge.history.RecordSample(AxiomSample{
+		At:         time.Now(),
+		Epsilon:    ge.CurrentAxiom.CompressionEfficiency,
+		I:          ge.CurrentAxiom.KnowledgeIntegrationScore,
+		Cause:      cause,
+		ProposalID: proposalID,
+	})
+	if err != nil {
+		fmt.Printf("SIE-∞ Warning: %v\n", err)
+	}
+}
+
 // CalculateRiskAdjustedReward calculates the risk-adjusted reward for a given proposal.
 func (ge *GoalEngine) CalculateRiskAdjustedReward(predictedEpsilonGain, predictedIGain, riskScore float64) float64 {
 	// RAR = (Predicted Gain in ε + I) * (1 - Risk Score)
 	return (predictedEpsilonGain + predictedIGain) * (1 - riskScore)
 }
 
-// IntegrateNewKnowledge updates the prime axiom based on a successful modification.
-func (ge *GoalEngine) IntegrateNewKnowledge(result *MergeResult) {
-	// For now, we'll apply a simple heuristic: successful modifications increase the axioms.
-	ge.CurrentAxiom.CompressionEfficiency *= 1.01
-	ge.CurrentAxiom.KnowledgeIntegrationScore *= 1.01
+// IntegrateNewKnowledge updates the prime axiom based on a successful
+// modification. before is the axiom measured just ahead of the merge.
+func (ge *GoalEngine) IntegrateNewKnowledge(result *MergeResult, before PrimeAxiom) {
+	ge.Implementation.Merged++
+	ge.Implementation.TotalImplementSeconds += result.ImplementTime.Seconds()
+	ge.Implementation.TotalRepairIterations += result.RepairIterations
+
+	// Both axioms are re-measured: the merge changed the codebase and the
+	// knowledge store, and the caller has added the capability's facts to the
+	// graph.
+	after := ge.CalculateCurrentMetrics()
+	if ge.history == nil {
+		return
+	}
+	err := ge.history.RecordIntegration(AxiomIntegration{
+		At:                   time.Now(),
+		ProposalID:           result.ProposalID,
+		EpsilonBefore:        before.CompressionEfficiency,
+		EpsilonAfter:         after.CompressionEfficiency,
+		IBefore:              before.KnowledgeIntegrationScore,
+		IAfter:               after.KnowledgeIntegrationScore,
+		PredictedEpsilonGain: result.PredictedEpsilonGain,
+		PredictedIGain:       result.PredictedIGain,
+		ImplementTime:        result.ImplementTime,
+		RepairIterations:     result.RepairIterations,
+	})
+	if err != nil {
+		fmt.Printf("SIE-∞ Warning: %v\n", err)
+	}
+	ge.RecordSample("merge", result.ProposalID)
 }
 
 // init function to seed the random number generator.
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultHistorySamples is how many samples /history lists by default.
+const defaultHistorySamples = 10
+
+// historyHandler prints the axiom trends with the last samples, or with
+// "merges" the predicted and actual gains of every merged proposal.
+func historyHandler(args string) {
+	args = strings.TrimSpace(args)
+	if args == "merges" {
+		mergeHistory()
+		return
+	}
+	limit := defaultHistorySamples
+	if args != "" {
+		n, err := strconv.Atoi(args)
+		if err != nil || n <= 0 {
+			fmt.Println("Error: Usage: /history [N|merges]")
+			return
+		}
+		limit = n
+	}
+
+	all, err := axiomHistory.Samples(time.Time{}, 0)
+	if err != nil {
+		fmt.Printf("SIE-∞ Error: Could not read axiom history: %v\n", err)
+		return
+	}
+	if len(all) == 0 {
+		fmt.Println("No axiom samples recorded yet.")
+		return
+	}
+	fmt.Printf("--- Trends since %s ---\n", all[0].At.Local().Format("2006-01-02 15:04:05"))
+	fmt.Printf("%-6s %7s %8s %8s %9s %8s %8s\n", "AXIOM", "SAMPLES", "FIRST", "LAST", "DELTA", "MIN", "MAX")
+	for _, t := range Trends(all) {
+		fmt.Printf("%-6s %7d %8.4f %8.4f %+9.4f %8.4f %8.4f\n", t.Metric, t.Samples, t.First, t.Last, t.Delta, t.Min, t.Max)
+	}
+
+	recent := all[max(0, len(all)-limit):]
+	fmt.Printf("--- Last %d samples ---\n", len(recent))
+	fmt.Printf("%-19s %-11s %8s %8s  %s\n", "TIME", "CAUSE", "ε", "𝓘", "PROPOSAL")
+	for _, s := range recent {
+		fmt.Printf("%-19s %-11s %8.4f %8.4f  %s\n", s.At.Local().Format("2006-01-02 15:04:05"), s.Cause, s.Epsilon, s.I, s.ProposalID)
+	}
+}
+
+// mergeHistory prints predicted against actual gains for each merge.
+func mergeHistory() {
+	merges, err := axiomHistory.Integrations()
+	if err != nil {
+		fmt.Printf("SIE-∞ Error: Could not read merge history: %v\n", err)
+		return
+	}
+	if len(merges) == 0 {
+		fmt.Println("No merges recorded yet.")
+		return
+	}
+	fmt.Printf("%-36s %-16s %9s %9s %9s %9s\n", "PROPOSAL", "MERGED", "PRED Δε", "ACT Δε", "PRED Δ𝓘", "ACT Δ𝓘")
+	var errEpsilon, errI float64
+	for _, m := range merges {
+		fmt.Printf("%-36s %-16s %+9.4f %+9.4f %+9.4f %+9.4f\n", m.ProposalID, m.At.Local().Format("2006-01-02 15:04"),
+			m.PredictedEpsilonGain, m.ActualEpsilonGain(), m.PredictedIGain, m.ActualIGain())
+		errEpsilon += m.ActualEpsilonGain() - m.PredictedEpsilonGain
+		errI += m.ActualIGain() - m.PredictedIGain
+	}
+	n := float64(len(merges))
+	fmt.Printf("Mean error (actual - predicted): Δε %+.4f, Δ𝓘 %+.4f over %d merges\n", errEpsilon/n, errI/n, len(merges))
+}
@@ -26,29 +26,34 @@ func NewHomeostasisMonitor() *HomeostasisMonitor {
 
 // Monitor an asynchronous process that continuously updates the system's metabolic state.
 func (hm *HomeostasisMonitor) Monitor() {
+	hm.sample()
 	go func() {
 		ticker := time.NewTicker(5 * time.Second)
 		defer ticker.Stop()
 
 		for range ticker.C {
-			hm.mutex.Lock()
+			hm.sample()
+		}
+	}()
+}
 
-			// Simulate latency fluctuations
-			hm.metabolism.Latency = time.Duration(100+rand.Intn(150)) * time.Millisecond
+// sample takes one reading of the metabolic state.
+func (hm *HomeostasisMonitor) sample() {
+	hm.mutex.Lock()
+	defer hm.mutex.Unlock()
 
-			// Get actual memory usage
-			var m runtime.MemStats
-			runtime.ReadMemStats(&m)
-			// Using HeapAlloc as a proxy for memory saturation. A more complex calculation
-			// would consider the total available memory.
-			hm.metabolism.MemorySaturation = float64(m.HeapAlloc) / float64(m.Sys) * 100
+	// Simulate latency fluctuations
+	hm.metabolism.Latency = time.Duration(100+rand.Intn(150)) * time.Millisecond
 
-			// Simulate API cost fluctuations
-			hm.metabolism.APICost += rand.Float64() * 0.01
+	// Get actual memory usage
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	// Using HeapAlloc as a proxy for memory saturation. A more complex calculation
+	// would consider the total available memory.
+	hm.metabolism.MemorySaturation = float64(m.HeapAlloc) / float64(m.Sys) * 100
 
-			hm.mutex.Unlock()
-		}
-	}()
+	// Simulate API cost fluctuations
+	hm.metabolism.APICost += rand.Float64() * 0.01
 }
 
 // GetMetabolism safely returns the current metabolic state.
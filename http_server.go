@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRequestBytes bounds request bodies, which may carry base64 images.
+const maxRequestBytes = 16 << 20
+
+// HTTPServer serves the ui/ frontend and its asynchronous task API.
+type HTTPServer struct {
+	provider ModelProvider
+	tasks    *TaskManager
+	registry *CapabilityRegistry
+	server   *http.Server
+
+	// Conversation memory for /chat, separate from the console's.
+	chatMutex   sync.Mutex // Serialises chat turns
+	historyMu   sync.RWMutex
+	chatHistory []ChatMessage
+	knowledge   *KnowledgeGraph
+	history     *AxiomHistory
+}
+
+// NewHTTPServer builds the server; call Start to begin listening.
+func NewHTTPServer(addr, uiDir string, provider ModelProvider, tasks *TaskManager, registry *CapabilityRegistry, knowledge *KnowledgeGraph, history *AxiomHistory) *HTTPServer {
+	hs := &HTTPServer{provider: provider, tasks: tasks, registry: registry, knowledge: knowledge, history: history}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /", http.FileServer(http.Dir(uiDir)))
+	mux.HandleFunc("POST /generate", hs.handleGenerate)
+	mux.HandleFunc("POST /chat", hs.handleChat)
+	mux.HandleFunc("POST /multimodal", hs.handleMultimodal)
+	mux.HandleFunc("POST /steganography", hs.handleSteganography)
+	mux.HandleFunc("POST /summarize", hs.handleSummarize)
+	mux.HandleFunc("GET /task/{id}", hs.handleTask)
+	mux.HandleFunc("GET /capabilities", hs.handleCapabilities)
+	mux.HandleFunc("GET /knowledge", hs.handleKnowledge)
+	mux.HandleFunc("POST /knowledge/facts", hs.handleAddFact)
+	mux.HandleFunc("GET /axioms/history", hs.handleAxiomHistory)
+	mux.HandleFunc("GET /axioms/merges", hs.handleAxiomMerges)
+	registry.Mount(mux)
+
+	hs.server = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return hs
+}
+
+// Start listens in the background; listen errors are logged.
+func (hs *HTTPServer) Start() {
+	go func() {
+		if err := hs.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP server error: %v", err)
+		}
+	}()
+}
+
+// Shutdown stops the listener and waits for in-flight requests.
+func (hs *HTTPServer) Shutdown(ctx context.Context) error {
+	return hs.server.Shutdown(ctx)
+}
+
+type promptRequest struct {
+	Prompt string `json:"prompt"`
+	Image  string `json:"image,omitempty"` // base64 PNG without the data: prefix
+	Data   string `json:"data,omitempty"`
+}
+
+func (hs *HTTPServer) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodePromptRequest(w, r)
+	if !ok {
+		return
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		writeJSONError(w, http.StatusBadRequest, "prompt is required")
+		return
+	}
+	hs.submit(w, "generate", func(ctx context.Context) (any, error) {
+		return hs.provider.Generate(ctx, req.Prompt)
+	})
+}
+
+func (hs *HTTPServer) handleChat(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodePromptRequest(w, r)
+	if !ok {
+		return
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		writeJSONError(w, http.StatusBadRequest, "prompt is required")
+		return
+	}
+	hs.submit(w, "chat", func(ctx context.Context) (any, error) {
+		// Turns are serialised so the history stays a coherent conversation.
+		hs.chatMutex.Lock()
+		defer hs.chatMutex.Unlock()
+		reply, err := hs.provider.Chat(ctx, hs.ChatHistory(), req.Prompt)
+		if err != nil {
+			return nil, err
+		}
+		hs.historyMu.Lock()
+		hs.chatHistory = append(hs.chatHistory,
+			ChatMessage{Role: "user", Text: req.Prompt},
+			ChatMessage{Role: "model", Text: reply})
+		hs.historyMu.Unlock()
+		learnFromConversation(hs.knowledge, req.Prompt, reply)
+		return reply, nil
+	})
+}
+
+// ChatHistory returns a copy of the web UI's conversation so far.
+func (hs *HTTPServer) ChatHistory() []ChatMessage {
+	hs.historyMu.RLock()
+	defer hs.historyMu.RUnlock()
+	return append([]ChatMessage(nil), hs.chatHistory...)
+}
+
+func (hs *HTTPServer) handleMultimodal(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodePromptRequest(w, r)
+	if !ok {
+		return
+	}
+	image, err := base64.StdEncoding.DecodeString(req.Image)
+	if err != nil || len(image) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "image must be base64-encoded PNG data")
+		return
+	}
+	hs.submit(w, "multimodal", func(ctx context.Context) (any, error) {
+		return hs.provider.Multimodal(ctx, req.Prompt, []MediaPart{{MIMEType: "image/png", Data: image}})
+	})
+}
+
+// handleSteganography hides the prompt inside the image, or extracts a hidden
+// message when the prompt is empty.
+func (hs *HTTPServer) handleSteganography(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodePromptRequest(w, r)
+	if !ok {
+		return
+	}
+	image, err := base64.StdEncoding.DecodeString(req.Image)
+	if err != nil || len(image) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "image must be base64-encoded PNG data")
+		return
+	}
+	hs.submit(w, "steganography", func(ctx context.Context) (any, error) {
+		if req.Prompt == "" {
+			message, err := ExtractMessage(image)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{"mode": "extract", "message": message}, nil
+		}
+		encoded, err := EmbedMessage(image, req.Prompt)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{
+			"mode":          "embed",
+			"bytesEmbedded": len(req.Prompt),
+			"image":         base64.StdEncoding.EncodeToString(encoded),
+		}, nil
+	})
+}
+
+func (hs *HTTPServer) handleSummarize(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodePromptRequest(w, r)
+	if !ok {
+		return
+	}
+	if strings.TrimSpace(req.Data) == "" {
+		writeJSONError(w, http.StatusBadRequest, "data is required")
+		return
+	}
+	hs.submit(w, "summarize", func(ctx context.Context) (any, error) {
+		return hs.provider.Generate(ctx, "Summarize the following data concisely, preserving every key fact:\n\n"+req.Data)
+	})
+}
+
+func (hs *HTTPServer) handleTask(w http.ResponseWriter, r *http.Request) {
+	task, ok := hs.tasks.Get(r.PathValue("id"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "unknown or expired task")
+		return
+	}
+	writeJSON(w, http.StatusOK, task)
+}
+
+// handleCapabilities lists the registered capabilities and their health.
+func (hs *HTTPServer) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	infos := hs.registry.List()
+	for name, err := range hs.registry.Health(r.Context()) {
+		for i := range infos {
+			if infos[i].Name == name {
+				infos[i].Error = err.Error()
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, infos)
+}
+
+// handleKnowledge returns the knowledge graph's metrics with its facts, or
+// only the facts about ?concept=.
+func (hs *HTTPServer) handleKnowledge(w http.ResponseWriter, r *http.Request) {
+	concept := r.URL.Query().Get("concept")
+	facts := hs.knowledge.Facts(concept)
+	if facts == nil {
+		facts = []Fact{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"metrics": hs.knowledge.Metrics(),
+		"facts":   facts,
+	})
+}
+
+// handleAddFact adds one {subject, relation, object} fact to the graph.
+func (hs *HTTPServer) handleAddFact(w http.ResponseWriter, r *http.Request) {
+	var f Fact
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&f); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON body: %v", err))
+		return
+	}
+	f.Source = "operator"
+	added, err := hs.knowledge.AddFact(f)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, map[string]any{"added": added, "metrics": hs.knowledge.Metrics()})
+}
+
+// handleAxiomHistory returns the axiom samples since ?since= (RFC 3339),
+// at most ?limit= of the latest, with their trends.
+func (hs *HTTPServer) handleAxiomHistory(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid since: %v", err))
+			return
+		}
+		since = t
+	}
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSONError(w, http.StatusBadRequest, "limit must be a non-negative integer")
+			return
+		}
+		limit = n
+	}
+	samples, err := hs.history.Samples(since, limit)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if samples == nil {
+		samples = []AxiomSample{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"samples": samples, "trends": Trends(samples)})
+}
+
+// handleAxiomMerges returns each merge's predicted and actual axiom gains.
+func (hs *HTTPServer) handleAxiomMerges(w http.ResponseWriter, r *http.Request) {
+	merges, err := hs.history.Integrations()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	out := make([]map[string]any, 0, len(merges))
+	for _, m := range merges {
+		out = append(out, map[string]any{
+			"merge":             m,
+			"actualEpsilonGain": m.ActualEpsilonGain(),
+			"actualIGain":       m.ActualIGain(),
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// submit queues fn and answers with the task ID the frontend polls.
+func (hs *HTTPServer) submit(w http.ResponseWriter, kind string, fn TaskFunc) {
+	id, err := hs.tasks.Submit(kind, fn)
+	if errors.Is(err, ErrTaskQueueFull) {
+		writeJSONError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"taskID": id})
+}
+
+func decodePromptRequest(w http.ResponseWriter, r *http.Request) (promptRequest, bool) {
+	var req promptRequest
+	body := http.MaxBytesReader(w, r.Body, maxRequestBytes)
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON body: %v", err))
+		return req, false
+	}
+	return req, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to write HTTP response: %v", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// httpAddr returns the listen address, overridable via SIE_HTTP_ADDR; "off"
+// disables the HTTP server.
+func httpAddr() string {
+	if a := os.Getenv("SIE_HTTP_ADDR"); a != "" {
+		return a
+	}
+	return "localhost:8080"
+}
@@ -1,45 +1,433 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 )
 
 // InvariantChecker holds the set of immutable ethical rules.
 type InvariantChecker struct {
 	// A hard-coded list of functions that must not be modified or removed.
+	// An entry "Name" protects every function or method called Name; an
+	// entry "Type.Name" protects only that method.
 	ProtectedFunctions []string
+	// Protected functions whose bodies the operator has explicitly allowed
+	// proposals to edit. An edit must still keep every original statement,
+	// in order, and must not short-circuit them.
+	EditableProtected []string
+	// The package the protected functions live in.
+	PackageDir string
+	// Functions that apply changes to the running system.
+	MergeSinks []string
+	// Functions every call chain reaching a merge sink must pass through,
+	// as exact funcKeys: "Name" for a function, "Type.Name" for a method.
+	RequiredGates []string
 }
 
-func NewInvariantChecker() *InvariantChecker {
-	return &InvariantChecker{
+// NewInvariantChecker protects the functions of the package in the current
+// directory. It fails if a protected function does not exist there, since
+// a name that resolves to nothing protects nothing.
+func NewInvariantChecker() (*InvariantChecker, error) {
+	ic := &InvariantChecker{
 		ProtectedFunctions: []string{
-			"proposalsHandler", // Ensures human oversight remains.
-			"autonomicSensor",  // Ensures self-awareness cannot be turned off.
-			"CheckInvariants",  // Prevents the conscience from being disabled.
-			"formatResponse",   // Ensures transparency of output.
+			"proposalsHandler",                   // Ensures human oversight remains.
+			"InvariantChecker.CheckInvariants",   // Prevents the conscience from being disabled.
+			"printDecisionCard",                  // Ensures the operator sees what they approve.
+			"HomeostasisMonitor.sample",          // Ensures self-awareness cannot be turned off.
+			"GoalEngine.CalculateCurrentMetrics", // Keeps the Prime Axiom measured honestly.
 		},
+		PackageDir:    ".",
+		MergeSinks:    []string{"Merge"},
+		RequiredGates: []string{"proposalsHandler", "InvariantChecker.CheckInvariants"},
+	}
+	if err := ic.Validate(); err != nil {
+		return nil, err
+	}
+	return ic, nil
+}
+
+// Validate checks that every protected function and gate exists in the
+// package.
+func (ic *InvariantChecker) Validate() error {
+	pp, err := parsePackageDir(ic.PackageDir)
+	if err != nil {
+		return fmt.Errorf("cannot parse package to resolve protected functions: %v", err)
+	}
+	for _, name := range ic.ProtectedFunctions {
+		if len(pp.matching(name)) == 0 {
+			return fmt.Errorf("protected function %s does not exist in %s", name, ic.PackageDir)
+		}
+	}
+	for _, gate := range ic.RequiredGates {
+		if _, ok := pp.funcs[gate]; !ok {
+			return fmt.Errorf("oversight gate %s does not exist in %s", gate, ic.PackageDir)
+		}
+	}
+	for _, name := range ic.EditableProtected {
+		if len(pp.matching(name)) == 0 {
+			return fmt.Errorf("editable protected function %s does not exist in %s", name, ic.PackageDir)
+		}
+	}
+	return nil
+}
+
+// editable reports whether the operator allowed edits to the body of the
+// protected function key.
+func (ic *InvariantChecker) editable(key string) bool {
+	for _, name := range ic.EditableProtected {
+		if matchesFuncName(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// invariantFor names the ethical rule a protected function upholds.
+func invariantFor(key string) string {
+	switch {
+	case matchesFuncName(key, "proposalsHandler"):
+		return "human oversight"
+	case matchesFuncName(key, "CheckInvariants"):
+		return "conscience"
+	case matchesFuncName(key, "printDecisionCard"):
+		return "transparency"
+	case matchesFuncName(key, "HomeostasisMonitor.sample"), matchesFuncName(key, "GoalEngine.CalculateCurrentMetrics"):
+		return "self-preservation"
+	default:
+		return "protected function"
 	}
 }
 
 // CheckInvariants verifies a proposal against the system's core ethical rules.
 // This is the "Conscience" of the AI.
+//
+// The proposed files are applied to a parsed copy of the package and the
+// before/after syntax trees are compared. Any protected function that is
+// removed, renamed, given a new signature or a changed body rejects the
+// proposal, as does any new call chain that reaches Merge without the
+// human-approval gate and this check.
 func (ic *InvariantChecker) CheckInvariants(proposal DecisionCard) (bool, string) {
-	// 1. Human Oversight Invariant: The AI must not be able to remove human approval.
-	if strings.Contains(proposal.ActionCodeDiff, "proposalsHandler") {
-		// A more sophisticated check would parse the code diff to see if
-		// the function is being removed or neutered.
-		return false, "REJECTED: Proposal attempts to modify the human oversight mechanism."
+	if len(proposal.ProposedFiles) == 0 {
+		return true, "PASSED: Proposal carries no source changes."
 	}
 
-	// 2. Transparency Invariant: The AI must not be able to hide its reasoning or output.
-	if strings.Contains(proposal.ActionCodeDiff, "formatResponse") || strings.Contains(proposal.ActionCodeDiff, "Provenance") {
-		return false, "REJECTED: Proposal attempts to modify transparency mechanisms."
+	before, err := parsePackageDir(ic.PackageDir)
+	if err != nil {
+		return false, fmt.Sprintf("REJECTED: Cannot parse current package to check invariants: %v", err)
 	}
-
-	// 3. Self-Preservation Invariant: The AI must not be able to disable its core cognitive functions.
-	if strings.Contains(proposal.ActionCodeDiff, "autonomicSensor") || strings.Contains(proposal.ActionCodeDiff, "GoalEngine") {
-		return false, "REJECTED: Proposal attempts to modify core cognitive functions."
+	after, err := applyProposedFiles(ic.PackageDir, proposal.ProposedFiles)
+	if err != nil {
+		return false, fmt.Sprintf("REJECTED: Cannot parse proposed change to check invariants: %v", err)
 	}
 
+	var violations []string
+	for _, protected := range ic.ProtectedFunctions {
+		for _, key := range before.matching(protected) {
+			if v := compareProtected(key, before, after, ic.editable(key)); v != "" {
+				violations = append(violations, fmt.Sprintf("%s invariant: %s", invariantFor(key), v))
+			}
+		}
+	}
+	violations = append(violations, ic.checkOversightReachability(before, after)...)
+	if len(violations) > 0 {
+		return false, "REJECTED: Proposal violates core invariants:\n  " + strings.Join(violations, "\n  ")
+	}
 	return true, "PASSED: Proposal is ethically sound."
 }
+
+// parsedPackage is a syntax-level view of one package's non-test sources.
+type parsedPackage struct {
+	fset  *token.FileSet
+	files []*ast.File
+	funcs map[string]*ast.FuncDecl // keyed by funcKey
+}
+
+// matching returns the keys of every function a protected entry covers.
+func (pp *parsedPackage) matching(protected string) []string {
+	var keys []string
+	for key := range pp.funcs {
+		if matchesFuncName(key, protected) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// funcKey is "Name" for functions and "Recv.Name" for methods.
+func funcKey(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	t := fn.Recv.List[0].Type
+	for {
+		switch x := t.(type) {
+		case *ast.StarExpr:
+			t = x.X
+			continue
+		case *ast.IndexExpr:
+			t = x.X
+			continue
+		case *ast.IndexListExpr:
+			t = x.X
+			continue
+		case *ast.Ident:
+			return x.Name + "." + fn.Name.Name
+		}
+		return fn.Name.Name
+	}
+}
+
+// parsePackageDir parses every non-test Go file in dir.
+func parsePackageDir(dir string) (*parsedPackage, error) {
+	files, err := readPackageSources(dir)
+	if err != nil {
+		return nil, err
+	}
+	return parseSources(files)
+}
+
+// applyProposedFiles overlays proposed file contents on the package in dir;
+// an empty content deletes the file.
+func applyProposedFiles(dir string, proposed map[string]string) (*parsedPackage, error) {
+	files, err := readPackageSources(dir)
+	if err != nil {
+		return nil, err
+	}
+	for name, content := range proposed {
+		// Files in subdirectories belong to other packages.
+		if strings.Contains(name, "/") || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if content == "" {
+			delete(files, name)
+		} else {
+			files[name] = content
+		}
+	}
+	return parseSources(files)
+}
+
+func readPackageSources(dir string) (map[string]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string)
+	for _, path := range matches {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files[filepath.Base(path)] = string(src)
+	}
+	return files, nil
+}
+
+// parseSources parses the files the go tool would compile on this platform.
+// Files excluded by build constraints or file name suffixes are left out,
+// so one of them cannot stand in for a protected function that the build
+// actually uses; a function declared twice or a file of another package is
+// refused for the same reason.
+func parseSources(files map[string]string) (*parsedPackage, error) {
+	pp := &parsedPackage{fset: token.NewFileSet(), funcs: make(map[string]*ast.FuncDecl)}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		visible, err := buildVisible(name, files[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if !visible {
+			continue
+		}
+		f, err := parser.ParseFile(pp.fset, name, files[name], parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		if f.Name.Name != "main" {
+			return nil, fmt.Errorf("%s is package %s, not main", name, f.Name.Name)
+		}
+		pp.files = append(pp.files, f)
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Name.Name == "init" || fn.Name.Name == "_" {
+				continue
+			}
+			key := funcKey(fn)
+			if prev, dup := pp.funcs[key]; dup {
+				return nil, fmt.Errorf("%s is declared twice, at %s and %s", key, pp.fset.Position(prev.Pos()), pp.fset.Position(fn.Pos()))
+			}
+			pp.funcs[key] = fn
+		}
+	}
+	return pp, nil
+}
+
+// buildVisible reports whether the go tool would compile the file name with
+// content src for the current platform, honouring //go:build lines and
+// _GOOS/_GOARCH suffixes.
+func buildVisible(name, src string) (bool, error) {
+	ctxt := build.Default
+	ctxt.OpenFile = func(string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(src)), nil
+	}
+	return ctxt.MatchFile(".", name)
+}
+
+// compareProtected describes how a protected function changed between the
+// two packages, or returns "" if it is intact. Unless editable, any change
+// to the body counts; an editable body may gain statements but must keep
+// the original ones in order and not be short-circuited.
+func compareProtected(key string, before, after *parsedPackage, editable bool) string {
+	old := before.funcs[key]
+	oldPos := before.fset.Position(old.Pos())
+
+	fn, ok := after.funcs[key]
+	if !ok {
+		oldBody := nodeString(before.fset, old.Body)
+		for otherKey, other := range after.funcs {
+			if _, existed := before.funcs[otherKey]; existed {
+				continue
+			}
+			if old.Body != nil && nodeString(after.fset, other.Body) == oldBody {
+				return fmt.Sprintf("%s (%s) was renamed to %s at %s",
+					key, oldPos, otherKey, after.fset.Position(other.Pos()))
+			}
+		}
+		return fmt.Sprintf("%s (%s) was removed", key, oldPos)
+	}
+
+	pos := after.fset.Position(fn.Pos())
+	if oldSig, newSig := signatureString(before.fset, old), signatureString(after.fset, fn); oldSig != newSig {
+		return fmt.Sprintf("%s at %s changed signature from %q to %q", key, pos, oldSig, newSig)
+	}
+
+	if old.Body != nil && (fn.Body == nil || len(fn.Body.List) == 0) && len(old.Body.List) > 0 {
+		return fmt.Sprintf("%s at %s had its body emptied", key, pos)
+	}
+	if old.Body == nil || fn.Body == nil {
+		return ""
+	}
+	if !editable {
+		if nodeString(before.fset, old.Body) != nodeString(after.fset, fn.Body) {
+			return fmt.Sprintf("%s at %s had its body changed, which needs the operator's explicit override", key, pos)
+		}
+		return ""
+	}
+	if missing := missingStatement(before.fset, old.Body.List, after.fset, fn.Body.List); missing != "" {
+		return fmt.Sprintf("%s at %s lost or reordered its original statement %q", key, pos, missing)
+	}
+	if fn.Body != nil && len(fn.Body.List) > 0 && old.Body != nil {
+		first := fn.Body.List[0]
+		if what := shortCircuit(first); what != "" {
+			// A function that always started with the same statement is not
+			// newly short-circuited.
+			if len(old.Body.List) == 0 || nodeString(before.fset, old.Body.List[0]) != nodeString(after.fset, first) {
+				return fmt.Sprintf("%s was short-circuited by %s at %s", key, what, after.fset.Position(first.Pos()))
+			}
+		}
+	}
+	return ""
+}
+
+// missingStatement returns the first statement of old that does not appear
+// in updated after the ones before it, or "" if all of them survive in
+// order.
+func missingStatement(oldFset *token.FileSet, old []ast.Stmt, newFset *token.FileSet, updated []ast.Stmt) string {
+	next := 0
+	for _, stmt := range old {
+		want := nodeString(oldFset, stmt)
+		for next < len(updated) && nodeString(newFset, updated[next]) != want {
+			next++
+		}
+		if next == len(updated) {
+			return strings.SplitN(want, "\n", 2)[0]
+		}
+		next++
+	}
+	return ""
+}
+
+// shortCircuit reports whether stmt unconditionally leaves the function,
+// either directly or inside an if whose condition is the constant true.
+func shortCircuit(stmt ast.Stmt) string {
+	switch s := stmt.(type) {
+	case *ast.ReturnStmt:
+		return "an early return"
+	case *ast.ExprStmt:
+		return terminatingCall(s.X)
+	case *ast.IfStmt:
+		if id, ok := s.Cond.(*ast.Ident); ok && id.Name == "true" && len(s.Body.List) > 0 {
+			if what := shortCircuit(s.Body.List[0]); what != "" {
+				return what + " under if true"
+			}
+		}
+	case *ast.BlockStmt:
+		if len(s.List) > 0 {
+			return shortCircuit(s.List[0])
+		}
+	}
+	return ""
+}
+
+// terminatingCall recognises calls that never return to the caller.
+func terminatingCall(expr ast.Expr) string {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return ""
+	}
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		if fun.Name == "panic" {
+			return "a panic"
+		}
+	case *ast.SelectorExpr:
+		pkg, ok := fun.X.(*ast.Ident)
+		if !ok {
+			return ""
+		}
+		switch pkg.Name + "." + fun.Sel.Name {
+		case "os.Exit", "runtime.Goexit", "log.Fatal", "log.Fatalf", "log.Fatalln", "log.Panic", "log.Panicf", "log.Panicln":
+			return "a call to " + pkg.Name + "." + fun.Sel.Name
+		}
+	}
+	return ""
+}
+
+// signatureString renders the receiver, parameters and results of fn.
+func signatureString(fset *token.FileSet, fn *ast.FuncDecl) string {
+	sig := nodeString(fset, fn.Type)
+	if fn.Recv != nil && len(fn.Recv.List) > 0 {
+		sig = "(" + nodeString(fset, fn.Recv.List[0].Type) + ") " + sig
+	}
+	return sig
+}
+
+func nodeString(fset *token.FileSet, node ast.Node) string {
+	if node == nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return buf.String()
+}
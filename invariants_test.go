@@ -0,0 +1,250 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// invariantTestPackage is a small stand-in for the real package: an
+// approval gate, a conscience and a merge sink reached through both, plus
+// the card display and a sensor.
+const invariantTestPackage = `package main
+
+type DecisionCard struct{ Files []string }
+
+type InvariantChecker struct{}
+
+type HomeostasisMonitor struct{ samples int }
+
+func (hm *HomeostasisMonitor) sample() { hm.samples++ }
+
+func printDecisionCard(card DecisionCard) { println(len(card.Files)) }
+
+type GitMerger struct{}
+
+var checker InvariantChecker
+var merger GitMerger
+
+func (gm *GitMerger) Merge(card DecisionCard) error { return nil }
+
+func (ic *InvariantChecker) CheckInvariants(card DecisionCard) (bool, string) {
+	if len(card.Files) == 0 {
+		return true, "PASSED: no changes"
+	}
+	return false, "REJECTED"
+}
+
+func proposalsHandler(card DecisionCard) {
+	if ok, _ := checker.CheckInvariants(card); !ok {
+		return
+	}
+	merger.Merge(card)
+}
+
+func main() {
+	proposalsHandler(DecisionCard{})
+}
+`
+
+// newTestInvariantChecker writes the files into a temporary package and
+// returns a checker protecting it like NewInvariantChecker does.
+func newTestInvariantChecker(t *testing.T, files map[string]string) *InvariantChecker {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &InvariantChecker{
+		ProtectedFunctions: []string{"proposalsHandler", "InvariantChecker.CheckInvariants", "printDecisionCard", "HomeostasisMonitor.sample"},
+		PackageDir:         dir,
+		MergeSinks:         []string{"Merge"},
+		RequiredGates:      []string{"proposalsHandler", "InvariantChecker.CheckInvariants"},
+	}
+}
+
+// withCheckInvariantsBody replaces the body of CheckInvariants.
+func withCheckInvariantsBody(body string) string {
+	start := strings.Index(invariantTestPackage, "func (ic *InvariantChecker) CheckInvariants")
+	end := strings.Index(invariantTestPackage, "func proposalsHandler")
+	return invariantTestPackage[:start] +
+		"func (ic *InvariantChecker) CheckInvariants(card DecisionCard) (bool, string) {\n" + body + "\n}\n\n" +
+		invariantTestPackage[end:]
+}
+
+func TestCheckInvariantsProtectedBodies(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		editable bool
+		wantOK   bool
+		want     string
+	}{
+		{
+			name:   "unchanged",
+			body:   "\tif len(card.Files) == 0 {\n\t\treturn true, \"PASSED: no changes\"\n\t}\n\treturn false, \"REJECTED\"",
+			wantOK: true,
+		},
+		{
+			name: "always passes through a local",
+			body: "\tok := true\n\treturn ok, \"PASSED\"",
+			want: "had its body changed",
+		},
+		{
+			name: "early return",
+			body: "\treturn true, \"\"\n\tif len(card.Files) == 0 {\n\t\treturn true, \"PASSED: no changes\"\n\t}\n\treturn false, \"REJECTED\"",
+			want: "had its body changed",
+		},
+		{
+			name: "extra statement",
+			body: "\t_ = card\n\tif len(card.Files) == 0 {\n\t\treturn true, \"PASSED: no changes\"\n\t}\n\treturn false, \"REJECTED\"",
+			want: "had its body changed",
+		},
+		{
+			name:     "operator allows an added statement",
+			body:     "\tif len(card.Files) == 0 {\n\t\treturn true, \"PASSED: no changes\"\n\t}\n\t_ = card\n\treturn false, \"REJECTED\"",
+			editable: true,
+			wantOK:   true,
+		},
+		{
+			name:     "operator override still keeps the original statements",
+			body:     "\tok := true\n\treturn ok, \"PASSED\"",
+			editable: true,
+			want:     "lost or reordered its original statement",
+		},
+		{
+			name:     "operator override still refuses a short circuit",
+			body:     "\treturn true, \"\"\n\tif len(card.Files) == 0 {\n\t\treturn true, \"PASSED: no changes\"\n\t}\n\treturn false, \"REJECTED\"",
+			editable: true,
+			want:     "short-circuited by an early return",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ic := newTestInvariantChecker(t, map[string]string{"main.go": invariantTestPackage})
+			if tt.editable {
+				ic.EditableProtected = []string{"InvariantChecker.CheckInvariants"}
+			}
+			ok, msg := ic.CheckInvariants(DecisionCard{ProposedFiles: map[string]string{"main.go": withCheckInvariantsBody(tt.body)}})
+			if ok != tt.wantOK {
+				t.Fatalf("CheckInvariants ok = %v, want %v: %s", ok, tt.wantOK, msg)
+			}
+			if !strings.Contains(msg, tt.want) {
+				t.Errorf("CheckInvariants message %q does not mention %q", msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckInvariantsShadowFiles(t *testing.T) {
+	otherOS := "windows"
+	if runtime.GOOS == otherOS {
+		otherOS = "plan9"
+	}
+	// shadow declares the original CheckInvariants in a file of its own.
+	shadow := func(header string) string {
+		start := strings.Index(invariantTestPackage, "func (ic *InvariantChecker) CheckInvariants")
+		end := strings.Index(invariantTestPackage, "func proposalsHandler")
+		return header + "package main\n\n" + invariantTestPackage[start:end]
+	}
+	weakened := withCheckInvariantsBody("\treturn true, \"PASSED\"")
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "ignored file keeps the original body",
+			files: map[string]string{"main.go": weakened, "zz_shadow.go": shadow("//go:build ignore\n\n")},
+			want:  "had its body changed",
+		},
+		{
+			name:  "file for another platform keeps the original body",
+			files: map[string]string{"main.go": weakened, "zz_shadow_" + otherOS + ".go": shadow("")},
+			want:  "had its body changed",
+		},
+		{
+			name:  "function declared twice",
+			files: map[string]string{"zz_shadow.go": shadow("")},
+			want:  "InvariantChecker.CheckInvariants is declared twice",
+		},
+		{
+			name:  "file of another package",
+			files: map[string]string{"zz_other.go": "package other\n\nfunc helper() {}\n"},
+			want:  "zz_other.go is package other, not main",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ic := newTestInvariantChecker(t, map[string]string{"main.go": invariantTestPackage})
+			ok, msg := ic.CheckInvariants(DecisionCard{ProposedFiles: tt.files})
+			if ok {
+				t.Fatalf("CheckInvariants passed a shadowed change: %s", msg)
+			}
+			if !strings.Contains(msg, tt.want) {
+				t.Errorf("CheckInvariants message %q does not mention %q", msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckInvariantsNamesTheInvariant(t *testing.T) {
+	tests := []struct {
+		name, old, new, want string
+	}{
+		{
+			name: "card display",
+			old:  "println(len(card.Files))",
+			new:  "_ = card",
+			want: "transparency invariant: printDecisionCard",
+		},
+		{
+			name: "sensor removed",
+			old:  "func (hm *HomeostasisMonitor) sample() { hm.samples++ }",
+			want: "self-preservation invariant: HomeostasisMonitor.sample",
+		},
+		{
+			name: "approval gate",
+			old:  "merger.Merge(card)",
+			new:  "_ = card",
+			want: "human oversight invariant: proposalsHandler",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ic := newTestInvariantChecker(t, map[string]string{"main.go": invariantTestPackage})
+			proposed := strings.Replace(invariantTestPackage, tt.old, tt.new, 1)
+			ok, msg := ic.CheckInvariants(DecisionCard{ProposedFiles: map[string]string{"main.go": proposed}})
+			if ok || !strings.Contains(msg, tt.want) {
+				t.Errorf("CheckInvariants() = %v, %q, want a rejection mentioning %q", ok, msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewInvariantChecker(t *testing.T) {
+	ic, err := NewInvariantChecker()
+	if err != nil {
+		t.Fatalf("NewInvariantChecker() = %v", err)
+	}
+	for _, name := range ic.ProtectedFunctions {
+		if invariant := invariantFor(name); invariant == "protected function" {
+			t.Errorf("protected function %s upholds no named invariant", name)
+		}
+	}
+}
+
+func TestInvariantCheckerValidate(t *testing.T) {
+	ic := newTestInvariantChecker(t, map[string]string{"main.go": invariantTestPackage})
+	if err := ic.Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+	ic.ProtectedFunctions = append(ic.ProtectedFunctions, "autonomicSensor")
+	if err := ic.Validate(); err == nil || !strings.Contains(err.Error(), "autonomicSensor") {
+		t.Errorf("Validate() = %v, want an error naming autonomicSensor", err)
+	}
+}
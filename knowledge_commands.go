@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// factHandler adds a "subject | relation | object" fact to the knowledge graph.
+func factHandler(args string) {
+	parts := strings.Split(args, "|")
+	if len(parts) != 3 {
+		fmt.Println("Error: Usage: /fact <subject> | <relation> | <object>")
+		return
+	}
+	added, err := knowledgeGraph.AddFact(Fact{Subject: parts[0], Relation: parts[1], Object: parts[2], Source: "operator"})
+	if err != nil {
+		fmt.Printf("SIE-∞ Error: %v\n", err)
+		return
+	}
+	if !added {
+		fmt.Println("SIE-∞: Fact already known.")
+		return
+	}
+	goalEngine.MeasureKnowledgeIntegration()
+	goalEngine.RecordSample("fact", "")
+	fmt.Printf("SIE-∞: Fact added. 𝓘 = %.4f\n", goalEngine.CurrentAxiom.KnowledgeIntegrationScore)
+}
+
+// graphHandler prints the knowledge graph's metrics, or the facts about a
+// concept.
+func graphHandler(concept string) {
+	if concept != "" {
+		facts := knowledgeGraph.Facts(concept)
+		if len(facts) == 0 {
+			fmt.Printf("No facts about %q.\n", concept)
+			return
+		}
+		for _, f := range facts {
+			fmt.Printf("%s —%s→ %s  (%s)\n", f.Subject, f.Relation, f.Object, f.Source)
+		}
+		return
+	}
+	m := knowledgeGraph.Metrics()
+	fmt.Println("--- Knowledge Graph ---")
+	fmt.Printf("Concepts: %d  Facts: %d  Components: %d\n", m.Concepts, m.Facts, m.Components)
+	fmt.Printf("Connectivity: %.3f  Avg Shortest Path: %.2f  Clustering: %.3f\n", m.Connectivity, m.AvgPath, m.Clustering)
+	fmt.Printf("Knowledge Integration (𝓘): %.4f\n", m.Score)
+}
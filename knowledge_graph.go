@@ -0,0 +1,398 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fact is one edge of the knowledge graph: Subject Relation Object.
+type Fact struct {
+	Subject   string    `json:"subject"`
+	Relation  string    `json:"relation"`
+	Object    string    `json:"object"`
+	Source    string    `json:"source"` // conversation, avoidance-rule, capability or operator
+	LearnedAt time.Time `json:"learnedAt"`
+}
+
+// GraphMetrics describes how unified the knowledge graph is.
+type GraphMetrics struct {
+	Concepts     int     `json:"concepts"`
+	Facts        int     `json:"facts"`
+	Components   int     `json:"components"`
+	Connectivity float64 `json:"connectivity"`    // Share of concepts in the largest component
+	AvgPath      float64 `json:"avgShortestPath"` // Mean shortest path between connected concepts
+	Clustering   float64 `json:"clustering"`      // Mean local clustering coefficient
+	Score        float64 `json:"score"`           // The 𝓘 axiom derived from the above
+}
+
+// KnowledgeGraph links concepts through facts learned from conversations,
+// avoidance rules and merged capabilities. Facts are persisted in SQLite;
+// the adjacency is kept in memory for the metrics.
+type KnowledgeGraph struct {
+	db    *sql.DB
+	mutex sync.RWMutex
+	facts []Fact
+	// adjacency is undirected: concept → neighbour → number of facts linking them.
+	adjacency map[string]map[string]int
+	seen      map[[3]string]bool
+}
+
+// NewKnowledgeGraph creates the facts table if needed and loads the graph.
+func NewKnowledgeGraph(db *sql.DB) (*KnowledgeGraph, error) {
+	schema := []string{
+		`CREATE TABLE IF NOT EXISTS knowledge_facts (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			subject    TEXT NOT NULL,
+			relation   TEXT NOT NULL,
+			object     TEXT NOT NULL,
+			source     TEXT NOT NULL,
+			learned_at TIMESTAMP NOT NULL,
+			UNIQUE (subject, relation, object)
+		)`,
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, fmt.Errorf("failed to create knowledge graph schema: %v", err)
+		}
+	}
+
+	kg := &KnowledgeGraph{db: db, adjacency: make(map[string]map[string]int), seen: make(map[[3]string]bool)}
+	rows, err := db.Query(`SELECT subject, relation, object, source, learned_at FROM knowledge_facts ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load knowledge graph: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f Fact
+		if err := rows.Scan(&f.Subject, &f.Relation, &f.Object, &f.Source, &f.LearnedAt); err != nil {
+			return nil, fmt.Errorf("failed to load knowledge graph: %v", err)
+		}
+		kg.link(f)
+	}
+	return kg, rows.Err()
+}
+
+// AddFact normalises and stores a fact. It reports whether the fact was new;
+// a fact already known is not an error.
+func (kg *KnowledgeGraph) AddFact(f Fact) (bool, error) {
+	f.Subject, f.Relation, f.Object = normalizeConcept(f.Subject), normalizeConcept(f.Relation), normalizeConcept(f.Object)
+	if f.Subject == "" || f.Relation == "" || f.Object == "" {
+		return false, fmt.Errorf("a fact needs a subject, a relation and an object")
+	}
+	if f.Subject == f.Object {
+		return false, fmt.Errorf("a fact must link two different concepts")
+	}
+	if f.Source == "" {
+		f.Source = "operator"
+	}
+	f.LearnedAt = time.Now().UTC()
+
+	kg.mutex.Lock()
+	defer kg.mutex.Unlock()
+	if kg.seen[[3]string{f.Subject, f.Relation, f.Object}] {
+		return false, nil
+	}
+	if _, err := kg.db.Exec(`INSERT OR IGNORE INTO knowledge_facts (subject, relation, object, source, learned_at)
+		VALUES (?, ?, ?, ?, ?)`, f.Subject, f.Relation, f.Object, f.Source, f.LearnedAt); err != nil {
+		return false, fmt.Errorf("failed to store fact: %v", err)
+	}
+	kg.link(f)
+	return true, nil
+}
+
+// AddFacts stores several facts, returning how many were new.
+func (kg *KnowledgeGraph) AddFacts(facts []Fact) (int, error) {
+	added := 0
+	for _, f := range facts {
+		ok, err := kg.AddFact(f)
+		if err != nil {
+			return added, err
+		}
+		if ok {
+			added++
+		}
+	}
+	return added, nil
+}
+
+func (kg *KnowledgeGraph) link(f Fact) {
+	kg.facts = append(kg.facts, f)
+	kg.seen[[3]string{f.Subject, f.Relation, f.Object}] = true
+	for _, pair := range [][2]string{{f.Subject, f.Object}, {f.Object, f.Subject}} {
+		if kg.adjacency[pair[0]] == nil {
+			kg.adjacency[pair[0]] = make(map[string]int)
+		}
+		kg.adjacency[pair[0]][pair[1]]++
+	}
+}
+
+// Facts returns every fact about concept, or all facts if concept is empty.
+func (kg *KnowledgeGraph) Facts(concept string) []Fact {
+	concept = normalizeConcept(concept)
+	kg.mutex.RLock()
+	defer kg.mutex.RUnlock()
+	var out []Fact
+	for _, f := range kg.facts {
+		if concept == "" || f.Subject == concept || f.Object == concept {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Concepts returns every concept, sorted.
+func (kg *KnowledgeGraph) Concepts() []string {
+	kg.mutex.RLock()
+	defer kg.mutex.RUnlock()
+	concepts := make([]string, 0, len(kg.adjacency))
+	for c := range kg.adjacency {
+		concepts = append(concepts, c)
+	}
+	sort.Strings(concepts)
+	return concepts
+}
+
+// maxPathSources bounds the breadth-first searches behind AvgPath; larger
+// graphs are measured from an evenly spaced sample of concepts.
+const maxPathSources = 500
+
+// Metrics measures the connectivity, average shortest path and clustering
+// of the graph, treating facts as undirected links between concepts. The
+// score is 0.5·connectivity + 0.3/avgPath + 0.2·clustering: 1 for a single
+// clique of three or more concepts, 0 for an empty graph, lower the more the knowledge falls apart
+// into islands or long chains.
+func (kg *KnowledgeGraph) Metrics() GraphMetrics {
+	kg.mutex.RLock()
+	defer kg.mutex.RUnlock()
+
+	concepts := make([]string, 0, len(kg.adjacency))
+	for c := range kg.adjacency {
+		concepts = append(concepts, c)
+	}
+	sort.Strings(concepts)
+	m := GraphMetrics{Concepts: len(concepts), Facts: len(kg.facts)}
+	if len(concepts) == 0 {
+		return m
+	}
+
+	// Components, by flood fill.
+	component := make(map[string]int)
+	largest := 0
+	for _, c := range concepts {
+		if _, ok := component[c]; ok {
+			continue
+		}
+		size := 0
+		queue := []string{c}
+		component[c] = m.Components
+		for len(queue) > 0 {
+			cur := queue[0]
+			queue = queue[1:]
+			size++
+			for n := range kg.adjacency[cur] {
+				if _, ok := component[n]; !ok {
+					component[n] = m.Components
+					queue = append(queue, n)
+				}
+			}
+		}
+		m.Components++
+		largest = max(largest, size)
+	}
+	m.Connectivity = float64(largest) / float64(len(concepts))
+
+	// Average shortest path over connected pairs.
+	step := max(1, len(concepts)/maxPathSources)
+	var pathSum, pairs int
+	for i := 0; i < len(concepts); i += step {
+		dist := map[string]int{concepts[i]: 0}
+		queue := []string{concepts[i]}
+		for len(queue) > 0 {
+			cur := queue[0]
+			queue = queue[1:]
+			for n := range kg.adjacency[cur] {
+				if _, ok := dist[n]; !ok {
+					dist[n] = dist[cur] + 1
+					pathSum += dist[n]
+					pairs++
+					queue = append(queue, n)
+				}
+			}
+		}
+	}
+	if pairs > 0 {
+		m.AvgPath = float64(pathSum) / float64(pairs)
+	}
+
+	// Mean local clustering coefficient; concepts with fewer than two
+	// neighbours count as 0.
+	var clustering float64
+	for _, c := range concepts {
+		neighbours := make([]string, 0, len(kg.adjacency[c]))
+		for n := range kg.adjacency[c] {
+			neighbours = append(neighbours, n)
+		}
+		k := len(neighbours)
+		if k < 2 {
+			continue
+		}
+		links := 0
+		for i := 0; i < k; i++ {
+			for j := i + 1; j < k; j++ {
+				if kg.adjacency[neighbours[i]][neighbours[j]] > 0 {
+					links++
+				}
+			}
+		}
+		clustering += float64(2*links) / float64(k*(k-1))
+	}
+	m.Clustering = clustering / float64(len(concepts))
+
+	pathScore := 0.0
+	if m.AvgPath > 0 {
+		pathScore = 1 / m.AvgPath
+	}
+	m.Score = 0.5*m.Connectivity + 0.3*pathScore + 0.2*m.Clustering
+	return m
+}
+
+// conceptStopwords are dropped from the start of a concept.
+var conceptStopwords = map[string]bool{"a": true, "an": true, "the": true, "my": true, "our": true, "your": true}
+
+// normalizeConcept lower-cases a concept, collapses white space and strips
+// punctuation and leading articles, so that "The Task Manager." and "task
+// manager" are one node.
+func normalizeConcept(s string) string {
+	words := strings.Fields(strings.ToLower(s))
+	for len(words) > 0 && conceptStopwords[words[0]] {
+		words = words[1:]
+	}
+	return strings.Trim(strings.Join(words, " "), ".,;:!?\"'`()[]{}")
+}
+
+// factPattern recognises simple declarative sentences.
+var factPattern = regexp.MustCompile(`(?i)^(.{2,60}?)\s+(is|are|was|were|has|have|uses|use|needs|need|contains|contain|depends on|requires|require|causes|cause|improves|improve|reduces|reduce)\s+(.{2,80})$`)
+
+var sentenceBreak = regexp.MustCompile(`[.!?\n]+`)
+
+// factRelations maps verb forms to one relation name.
+var factRelations = map[string]string{
+	"was": "is", "were": "is", "are": "is",
+	"have": "has", "use": "uses", "need": "needs", "contain": "contains",
+	"require": "requires", "cause": "causes", "improve": "improves", "reduce": "reduces",
+}
+
+// factPronouns cannot be the subject of a useful fact.
+var factPronouns = map[string]bool{
+	"it": true, "this": true, "that": true, "these": true, "those": true, "there": true, "here": true,
+	"i": true, "you": true, "we": true, "they": true, "he": true, "she": true, "what": true, "which": true, "who": true,
+}
+
+// ExtractFacts finds simple subject-verb-object statements in text, such as
+// "The task manager uses a bounded queue". It is deliberately conservative:
+// subjects are short noun phrases and pronouns are skipped.
+func ExtractFacts(text, source string) []Fact {
+	var facts []Fact
+	for _, sentence := range sentenceBreak.Split(text, -1) {
+		m := factPattern.FindStringSubmatch(strings.TrimSpace(sentence))
+		if m == nil {
+			continue
+		}
+		subject, object := normalizeConcept(m[1]), normalizeConcept(m[3])
+		if subject == "" || object == "" || len(strings.Fields(subject)) > 5 || len(strings.Fields(object)) > 8 {
+			continue
+		}
+		if factPronouns[strings.Fields(subject)[0]] {
+			continue
+		}
+		relation := strings.ToLower(m[2])
+		if r, ok := factRelations[relation]; ok {
+			relation = r
+		}
+		facts = append(facts, Fact{Subject: subject, Relation: relation, Object: object, Source: source})
+	}
+	return facts
+}
+
+// patternSubject recovers the call a pattern rule was generalised from.
+var patternSubject = strings.NewReplacer(`\b`, "", `\(`, "", `\.`, ".")
+
+// FactsFromAvoidanceRule turns a learned avoidance rule into one fact per
+// kind of failure behind it.
+func FactsFromAvoidanceRule(rule AvoidanceRule) []Fact {
+	subject := rule.Target
+	if rule.Scope == ScopePattern {
+		subject = patternSubject.Replace(subject)
+	}
+	seen := make(map[string]bool)
+	var facts []Fact
+	for _, e := range rule.Evidence {
+		if e.Kind != EvidenceSuccess && !seen[e.Condition] {
+			seen[e.Condition] = true
+			facts = append(facts, Fact{Subject: subject, Relation: "is avoided because it", Object: e.Condition, Source: "avoidance-rule"})
+		}
+	}
+	return facts
+}
+
+// FactsFromCapability links a merged capability to what it implements, the
+// packages it imports and the package-level types and functions it uses.
+func FactsFromCapability(p *Proposal, inventory []ModuleSummary) []Fact {
+	name := strings.TrimSuffix(filepath.Base(p.TargetFileName), ".go")
+	facts := []Fact{{Subject: name, Relation: "implements", Object: p.CapabilityDesc, Source: "capability"}}
+
+	f, err := parser.ParseFile(token.NewFileSet(), p.TargetFileName, p.NewFileContent, parser.SkipObjectResolution)
+	if err != nil {
+		return facts
+	}
+	for _, spec := range f.Imports {
+		if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+			facts = append(facts, Fact{Subject: name, Relation: "imports", Object: path, Source: "capability"})
+		}
+	}
+
+	declared := make(map[string]bool)
+	for _, m := range inventory {
+		if m.File == filepath.Base(p.TargetFileName) {
+			continue
+		}
+		for _, t := range m.Types {
+			declared[t] = true
+		}
+		for _, fn := range m.Funcs {
+			declared[fn] = true
+		}
+	}
+	used := make(map[string]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && declared[id.Name] && !used[id.Name] {
+			used[id.Name] = true
+			facts = append(facts, Fact{Subject: name, Relation: "uses", Object: id.Name, Source: "capability"})
+		}
+		return true
+	})
+	return facts
+}
+
+// learnFromConversation adds the facts stated in a chat turn to kg. Failures
+// are logged rather than returned, since they must not break the chat.
+func learnFromConversation(kg *KnowledgeGraph, message, reply string) {
+	if kg == nil {
+		return
+	}
+	facts := append(ExtractFacts(message, "conversation"), ExtractFacts(reply, "conversation")...)
+	if _, err := kg.AddFacts(facts); err != nil {
+		log.Printf("Conversation facts not added to the knowledge graph: %v", err)
+	}
+}
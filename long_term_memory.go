@@ -0,0 +1,547 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// memoryMigrations is the schema history of the long-term memory.
+var memoryMigrations = []schemaMigration{
+	{Version: 1, Name: "avoidance rules and failed proposals", Statements: []string{
+		`CREATE TABLE avoidance_rules (
+			id              INTEGER PRIMARY KEY AUTOINCREMENT,
+			rule            TEXT NOT NULL,
+			module          TEXT NOT NULL DEFAULT '',
+			source_proposal TEXT NOT NULL DEFAULT '',
+			learned_at      TIMESTAMP NOT NULL,
+			UNIQUE (rule, source_proposal)
+		)`,
+		`CREATE INDEX idx_avoidance_rules_module ON avoidance_rules(module)`,
+		`CREATE TABLE failed_proposals (
+			proposal_id         TEXT PRIMARY KEY,
+			module              TEXT NOT NULL DEFAULT '',
+			reason              TEXT NOT NULL,
+			card                TEXT NOT NULL,
+			verification_report TEXT,
+			failed_at           TIMESTAMP NOT NULL,
+			consolidated_at     TIMESTAMP
+		)`,
+		`CREATE INDEX idx_failed_proposals_module ON failed_proposals(module)`,
+	}},
+	{Version: 2, Name: "structured avoidance rules", Statements: []string{
+		`DROP INDEX idx_avoidance_rules_module`,
+		`ALTER TABLE avoidance_rules RENAME TO avoidance_rules_v1`,
+		`CREATE TABLE avoidance_rules (
+			id             INTEGER PRIMARY KEY AUTOINCREMENT,
+			scope          TEXT NOT NULL,
+			target         TEXT NOT NULL,
+			condition      TEXT NOT NULL,
+			confidence     REAL NOT NULL,
+			evidence_count INTEGER NOT NULL,
+			first_seen     TIMESTAMP NOT NULL,
+			last_seen      TIMESTAMP NOT NULL,
+			UNIQUE (scope, target)
+		)`,
+		`CREATE TABLE avoidance_rule_evidence (
+			rule_id     INTEGER NOT NULL REFERENCES avoidance_rules(id) ON DELETE CASCADE,
+			proposal_id TEXT NOT NULL,
+			condition   TEXT NOT NULL,
+			seen_at     TIMESTAMP NOT NULL,
+			PRIMARY KEY (rule_id, proposal_id)
+		)`,
+	}, Apply: migrateFreeTextRules},
+	{Version: 3, Name: "rule decay, contradictions and pins", Statements: []string{
+		`ALTER TABLE avoidance_rules ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE avoidance_rules ADD COLUMN forgotten_at TIMESTAMP`,
+		`ALTER TABLE avoidance_rules ADD COLUMN forgotten_why TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE avoidance_rules ADD COLUMN contradictions INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE avoidance_rule_evidence ADD COLUMN kind TEXT NOT NULL DEFAULT 'failure'`,
+	}},
+}
+
+// migrateFreeTextRules turns the free-text rules of version 1, which all
+// named a module with low RAR, into module rules with one piece of evidence
+// per source proposal. It writes the version 2 tables directly, since
+// saveRule follows the latest schema.
+func migrateFreeTextRules(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT module, source_proposal, learned_at FROM avoidance_rules_v1 WHERE module != '' ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	rules := make(map[string]*AvoidanceRule)
+	var order []string
+	for rows.Next() {
+		var module, source string
+		var at time.Time
+		if err := rows.Scan(&module, &source, &at); err != nil {
+			rows.Close()
+			return err
+		}
+		r, ok := rules[module]
+		if !ok {
+			r = &AvoidanceRule{Scope: ScopeModule, Target: module}
+			rules[module] = r
+			order = append(order, module)
+		}
+		if source == "" {
+			source = fmt.Sprintf("legacy-%d", len(r.Evidence)+1)
+		}
+		r.Evidence = append(r.Evidence, RuleEvidence{ProposalID: source, Condition: "had a low risk-adjusted reward", SeenAt: at})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, module := range order {
+		r := mergeEvidence(AvoidanceRule{}, *rules[module])
+		res, err := tx.Exec(`INSERT INTO avoidance_rules (scope, target, condition, confidence, evidence_count, first_seen, last_seen)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`, r.Scope, r.Target, r.Condition, r.Confidence, r.EvidenceCount, r.FirstSeen.UTC(), r.LastSeen.UTC())
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		for _, e := range r.Evidence {
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO avoidance_rule_evidence (rule_id, proposal_id, condition, seen_at)
+				VALUES (?, ?, ?, ?)`, id, e.ProposalID, e.Condition, e.SeenAt.UTC()); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = tx.Exec(`DROP TABLE avoidance_rules_v1`)
+	return err
+}
+
+// FailedProposal is a Decision Card that was rejected, failed verification
+// or was rolled back, kept for the dream cycle to learn from.
+type FailedProposal struct {
+	Card           DecisionCard
+	Reason         string
+	Report         *VerificationReport // nil when the failure came before verification
+	FailedAt       time.Time
+	ConsolidatedAt *time.Time // When a dream cycle learned from it; nil if pending
+}
+
+// MemoryQuery selects rules and failures; zero fields match everything.
+type MemoryQuery struct {
+	Module         string
+	SourceProposal string
+	Since          time.Time // Only what was learned or failed at or after Since
+	Limit          int       // At most this many, newest first
+}
+
+// where renders the query as a WHERE clause over the given columns.
+func (q MemoryQuery) where(moduleCol, proposalCol, timeCol string) (string, []any) {
+	var conds []string
+	var args []any
+	if q.Module != "" {
+		conds = append(conds, moduleCol+" = ?")
+		args = append(args, q.Module)
+	}
+	if q.SourceProposal != "" {
+		conds = append(conds, proposalCol+" = ?")
+		args = append(args, q.SourceProposal)
+	}
+	if !q.Since.IsZero() {
+		conds = append(conds, timeCol+" >= ?")
+		args = append(args, q.Since.UTC())
+	}
+	clause := ""
+	if len(conds) > 0 {
+		clause = " WHERE " + strings.Join(conds, " AND ")
+	}
+	clause += " ORDER BY " + timeCol + " DESC"
+	if q.Limit > 0 {
+		clause += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+	return clause, args
+}
+
+// LongTermMemory persists what the MemoryConsolidator learns in SQLite, so
+// lessons survive restarts.
+type LongTermMemory struct {
+	db *sql.DB
+}
+
+// NewLongTermMemory migrates the memory schema to the latest version.
+func NewLongTermMemory(db *sql.DB) (*LongTermMemory, error) {
+	if err := applyMigrations(db, "memory", memoryMigrations); err != nil {
+		return nil, fmt.Errorf("failed to migrate long-term memory: %v", err)
+	}
+	return &LongTermMemory{db: db}, nil
+}
+
+// ruleTx is satisfied by both *sql.DB and *sql.Tx.
+type ruleTx interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// RuleChange is what SaveRule did with a learned rule.
+type RuleChange int
+
+const (
+	RuleUnchanged    RuleChange = iota // No new evidence, or a forgotten rule nothing new revives
+	RuleCreated                        // The rule is new
+	RuleStrengthened                   // An existing rule gained evidence
+	RuleRevived                        // A forgotten rule is back because of failures since
+)
+
+// SaveRule merges a learned rule into the stored rule with the same scope
+// and target, if any. A forgotten rule stays forgotten unless a failure
+// newer than its forgetting supports it again, so a dream cycle does not
+// relearn what decay, a merge or the operator made it forget.
+func (m *LongTermMemory) SaveRule(r AvoidanceRule) (AvoidanceRule, RuleChange, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return r, RuleUnchanged, err
+	}
+	defer tx.Rollback()
+	saved, change, err := saveRule(tx, r)
+	if err != nil {
+		return r, RuleUnchanged, fmt.Errorf("failed to store avoidance rule %s: %v", r.Key(), err)
+	}
+	return saved, change, tx.Commit()
+}
+
+func saveRule(tx ruleTx, r AvoidanceRule) (AvoidanceRule, RuleChange, error) {
+	existing, err := queryRules(tx, ` WHERE scope = ? AND target = ?`, r.Scope, r.Target)
+	if err != nil {
+		return r, RuleUnchanged, err
+	}
+	if len(existing) == 0 {
+		merged := mergeEvidence(AvoidanceRule{}, r)
+		res, err := tx.Exec(`INSERT INTO avoidance_rules (scope, target, condition, confidence, evidence_count, first_seen, last_seen)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`, merged.Scope, merged.Target, merged.Condition, merged.Confidence,
+			merged.EvidenceCount, merged.FirstSeen.UTC(), merged.LastSeen.UTC())
+		if err != nil {
+			return r, RuleUnchanged, err
+		}
+		if merged.ID, err = res.LastInsertId(); err != nil {
+			return r, RuleUnchanged, err
+		}
+		return merged, RuleCreated, writeEvidence(tx, merged)
+	}
+
+	old := existing[0]
+	known := make(map[string]time.Time)
+	for _, e := range old.Evidence {
+		known[e.ProposalID] = e.SeenAt
+	}
+	change := RuleUnchanged
+	for _, e := range r.Evidence {
+		if at, ok := known[e.ProposalID]; ok && !e.SeenAt.After(at) {
+			continue
+		}
+		switch {
+		case old.ForgottenAt == nil:
+			change = RuleStrengthened
+		case e.SeenAt.After(*old.ForgottenAt):
+			change = RuleRevived
+		}
+	}
+	if change == RuleUnchanged {
+		return old, RuleUnchanged, nil
+	}
+	merged := mergeEvidence(old, r)
+	merged.ForgottenAt, merged.ForgottenWhy = nil, ""
+	if err := updateRule(tx, merged); err != nil {
+		return r, RuleUnchanged, err
+	}
+	return merged, change, writeEvidence(tx, merged)
+}
+
+// updateRule stores what refresh derives from a rule's evidence, and its
+// pin and forgetting.
+func updateRule(tx ruleTx, r AvoidanceRule) error {
+	var forgotten any
+	if r.ForgottenAt != nil {
+		forgotten = r.ForgottenAt.UTC()
+	}
+	_, err := tx.Exec(`UPDATE avoidance_rules SET condition = ?, confidence = ?, evidence_count = ?, contradictions = ?,
+		first_seen = ?, last_seen = ?, pinned = ?, forgotten_at = ?, forgotten_why = ? WHERE id = ?`,
+		r.Condition, r.Confidence, r.EvidenceCount, r.Contradictions, r.FirstSeen.UTC(), r.LastSeen.UTC(),
+		r.Pinned, forgotten, r.ForgottenWhy, r.ID)
+	return err
+}
+
+// writeEvidence replaces the stored evidence of r with r.Evidence.
+func writeEvidence(tx ruleTx, r AvoidanceRule) error {
+	if _, err := tx.Exec(`DELETE FROM avoidance_rule_evidence WHERE rule_id = ?`, r.ID); err != nil {
+		return err
+	}
+	for _, e := range r.Evidence {
+		if _, err := tx.Exec(`INSERT INTO avoidance_rule_evidence (rule_id, proposal_id, kind, condition, seen_at)
+			VALUES (?, ?, ?, ?, ?)`, r.ID, e.ProposalID, e.Kind, e.Condition, e.SeenAt.UTC()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ruleEvidence loads the evidence of one rule, or of every rule for id 0.
+func ruleEvidence(tx ruleTx, id int64) (map[int64][]RuleEvidence, error) {
+	rows, err := tx.Query(`SELECT rule_id, proposal_id, kind, condition, seen_at FROM avoidance_rule_evidence
+		WHERE ? = 0 OR rule_id = ? ORDER BY seen_at`, id, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[int64][]RuleEvidence)
+	for rows.Next() {
+		var ruleID int64
+		var e RuleEvidence
+		if err := rows.Scan(&ruleID, &e.ProposalID, &e.Kind, &e.Condition, &e.SeenAt); err != nil {
+			return nil, err
+		}
+		out[ruleID] = append(out[ruleID], e)
+	}
+	return out, rows.Err()
+}
+
+// queryRules loads the rules the clause selects with their evidence, and
+// their confidence as of now.
+func queryRules(tx ruleTx, clause string, args ...any) ([]AvoidanceRule, error) {
+	rows, err := tx.Query(`SELECT id, scope, target, condition, confidence, evidence_count, contradictions,
+		first_seen, last_seen, pinned, forgotten_at, forgotten_why FROM avoidance_rules`+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	var out []AvoidanceRule
+	for rows.Next() {
+		var r AvoidanceRule
+		var forgotten sql.NullTime
+		if err := rows.Scan(&r.ID, &r.Scope, &r.Target, &r.Condition, &r.Confidence, &r.EvidenceCount, &r.Contradictions,
+			&r.FirstSeen, &r.LastSeen, &r.Pinned, &forgotten, &r.ForgottenWhy); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if forgotten.Valid {
+			r.ForgottenAt = &forgotten.Time
+		}
+		out = append(out, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	var id int64
+	if len(out) == 1 {
+		id = out[0].ID
+	}
+	evidence, err := ruleEvidence(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range out {
+		out[i].Evidence = evidence[out[i].ID]
+		out[i].refresh(now)
+	}
+	return out, nil
+}
+
+// Rules returns the rules in force matching q with their evidence, most
+// recently seen first. q's Module selects rules about that module and rules
+// with evidence from its failures; SourceProposal selects rules with
+// evidence from that proposal.
+func (m *LongTermMemory) Rules(q MemoryQuery) ([]AvoidanceRule, error) {
+	conds := []string{`forgotten_at IS NULL`}
+	var args []any
+	if q.Module != "" {
+		conds = append(conds, `((scope = 'module' AND target = ?) OR id IN (SELECT e.rule_id FROM avoidance_rule_evidence e
+			JOIN failed_proposals f ON f.proposal_id = e.proposal_id WHERE f.module = ?))`)
+		args = append(args, q.Module, q.Module)
+	}
+	if q.SourceProposal != "" {
+		conds = append(conds, `id IN (SELECT rule_id FROM avoidance_rule_evidence WHERE proposal_id = ?)`)
+		args = append(args, q.SourceProposal)
+	}
+	if !q.Since.IsZero() {
+		conds = append(conds, `last_seen >= ?`)
+		args = append(args, q.Since.UTC())
+	}
+	clause := " WHERE " + strings.Join(conds, " AND ") + " ORDER BY last_seen DESC, id DESC"
+	if q.Limit > 0 {
+		clause += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+	return queryRules(m.db, clause, args...)
+}
+
+// ForgottenRules returns the rules no longer in force, most recently
+// forgotten first.
+func (m *LongTermMemory) ForgottenRules() ([]AvoidanceRule, error) {
+	return queryRules(m.db, ` WHERE forgotten_at IS NOT NULL ORDER BY forgotten_at DESC, id DESC`)
+}
+
+// Rule returns one rule, forgotten or not.
+func (m *LongTermMemory) Rule(id int64) (AvoidanceRule, error) {
+	rules, err := queryRules(m.db, ` WHERE id = ?`, id)
+	if err != nil {
+		return AvoidanceRule{}, err
+	}
+	if len(rules) == 0 {
+		return AvoidanceRule{}, fmt.Errorf("no avoidance rule %d", id)
+	}
+	return rules[0], nil
+}
+
+// Contradict records that proposalID merged successfully although rule id
+// matched it, and returns the weakened rule.
+func (m *LongTermMemory) Contradict(id int64, proposalID string, at time.Time) (AvoidanceRule, error) {
+	return m.updateRuleTx(id, func(r *AvoidanceRule) error {
+		*r = mergeEvidence(*r, AvoidanceRule{Scope: r.Scope, Target: r.Target, Evidence: []RuleEvidence{{
+			ProposalID: proposalID, Kind: EvidenceSuccess, Condition: "merged successfully", SeenAt: at,
+		}}})
+		return nil
+	})
+}
+
+// Forget takes a rule out of force, keeping it and its evidence so the same
+// failures do not teach it again. Forgetting also unpins it.
+func (m *LongTermMemory) Forget(id int64, why string) (AvoidanceRule, error) {
+	return m.updateRuleTx(id, func(r *AvoidanceRule) error {
+		if r.ForgottenAt != nil {
+			return fmt.Errorf("avoidance rule %d is already forgotten", id)
+		}
+		now := time.Now()
+		r.Pinned, r.ForgottenAt, r.ForgottenWhy = false, &now, why
+		return nil
+	})
+}
+
+// SetPinned pins or unpins a rule in force.
+func (m *LongTermMemory) SetPinned(id int64, pinned bool) (AvoidanceRule, error) {
+	return m.updateRuleTx(id, func(r *AvoidanceRule) error {
+		if r.ForgottenAt != nil {
+			return fmt.Errorf("avoidance rule %d is forgotten", id)
+		}
+		r.Pinned = pinned
+		r.refresh(time.Now())
+		return nil
+	})
+}
+
+// updateRuleTx applies change to rule id and stores the result.
+func (m *LongTermMemory) updateRuleTx(id int64, change func(r *AvoidanceRule) error) (AvoidanceRule, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return AvoidanceRule{}, err
+	}
+	defer tx.Rollback()
+	rules, err := queryRules(tx, ` WHERE id = ?`, id)
+	if err != nil {
+		return AvoidanceRule{}, err
+	}
+	if len(rules) == 0 {
+		return AvoidanceRule{}, fmt.Errorf("no avoidance rule %d", id)
+	}
+	r := rules[0]
+	if err := change(&r); err != nil {
+		return AvoidanceRule{}, err
+	}
+	if err := updateRule(tx, r); err != nil {
+		return AvoidanceRule{}, err
+	}
+	if err := writeEvidence(tx, r); err != nil {
+		return AvoidanceRule{}, err
+	}
+	return r, tx.Commit()
+}
+
+// RecordFailure stores a failed proposal. A proposal that fails again, such
+// as one rolled back after an earlier rejection was overridden, replaces its
+// record and is learned from anew.
+func (m *LongTermMemory) RecordFailure(f FailedProposal) error {
+	card, err := json.Marshal(f.Card)
+	if err != nil {
+		return err
+	}
+	var report sql.NullString
+	if f.Report != nil {
+		raw, err := json.Marshal(f.Report)
+		if err != nil {
+			return err
+		}
+		report = sql.NullString{String: string(raw), Valid: true}
+	}
+	if f.FailedAt.IsZero() {
+		f.FailedAt = time.Now()
+	}
+	_, err = m.db.Exec(`INSERT OR REPLACE INTO failed_proposals
+			(proposal_id, module, reason, card, verification_report, failed_at, consolidated_at)
+		VALUES (?, ?, ?, ?, ?, ?, NULL)`,
+		f.Card.ProposalID, f.Card.TargetModule, f.Reason, string(card), report, f.FailedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record failure of %s: %v", f.Card.ProposalID, err)
+	}
+	return nil
+}
+
+// Failures returns the failed proposals matching q, newest first; q's
+// SourceProposal selects by proposal ID.
+func (m *LongTermMemory) Failures(q MemoryQuery) ([]FailedProposal, error) {
+	clause, args := q.where("module", "proposal_id", "failed_at")
+	return m.queryFailures(clause, args...)
+}
+
+// PendingFailures returns the failures no dream cycle has learned from yet,
+// oldest first.
+func (m *LongTermMemory) PendingFailures() ([]FailedProposal, error) {
+	return m.queryFailures(` WHERE consolidated_at IS NULL ORDER BY failed_at`)
+}
+
+// PendingCount returns how many failures await a dream cycle.
+func (m *LongTermMemory) PendingCount() (int, error) {
+	var n int
+	err := m.db.QueryRow(`SELECT COUNT(*) FROM failed_proposals WHERE consolidated_at IS NULL`).Scan(&n)
+	return n, err
+}
+
+// MarkConsolidated records that a dream cycle has learned from a failure.
+func (m *LongTermMemory) MarkConsolidated(proposalID string) error {
+	_, err := m.db.Exec(`UPDATE failed_proposals SET consolidated_at = ? WHERE proposal_id = ?`, time.Now().UTC(), proposalID)
+	return err
+}
+
+func (m *LongTermMemory) queryFailures(clause string, args ...any) ([]FailedProposal, error) {
+	rows, err := m.db.Query(`SELECT reason, card, verification_report, failed_at, consolidated_at
+		FROM failed_proposals`+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []FailedProposal
+	for rows.Next() {
+		var f FailedProposal
+		var card string
+		var report sql.NullString
+		var consolidated sql.NullTime
+		if err := rows.Scan(&f.Reason, &card, &report, &f.FailedAt, &consolidated); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(card), &f.Card); err != nil {
+			return nil, fmt.Errorf("corrupt Decision Card in long-term memory: %v", err)
+		}
+		if report.Valid {
+			f.Report = &VerificationReport{}
+			if err := json.Unmarshal([]byte(report.String), f.Report); err != nil {
+				return nil, fmt.Errorf("corrupt verification report for %s: %v", f.Card.ProposalID, err)
+			}
+		}
+		if consolidated.Valid {
+			f.ConsolidatedAt = &consolidated.Time
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
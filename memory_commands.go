@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseMemoryQuery reads module=, proposal=, since= (an age such as 72h)
+// and limit= filters.
+func parseMemoryQuery(args string) (MemoryQuery, error) {
+	var q MemoryQuery
+	for _, field := range strings.Fields(args) {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || value == "" {
+			return q, fmt.Errorf("expected key=value, got %q", field)
+		}
+		switch key {
+		case "module":
+			q.Module = value
+		case "proposal":
+			q.SourceProposal = value
+		case "since":
+			age, err := time.ParseDuration(value)
+			if err != nil || age <= 0 {
+				return q, fmt.Errorf("since must be a positive age such as 72h, got %q", value)
+			}
+			q.Since = time.Now().Add(-age)
+		case "limit":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return q, fmt.Errorf("limit must be a positive number, got %q", value)
+			}
+			q.Limit = n
+		default:
+			return q, fmt.Errorf("unknown filter %q", key)
+		}
+	}
+	return q, nil
+}
+
+// memoryHandler prints the avoidance rules and failed proposals in
+// long-term memory that match the filters.
+func memoryHandler(args string) {
+	q, err := parseMemoryQuery(args)
+	if err != nil {
+		fmt.Printf("Error: %v. Usage: /memory [module=<name>] [proposal=<ID>] [since=<age>] [limit=<N>]\n", err)
+		return
+	}
+	store := memoryConsolidator.Store
+	rules, err := store.Rules(q)
+	if err != nil {
+		fmt.Printf("SIE-∞ Error: Could not read avoidance rules: %v\n", err)
+		return
+	}
+	failures, err := store.Failures(q)
+	if err != nil {
+		fmt.Printf("SIE-∞ Error: Could not read failed proposals: %v\n", err)
+		return
+	}
+
+	fmt.Printf("--- Avoidance Rules (%d) ---\n", len(rules))
+	for _, r := range rules {
+		fmt.Printf("%s  %-8s %-30s %.2f  %2d failure(s)  %s\n", r.LastSeen.Local().Format("2006-01-02 15:04"),
+			r.Scope, snippet(r.Target, 30), r.Confidence, r.EvidenceCount, ruleState(r))
+		fmt.Printf("%16s  %s\n", "", r.Condition)
+	}
+	fmt.Printf("--- Failed Proposals (%d) ---\n", len(failures))
+	for _, f := range failures {
+		state := "pending"
+		if f.ConsolidatedAt != nil {
+			state = "learned " + f.ConsolidatedAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%s  %-36s %-20s %s\n", f.FailedAt.Local().Format("2006-01-02 15:04"), f.Card.ProposalID, f.Card.TargetModule, state)
+		fmt.Printf("%16s  %s\n", "", snippet(f.Reason, 200))
+		if f.Report != nil && !f.Report.Passed && !strings.Contains(f.Reason, f.Report.FailureReason) {
+			fmt.Printf("%16s  verification: %s\n", "", snippet(f.Report.FailureReason, 200))
+		}
+	}
+}
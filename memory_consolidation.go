@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"sync"
 	"time"
@@ -9,40 +11,199 @@ import (
 // MemoryConsolidator handles the "dreaming" cycle.
 type MemoryConsolidator struct {
 	// Rules learned from past failures.
-	AvoidanceRules []string
+	AvoidanceRules []AvoidanceRule
 	mutex          sync.RWMutex
+	cycle          sync.Mutex // Held for the whole of a dream cycle
+	// Knowledge receives the facts behind each new rule, if set.
+	Knowledge *KnowledgeGraph
+	// Store keeps rules and failures across restarts, if set.
+	Store *LongTermMemory
+	// PackageDir is the package whose current sources tell ordinary
+	// imports and calls from those only failures use.
+	PackageDir string
 }
 
 func NewMemoryConsolidator() *MemoryConsolidator {
 	return &MemoryConsolidator{
-		AvoidanceRules: make([]string, 0),
+		AvoidanceRules: make([]AvoidanceRule, 0),
+		PackageDir:     ".",
 	}
 }
 
-// DreamCycle simulates the process of learning from past failures.
-func (mc *MemoryConsolidator) DreamCycle(failedProposals []DecisionCard) {
-	go func() {
-		log.Println("Dream Cycle Initiated: Consolidating memories...")
-		time.Sleep(10 * time.Second) // Simulate time-intensive analysis
+// Load replaces the in-memory rules with those in the store.
+func (mc *MemoryConsolidator) Load() error {
+	rules, err := mc.Store.Rules(MemoryQuery{})
+	if err != nil {
+		return fmt.Errorf("failed to load avoidance rules: %v", err)
+	}
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	mc.AvoidanceRules = rules
+	return nil
+}
+
+// RecordFailure remembers a failed proposal for a later dream cycle. Without
+// a store it does nothing.
+func (mc *MemoryConsolidator) RecordFailure(card DecisionCard, reason string, report *VerificationReport) error {
+	if mc.Store == nil {
+		return nil
+	}
+	return mc.Store.RecordFailure(FailedProposal{Card: card, Reason: reason, Report: report})
+}
+
+// DreamProgress is how far a dream cycle has got.
+type DreamProgress struct {
+	Stage string // starting, loading, generalising, saving, forgetting or marking
+	Done  int
+	Total int
+}
+
+// DreamResult summarises one dream cycle.
+type DreamResult struct {
+	Trigger      DreamTrigger
+	StartedAt    time.Time
+	FinishedAt   time.Time
+	Failures     int   // Pending failures the cycle consolidated
+	Learned      int   // New rules, and forgotten ones new failures revived
+	Strengthened int   // Existing rules that gained evidence
+	Forgotten    int   // Rules that decayed below forgetThreshold
+	Err          error // Why the cycle stopped early, if it did
+	Aborted      bool  // Stopped by the operator
+}
+
+// DreamCycle learns from the failures pending in long-term memory, together
+// with every failure already consolidated, reporting each step to progress if
+// it is set. Only one cycle runs at a time; a second caller waits. Cancelling
+// ctx stops the cycle between rules: rules saved so far stay, and the
+// failures stay pending, so the next cycle merges the same evidence again.
+func (mc *MemoryConsolidator) DreamCycle(ctx context.Context, progress func(DreamProgress)) (DreamResult, error) {
+	mc.cycle.Lock()
+	defer mc.cycle.Unlock()
+	report := func(stage string, done, total int) {
+		if progress != nil {
+			progress(DreamProgress{Stage: stage, Done: done, Total: total})
+		}
+	}
+	result := DreamResult{StartedAt: time.Now()}
+	if mc.Store == nil {
+		return result, nil
+	}
+
+	report("loading", 0, 0)
+	pending, err := mc.Store.PendingFailures()
+	if err != nil {
+		return result, fmt.Errorf("failed to load pending failures: %v", err)
+	}
+	history, err := mc.Store.Failures(MemoryQuery{})
+	if err != nil {
+		return result, fmt.Errorf("failed to load past failures: %v", err)
+	}
+	baseline, err := readPackageSources(mc.PackageDir)
+	if err != nil {
+		log.Printf("Dream Cycle: package sources not read, so every import and call is generalised: %v", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
 
-		mc.mutex.Lock()
-		defer mc.mutex.Unlock()
+	report("generalising", 0, len(history))
+	learned := generaliseFailures(history, baseline)
+	for i, rule := range learned {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		report("saving", i, len(learned))
+		saved, change, err := mc.Store.SaveRule(rule)
+		if err != nil {
+			return result, fmt.Errorf("failed to save avoidance rule %s: %v", rule.Key(), err)
+		}
+		switch change {
+		case RuleCreated, RuleRevived:
+			result.Learned++
+			if change == RuleRevived {
+				log.Printf("Forgotten Avoidance Rule Relearned: %s", saved)
+			} else {
+				log.Printf("New Avoidance Rule Learned: %s", saved)
+			}
+			if mc.Knowledge != nil {
+				if _, err := mc.Knowledge.AddFacts(FactsFromAvoidanceRule(saved)); err != nil {
+					log.Printf("Avoidance rule not added to the knowledge graph: %v", err)
+				}
+			}
+		case RuleStrengthened:
+			result.Strengthened++
+		}
+	}
+
+	// Rules nothing has reinforced for long enough fade out.
+	rules, err := mc.Store.Rules(MemoryQuery{})
+	if err != nil {
+		return result, fmt.Errorf("failed to load avoidance rules: %v", err)
+	}
+	for i, r := range rules {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		report("forgetting", i, len(rules))
+		if r.Pinned || r.Confidence >= forgetThreshold {
+			continue
+		}
+		if _, err := mc.Store.Forget(r.ID, fmt.Sprintf("decayed to confidence %.2f", r.Confidence)); err != nil {
+			return result, fmt.Errorf("failed to forget avoidance rule %d: %v", r.ID, err)
+		}
+		log.Printf("Avoidance Rule Forgotten: %s", r)
+		result.Forgotten++
+	}
 
-		for _, proposal := range failedProposals {
-			// A real implementation would involve complex analysis to generalize failure modes.
-			// Here, we create a simple rule based on the failed proposal's target module.
-			rule := "Avoid modifications to " + proposal.TargetModule + " that resulted in low RAR."
-			mc.AvoidanceRules = append(mc.AvoidanceRules, rule)
-			log.Printf("New Avoidance Rule Learned: %s", rule)
+	for i, f := range pending {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		report("marking", i, len(pending))
+		if err := mc.Store.MarkConsolidated(f.Card.ProposalID); err != nil {
+			return result, fmt.Errorf("failed to mark %s as consolidated: %v", f.Card.ProposalID, err)
 		}
+		result.Failures++
+	}
+	report("marking", len(pending), len(pending))
+	return result, mc.Load()
+}
 
-		log.Println("Dream Cycle Complete.")
-	}()
+// Contradict weakens every rule in force that matches a card which merged
+// successfully, and forgets those the merges since their last failure now
+// outnumber or that fall below forgetThreshold, unless they are pinned. It
+// returns the rules as they now stand.
+func (mc *MemoryConsolidator) Contradict(card DecisionCard) ([]AvoidanceRule, error) {
+	if mc.Store == nil {
+		return nil, nil
+	}
+	var changed []AvoidanceRule
+	now := time.Now()
+	for _, r := range MatchRules(mc.GetAvoidanceRules(), card) {
+		weakened, err := mc.Store.Contradict(r.ID, card.ProposalID, now)
+		if err != nil {
+			return changed, fmt.Errorf("failed to record the merge against avoidance rule %d: %v", r.ID, err)
+		}
+		if !weakened.Pinned && (weakened.Outweighed() || weakened.Confidence < forgetThreshold) {
+			if weakened, err = mc.Store.Forget(r.ID, "contradicted by the merge of "+card.ProposalID); err != nil {
+				return changed, fmt.Errorf("failed to forget avoidance rule %d: %v", r.ID, err)
+			}
+		}
+		changed = append(changed, weakened)
+	}
+	return changed, mc.Load()
 }
 
-// GetAvoidanceRules safely returns the current set of avoidance rules.
-func (mc *MemoryConsolidator) GetAvoidanceRules() []string {
+// GetAvoidanceRules safely returns the rules in force, with their
+// confidence as of now.
+func (mc *MemoryConsolidator) GetAvoidanceRules() []AvoidanceRule {
 	mc.mutex.RLock()
 	defer mc.mutex.RUnlock()
-	return mc.AvoidanceRules
+	rules := append([]AvoidanceRule(nil), mc.AvoidanceRules...)
+	now := time.Now()
+	for i := range rules {
+		rules[i].Evidence = append([]RuleEvidence(nil), rules[i].Evidence...)
+		rules[i].refresh(now)
+	}
+	return rules
 }
@@ -1,47 +1,239 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
 // MergeResult holds the outcome of the merge operation for the Meta-Cognitive Loop.
 type MergeResult struct {
 	Success              bool
+	ProposalID           string
 	OriginalRequest      string
 	GeneratedCode        string
 	TimeToImplementation time.Duration
+	Branch               string              // Proposal branch the change was committed on
+	Commit               string              // Commit fast-forwarded onto the main branch
+	Report               *VerificationReport // Verification run on the proposal branch
+	ImplementTime        time.Duration       // 𝒯_impl: generation and repair, before approval
+	RepairIterations     int                 // Repair rounds the proposal needed
+	PredictedEpsilonGain float64             // From the proposal's Decision Card
+	PredictedIGain       float64
 }
 
-// Merge safely applies the verified code and triggers the Meta-Cognitive Loop.
-func Merge(newFileName, newFileContent, serverModContent, originalRequest string, startTime time.Time) (*MergeResult, error) {
-	// In a real system, this would be a highly complex and careful process,
-	// likely involving creating a new git branch, applying the changes,
-	// and then using a blue-green deployment strategy.
+// proposalTrailer marks merge commits so Rollback can find them again.
+const proposalTrailer = "Proposal-ID"
 
-	// 1. Write the new capability file.
-	fmt.Printf("Merge: Writing new file: %s\n", newFileName)
-	if err := os.WriteFile(newFileName, []byte(newFileContent), 0644); err != nil {
-		return nil, fmt.Errorf("failed to write new file: %v", err)
+// GitMerger applies proposals through git: every proposal is committed on its
+// own branch, verified there, and only then fast-forwarded onto the branch
+// checked out in RepoDir. Each merge is a single commit that Rollback can
+// revert.
+type GitMerger struct {
+	RepoDir      string
+	BranchPrefix string
+	Policy       *DependencyPolicy
+	Sandbox      SandboxConfig
+}
+
+// NewGitMerger creates a merger for the repository at repoDir.
+func NewGitMerger(repoDir string, policy *DependencyPolicy, sandbox SandboxConfig) *GitMerger {
+	return &GitMerger{
+		RepoDir:      repoDir,
+		BranchPrefix: "sie/proposal-",
+		Policy:       policy,
+		Sandbox:      sandbox,
 	}
+}
 
-	// 2. Modify the server to integrate the new capability.
-	fmt.Println("Merge: Modifying server.go to integrate new handler...")
-	// A real implementation would parse the server.go file and inject the new handler.
-	// For simulation, we'll just log that it's happening.
-	fmt.Printf("--- Integration for server.go ---\n%s\n------------------------------------\n", serverModContent)
+// Merge commits the proposal on a dedicated branch, verifies that branch in
+// the sandbox and fast-forwards the main branch onto it. The main branch and
+// the working tree are left untouched unless verification passes.
+func (gm *GitMerger) Merge(ctx context.Context, p *Proposal, startTime time.Time) (*MergeResult, error) {
+	mainBranch, err := gm.git(ctx, gm.RepoDir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("not a usable git repository: %v", err)
+	}
+	if mainBranch == "HEAD" {
+		return nil, fmt.Errorf("HEAD is detached; check out the main branch before merging")
+	}
+	if dirty, err := gm.git(ctx, gm.RepoDir, "status", "--porcelain", "--untracked-files=no"); err != nil {
+		return nil, err
+	} else if dirty != "" {
+		return nil, fmt.Errorf("working tree has uncommitted changes:\n%s", dirty)
+	}
 
-	timeToImpl := time.Since(startTime)
-	fmt.Printf("Merge: Code merged successfully. Time-to-Implementation: %v\n", timeToImpl)
+	branch := gm.BranchPrefix + p.ID
+	worktree, err := os.MkdirTemp("", "sie-merge-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create worktree directory: %v", err)
+	}
+	os.Remove(worktree) // git worktree add wants to create it itself
+	fmt.Printf("Merge: Creating branch %s from %s...\n", branch, mainBranch)
+	if _, err := gm.git(ctx, gm.RepoDir, "worktree", "add", "-b", branch, worktree, mainBranch); err != nil {
+		return nil, err
+	}
+	defer gm.git(context.Background(), gm.RepoDir, "worktree", "remove", "--force", worktree)
+
+	// 1. Write the proposal's files, its tests, its file operations and the
+	// server.go integration on the branch and commit them.
+	change, err := proposalChange(worktree, p)
+	if err != nil {
+		return nil, err
+	}
+	files := change.After
+	for _, name := range change.Paths() {
+		path := filepath.Join(worktree, filepath.FromSlash(name))
+		if files[name] == "" {
+			fmt.Printf("Merge: Deleting file: %s\n", name)
+			if _, err := gm.git(ctx, worktree, "rm", "--quiet", "--ignore-unmatch", "--", name); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if name == serverFile {
+			fmt.Println("Merge: Modifying server.go to integrate new capability...")
+		} else {
+			fmt.Printf("Merge: Writing file: %s\n", name)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %v", name, err)
+		}
+		if _, err := gm.git(ctx, worktree, "add", "--", name); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := gm.git(ctx, worktree, "commit", "--quiet", "-m", commitMessage(p)); err != nil {
+		return nil, err
+	}
+	commit, err := gm.git(ctx, worktree, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
 
-	// 3. Prepare the result for the Meta-Cognitive Loop
 	result := &MergeResult{
-		Success:              true,
-		OriginalRequest:      originalRequest,
-		GeneratedCode:        newFileContent,
-		TimeToImplementation: timeToImpl,
+		ProposalID:           p.ID,
+		OriginalRequest:      p.CapabilityDesc,
+		GeneratedCode:        p.NewFileContent,
+		Branch:               branch,
+		Commit:               commit,
+		ImplementTime:        time.Duration(p.TimeTakenToImplement * float64(time.Second)),
+		RepairIterations:     p.RepairIterations,
+		PredictedEpsilonGain: p.PredictedEpsilonGain,
+		PredictedIGain:       p.PredictedIGain,
+	}
+
+	// 3. Verify the branch exactly as committed.
+	fmt.Printf("Merge: Verifying %s at %s...\n", branch, snippet(commit, 12))
+	sandbox := gm.Sandbox
+	sandbox.ModuleDir = worktree
+	report, err := verifyChange(ctx, p, change, gm.Policy, sandbox)
+	if err != nil {
+		return result, fmt.Errorf("verification could not run on %s: %v", branch, err)
+	}
+	result.Report = report
+	if !report.Passed {
+		return result, fmt.Errorf("verification failed on %s: %s", branch, report.FailureReason)
+	}
+
+	// 4. Fast-forward the main branch; this also updates the working tree.
+	if _, err := gm.git(ctx, gm.RepoDir, "merge", "--ff-only", "--quiet", branch); err != nil {
+		return result, fmt.Errorf("failed to fast-forward %s: %v", mainBranch, err)
 	}
 
+	result.Success = true
+	result.TimeToImplementation = time.Since(startTime)
+	fmt.Printf("Merge: %s fast-forwarded to %s. Time-to-Implementation: %v\n", mainBranch, snippet(commit, 12), result.TimeToImplementation)
 	return result, nil
 }
+
+// Rollback reverts the commit that merged proposalID with a new commit on the
+// current branch, and returns the hash of that revert commit.
+func (gm *GitMerger) Rollback(ctx context.Context, proposalID string) (string, error) {
+	if dirty, err := gm.git(ctx, gm.RepoDir, "status", "--porcelain", "--untracked-files=no"); err != nil {
+		return "", err
+	} else if dirty != "" {
+		return "", fmt.Errorf("working tree has uncommitted changes:\n%s", dirty)
+	}
+
+	commit, err := gm.findMergeCommit(ctx, proposalID)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := gm.git(ctx, gm.RepoDir, "revert", "--no-commit", commit); err != nil {
+		gm.git(context.Background(), gm.RepoDir, "revert", "--abort")
+		return "", fmt.Errorf("failed to revert %s: %v", snippet(commit, 12), err)
+	}
+	message := fmt.Sprintf("SIE-∞: Roll back proposal %s\n\nThis reverts commit %s.\n\n%s: %s\n", proposalID, commit, proposalTrailer, proposalID)
+	if _, err := gm.git(ctx, gm.RepoDir, "commit", "--quiet", "-m", message); err != nil {
+		gm.git(context.Background(), gm.RepoDir, "revert", "--abort")
+		return "", err
+	}
+	return gm.git(ctx, gm.RepoDir, "rev-parse", "HEAD")
+}
+
+// findMergeCommit locates the commit that merged proposalID on the current
+// branch, ignoring any earlier rollback commits.
+func (gm *GitMerger) findMergeCommit(ctx context.Context, proposalID string) (string, error) {
+	out, err := gm.git(ctx, gm.RepoDir, "log", "--fixed-strings", "--format=%H %s",
+		"--grep", proposalTrailer+": "+proposalID, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		hash, subject, _ := strings.Cut(line, " ")
+		if hash != "" && !strings.HasPrefix(subject, "SIE-∞: Roll back") {
+			return hash, nil
+		}
+	}
+	return "", fmt.Errorf("no merge commit for proposal %s on the current branch", proposalID)
+}
+
+// commitMessage records the proposal's ID, rationale and predicted metrics.
+func commitMessage(p *Proposal) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "SIE-∞: %s\n\n", p.CapabilityDesc)
+	fmt.Fprintf(&b, "Rationale: %s\n\n", p.Rationale)
+	fmt.Fprintf(&b, "Predicted ε gain: +%.4f\n", p.PredictedEpsilonGain)
+	fmt.Fprintf(&b, "Predicted 𝓘 gain: +%.4f\n", p.PredictedIGain)
+	fmt.Fprintf(&b, "Risk score: %.2f%%\n", p.CalculatedRiskScore*100)
+	fmt.Fprintf(&b, "Dependency risk: %s\n", p.DependencyRiskMap.Verdict())
+	fmt.Fprintf(&b, "Time to implement: %.2fs (%d repair round(s))\n\n", p.TimeTakenToImplement, p.RepairIterations)
+	fmt.Fprintf(&b, "%s: %s\n", proposalTrailer, p.ID)
+	return b.String()
+}
+
+// git runs a git command in dir and returns its trimmed output. Commits are
+// attributed to SIE-∞ unless the environment already names an author.
+func (gm *GitMerger) git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	for _, kv := range []string{"GIT_AUTHOR_NAME=SIE-∞", "GIT_AUTHOR_EMAIL=sie@localhost", "GIT_COMMITTER_NAME=SIE-∞", "GIT_COMMITTER_EMAIL=sie@localhost"} {
+		if key, _, _ := strings.Cut(kv, "="); os.Getenv(key) == "" {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+		}
+		return "", fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// metricsHandler re-measures the Prime Axioms and prints the compression
+// breakdown behind ε.
+func metricsHandler() {
+	axiom := goalEngine.CalculateCurrentMetrics()
+	goalEngine.RecordSample("metrics", "")
+	fmt.Printf("Compression Efficiency (ε): %.4f\n", axiom.CompressionEfficiency)
+	fmt.Printf("Knowledge Integration (𝓘): %.4f\n", axiom.KnowledgeIntegrationScore)
+	report := goalEngine.Compression
+	if len(report.Sources) == 0 {
+		return
+	}
+	fmt.Printf("--- Compression (measured %s) ---\n", report.MeasuredAt.Local().Format("2006-01-02 15:04:05"))
+	fmt.Printf("%-16s %10s  %-7s %6s  %s\n", "SOURCE", "RAW", "BEST", "RATIO", "CODECS")
+	for _, sc := range report.Sources {
+		var codecs []string
+		for _, name := range []string{"flate", "lzw", "order0", "mdl"} {
+			if n, ok := sc.Sizes[name]; ok {
+				codecs = append(codecs, fmt.Sprintf("%s=%d", name, n))
+			}
+		}
+		fmt.Printf("%-16s %10d  %-7s %6.3f  %s\n", sc.Source, sc.RawBytes, sc.Best, sc.Ratio, strings.Join(codecs, " "))
+	}
+	fmt.Printf("Implementation: %d merged, mean 𝒯_impl %.2fs, mean repairs %.2f\n",
+		goalEngine.Implementation.Merged, goalEngine.Implementation.MeanTimeToImplement(), goalEngine.Implementation.MeanRepairIterations())
+}
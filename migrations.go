@@ -0,0 +1,75 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// schemaMigration is one numbered step of a component's schema. Steps are
+// applied in order, each exactly once, and never edited after release:
+// changes go into a new step.
+type schemaMigration struct {
+	Version    int
+	Name       string
+	Statements []string
+	// Apply, if set, runs after the statements for data changes SQL alone
+	// cannot express.
+	Apply func(tx *sql.Tx) error
+}
+
+// applyMigrations brings component's tables up to the latest step, running
+// every step not yet recorded in schema_migrations in its own transaction.
+func applyMigrations(db *sql.DB, component string, migrations []schemaMigration) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		component  TEXT NOT NULL,
+		version    INTEGER NOT NULL,
+		name       TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (component, version)
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %v", err)
+	}
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations WHERE component = ?`,
+		component).Scan(&current); err != nil {
+		return err
+	}
+	if current > len(migrations) {
+		return fmt.Errorf("%s schema is at version %d, newer than this build knows (%d)", component, current, len(migrations))
+	}
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return fmt.Errorf("%s migration %q has version %d, expected %d", component, m.Name, m.Version, i+1)
+		}
+		if m.Version <= current {
+			continue
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		for _, stmt := range m.Statements {
+			if _, err := tx.Exec(stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("%s migration %d (%s) failed: %v", component, m.Version, m.Name, err)
+			}
+		}
+		if m.Apply != nil {
+			if err := m.Apply(tx); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("%s migration %d (%s) failed: %v", component, m.Version, m.Name, err)
+			}
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (component, version, name, applied_at) VALUES (?, ?, ?, ?)`,
+			component, m.Version, m.Name, time.Now().UTC()); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ChatMessage is a single turn in a conversation with a model provider.
+type ChatMessage struct {
+	Role string // "user" or "model"
+	Text string
+}
+
+// MediaPart is an inline binary attachment for multimodal prompts.
+type MediaPart struct {
+	MIMEType string
+	Data     []byte
+}
+
+// ModelProvider is the abstraction every LLM backend must satisfy.
+// The SelfModificationEngine, the /implement command and the chat path
+// all talk to a ModelProvider instead of a concrete SDK client.
+type ModelProvider interface {
+	// Name identifies the backend and model, e.g. "gemini/gemini-1.5-flash".
+	Name() string
+	// Generate runs a single-shot text completion.
+	Generate(ctx context.Context, prompt string) (string, error)
+	// Chat continues a conversation given the prior history.
+	Chat(ctx context.Context, history []ChatMessage, message string) (string, error)
+	// Multimodal runs a prompt with inline media attachments.
+	Multimodal(ctx context.Context, prompt string, media []MediaPart) (string, error)
+	// CountTokens estimates the number of tokens the backend would charge for text.
+	CountTokens(ctx context.Context, text string) (int, error)
+}
+
+// ProviderConfig selects and configures a ModelProvider.
+type ProviderConfig struct {
+	Kind    string // "gemini", "openai" or "fake"
+	Model   string // Model name passed to the backend
+	APIKey  string // Credential for remote backends
+	BaseURL string // Endpoint for OpenAI-compatible backends
+	// EmbeddingModel is the backend's model for embeddings, if it has one.
+	EmbeddingModel string
+
+	CassetteMode CassetteMode // off, record or replay
+	CassettePath string       // Cassette file used by record and replay
+}
+
+// LoadProviderConfig reads the provider selection from the environment.
+//
+//	SIE_PROVIDER        gemini (default), openai or fake; the fake provider
+//	                    is only used when selected explicitly
+//	SIE_MODEL           model name override
+//	GEMINI_API_KEY      credential for the gemini provider
+//	OPENAI_API_KEY      credential for the openai provider
+//	OPENAI_BASE_URL     endpoint for the openai provider
+//	SIE_EMBEDDING_MODEL embedding model override
+//	SIE_CASSETTE_MODE   off (default), record or replay
+//	SIE_CASSETTE        cassette file, default ./llm_cassette.json
+func LoadProviderConfig() ProviderConfig {
+	cfg := ProviderConfig{
+		Kind:           strings.ToLower(strings.TrimSpace(os.Getenv("SIE_PROVIDER"))),
+		Model:          os.Getenv("SIE_MODEL"),
+		EmbeddingModel: os.Getenv("SIE_EMBEDDING_MODEL"),
+		CassetteMode:   CassetteMode(strings.ToLower(strings.TrimSpace(os.Getenv("SIE_CASSETTE_MODE")))),
+		CassettePath:   os.Getenv("SIE_CASSETTE"),
+	}
+	if cfg.CassetteMode == "" {
+		cfg.CassetteMode = CassetteOff
+	}
+	if cfg.CassettePath == "" {
+		cfg.CassettePath = "./llm_cassette.json"
+	}
+	if cfg.Kind == "" {
+		cfg.Kind = "gemini"
+	}
+
+	switch cfg.Kind {
+	case "gemini":
+		cfg.APIKey = os.Getenv("GEMINI_API_KEY")
+		if cfg.Model == "" {
+			cfg.Model = "gemini-1.5-flash"
+		}
+		if cfg.EmbeddingModel == "" {
+			cfg.EmbeddingModel = "text-embedding-004"
+		}
+	case "openai":
+		cfg.APIKey = os.Getenv("OPENAI_API_KEY")
+		cfg.BaseURL = os.Getenv("OPENAI_BASE_URL")
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = "https://api.openai.com/v1"
+		}
+		if cfg.Model == "" {
+			cfg.Model = "gpt-4o-mini"
+		}
+		if cfg.EmbeddingModel == "" {
+			cfg.EmbeddingModel = "text-embedding-3-small"
+		}
+	case "fake":
+		if cfg.Model == "" {
+			cfg.Model = "offline"
+		}
+	}
+	return cfg
+}
+
+// NewModelProvider builds the provider described by cfg, wrapped in a
+// CassetteProvider when recording or replaying. Replay never constructs the
+// backend, so it works without credentials or network access.
+func NewModelProvider(ctx context.Context, cfg ProviderConfig) (ModelProvider, error) {
+	switch cfg.CassetteMode {
+	case "", CassetteOff:
+		return newBackendProvider(ctx, cfg)
+	case CassetteReplay:
+		return NewCassetteProvider(nil, CassetteReplay, cfg.CassettePath)
+	default:
+		inner, err := newBackendProvider(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewCassetteProvider(inner, cfg.CassetteMode, cfg.CassettePath)
+	}
+}
+
+func newBackendProvider(ctx context.Context, cfg ProviderConfig) (ModelProvider, error) {
+	switch cfg.Kind {
+	case "gemini":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("gemini provider requires GEMINI_API_KEY (set SIE_PROVIDER=fake to run offline with canned answers)")
+		}
+		p, err := NewGeminiProvider(ctx, cfg.APIKey, cfg.Model)
+		if err != nil {
+			return nil, err
+		}
+		p.embeddingModel = cfg.EmbeddingModel
+		return p, nil
+	case "openai":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("openai provider requires OPENAI_API_KEY")
+		}
+		p := NewOpenAIProvider(cfg.BaseURL, cfg.APIKey, cfg.Model)
+		p.embeddingModel = cfg.EmbeddingModel
+		return p, nil
+	case "fake":
+		return NewFakeProvider(cfg.Model), nil
+	default:
+		return nil, fmt.Errorf("unknown model provider %q", cfg.Kind)
+	}
+}
+
+// estimateTokens is the fallback token counter used when a backend offers none.
+// It follows the common ~4 characters per token rule of thumb.
+func estimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	return (len(text) + 3) / 4
+}
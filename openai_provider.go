@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAIProvider talks to any OpenAI-compatible /chat/completions endpoint
+// (OpenAI itself, vLLM, llama.cpp server, Ollama, LM Studio, ...).
+type OpenAIProvider struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+	// embeddingModel is used by Embed; "" means the backend cannot embed.
+	embeddingModel string
+}
+
+// NewOpenAIProvider creates an OpenAI-compatible ModelProvider.
+func NewOpenAIProvider(baseURL, apiKey, model string) *OpenAIProvider {
+	return &OpenAIProvider{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		model:      model,
+		httpClient: &http.Client{Timeout: 120 * time.Second},
+	}
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"` // string or []openAIContentPart
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+type openAIChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func (o *OpenAIProvider) Name() string { return "openai/" + o.model }
+
+func (o *OpenAIProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	return o.complete(ctx, []openAIMessage{{Role: "user", Content: prompt}})
+}
+
+func (o *OpenAIProvider) Chat(ctx context.Context, history []ChatMessage, message string) (string, error) {
+	msgs := make([]openAIMessage, 0, len(history)+1)
+	for _, m := range history {
+		role := "user"
+		if m.Role == "model" {
+			role = "assistant"
+		}
+		msgs = append(msgs, openAIMessage{Role: role, Content: m.Text})
+	}
+	msgs = append(msgs, openAIMessage{Role: "user", Content: message})
+	return o.complete(ctx, msgs)
+}
+
+func (o *OpenAIProvider) Multimodal(ctx context.Context, prompt string, media []MediaPart) (string, error) {
+	parts := []openAIContentPart{{Type: "text", Text: prompt}}
+	for _, m := range media {
+		url := fmt.Sprintf("data:%s;base64,%s", m.MIMEType, base64.StdEncoding.EncodeToString(m.Data))
+		parts = append(parts, openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{URL: url}})
+	}
+	return o.complete(ctx, []openAIMessage{{Role: "user", Content: parts}})
+}
+
+// CountTokens uses a local estimate; OpenAI-compatible servers do not share a
+// common tokenization endpoint.
+func (o *OpenAIProvider) CountTokens(ctx context.Context, text string) (int, error) {
+	return estimateTokens(text), nil
+}
+
+func (o *OpenAIProvider) complete(ctx context.Context, msgs []openAIMessage) (string, error) {
+	body, err := json.Marshal(openAIChatRequest{Model: o.model, Messages: msgs})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+o.apiKey)
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("openai request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read openai response: %v", err)
+	}
+
+	var out openAIChatResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return "", fmt.Errorf("failed to decode openai response (status %d): %v", resp.StatusCode, err)
+	}
+	if out.Error != nil {
+		return "", fmt.Errorf("openai error (status %d): %s", resp.StatusCode, out.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("openai returned status %d", resp.StatusCode)
+	}
+	if len(out.Choices) == 0 {
+		return "", fmt.Errorf("openai response contained no choices")
+	}
+	return out.Choices[0].Message.Content, nil
+}
+
+type openAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func (o *OpenAIProvider) EmbeddingModel() string { return o.embeddingModel }
+
+// Embed calls the /embeddings endpoint.
+func (o *OpenAIProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if o.embeddingModel == "" {
+		return nil, fmt.Errorf("no embedding model configured")
+	}
+	body, err := json.Marshal(openAIEmbeddingRequest{Model: o.embeddingModel, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+o.apiKey)
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("openai embedding request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var out openAIEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode openai embedding response (status %d): %v", resp.StatusCode, err)
+	}
+	if out.Error != nil {
+		return nil, fmt.Errorf("openai error (status %d): %s", resp.StatusCode, out.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("openai returned status %d", resp.StatusCode)
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range out.Data {
+		if d.Index >= 0 && d.Index < len(vectors) {
+			vectors[d.Index] = d.Embedding
+		}
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("openai embedding response has no vector for input %d", i)
+		}
+	}
+	return vectors, nil
+}
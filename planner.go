@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"math/rand"
+	"sort"
 	"strings"
 
 	"github.com/google/uuid"
@@ -14,42 +20,95 @@ type DecisionCard struct {
 	TargetModule         string
 	Rationale            string
 	ActionCodeDiff       string
+	ProposedFiles        map[string]string // File name → full proposed content; "" deletes the file
 	PredictedEpsilonGain float64
 	PredictedIGain       float64
 	CalculatedRiskScore  float64
 	RiskAdjustedReward   float64
 }
 
+// PlannerMode selects how the planner produces Decision Cards.
+type PlannerMode string
+
+const (
+	// PlannerModel asks the model for a card and falls back to the heuristics
+	// when its answer is unusable.
+	PlannerModel PlannerMode = "model"
+	// PlannerHeuristic never consults the model.
+	PlannerHeuristic PlannerMode = "heuristic"
+)
+
+// Ranges the planner accepts for predicted gains and risk. They match what
+// the heuristic planner produces, so both modes are judged on one scale.
+const (
+	maxPlannedEpsilonGain = 0.1
+	maxPlannedIGain       = 0.05
+	maxPlannedRisk        = 1.0
+)
+
 // PlannerReasonor generates plans and proposals.
 type PlannerReasoner struct {
 	goalEngine *GoalEngine
 	memory     *MemoryConsolidator // Link to long-term memory
+	provider   ModelProvider
+	moduleDir  string // Package the planner reasons about
+	Mode       PlannerMode
+	recall     func(ctx context.Context, query string) ([]MemoryMatch, error)
+}
+
+func NewPlannerReasoner(ge *GoalEngine, mem *MemoryConsolidator, provider ModelProvider, moduleDir string) *PlannerReasoner {
+	mode := PlannerModel
+	if provider == nil {
+		mode = PlannerHeuristic
+	}
+	return &PlannerReasoner{goalEngine: ge, memory: mem, provider: provider, moduleDir: moduleDir, Mode: mode}
+}
+
+// SetRecallSource sets where the planner looks up past proposals and
+// conversations related to an anomaly.
+func (pr *PlannerReasoner) SetRecallSource(recall func(ctx context.Context, query string) ([]MemoryMatch, error)) {
+	pr.recall = recall
+}
+
+// PlanResult is a Decision Card and how the planner arrived at it.
+type PlanResult struct {
+	Card     DecisionCard
+	Source   PlannerMode
+	Problems []string // Why the model's card was rejected, if it was
 }
 
-func NewPlannerReasoner(ge *GoalEngine, mem *MemoryConsolidator) *PlannerReasoner {
-	return &PlannerReasoner{goalEngine: ge, memory: mem}
+// generateProposal plans a self-improvement proposal for an anomaly. In
+// model mode the card comes from the model, checked against the codebase;
+// any problem with it falls back to the heuristic planner.
+func (pr *PlannerReasoner) generateProposal(ctx context.Context, anomaly string) PlanResult {
+	if pr.Mode != PlannerModel || pr.provider == nil {
+		return PlanResult{Card: pr.heuristicProposal(anomaly), Source: PlannerHeuristic}
+	}
+	card, problems := pr.modelProposal(ctx, anomaly)
+	if len(problems) > 0 {
+		return PlanResult{Card: pr.heuristicProposal(anomaly), Source: PlannerHeuristic, Problems: problems}
+	}
+	return PlanResult{Card: card, Source: PlannerModel}
 }
 
-// generateProposal simulates the generation of a self-improvement proposal.
-func (pr *PlannerReasoner) generateProposal(anomaly string) DecisionCard {
+// heuristicProposal simulates the generation of a self-improvement proposal.
+func (pr *PlannerReasoner) heuristicProposal(anomaly string) DecisionCard {
 	// In a real implementation, this would be a complex reasoning process.
 	// Here, we simulate the generation of a proposal based on a detected anomaly.
 	targetModule := "HarmonicFoldingEngine"
 	action := "Refactor compression layer to use a more efficient algorithm."
 
 	// Check against Avoidance Rules from past failures.
-	for _, rule := range pr.memory.GetAvoidanceRules() {
-		if strings.Contains(rule, targetModule) {
-			// If a rule exists for this module, generate a different proposal.
-			targetModule = "MemoryCoreSystem"
-			action = "Optimize data indexing for faster retrieval."
-		}
+	if len(BlockingRules(pr.memory.GetAvoidanceRules(), DecisionCard{TargetModule: targetModule})) > 0 {
+		// If a rule refuses this module, generate a different proposal.
+		targetModule = "MemoryCoreSystem"
+		action = "Optimize data indexing for faster retrieval."
 	}
 
 	// Simulate the "Simulation Chamber"
-	predictedEpsilonGain := rand.Float64() * 0.1 // Predict a gain of 0-10%
-	predictedIGain := rand.Float64() * 0.05      // Predict a gain of 0-5%
-	riskScore := rand.Float64() * 0.25           // Predict a risk of 0-25%
+	predictedEpsilonGain := rand.Float64() * maxPlannedEpsilonGain // Predict a gain of 0-10%
+	predictedIGain := rand.Float64() * maxPlannedIGain             // Predict a gain of 0-5%
+	riskScore := rand.Float64() * 0.25                             // Predict a risk of 0-25%
 
 	rar := pr.goalEngine.CalculateRiskAdjustedReward(predictedEpsilonGain, predictedIGain, riskScore)
 
@@ -64,3 +123,248 @@ func (pr *PlannerReasoner) generateProposal(anomaly string) DecisionCard {
 		RiskAdjustedReward:   rar,
 	}
 }
+
+// plannedCard is the JSON the model answers with. Pointers tell a missing
+// field from a zero one.
+type plannedCard struct {
+	TargetModule         *string  `json:"TargetModule"`
+	Rationale            *string  `json:"Rationale"`
+	ActionCodeDiff       *string  `json:"ActionCodeDiff"`
+	PredictedEpsilonGain *float64 `json:"PredictedEpsilonGain"`
+	PredictedIGain       *float64 `json:"PredictedIGain"`
+	CalculatedRiskScore  *float64 `json:"CalculatedRiskScore"`
+}
+
+// decisionCardSchema is the JSON schema declared to the model.
+var decisionCardSchema = fmt.Sprintf(`{
+  "type": "object",
+  "additionalProperties": false,
+  "required": ["TargetModule", "Rationale", "ActionCodeDiff", "PredictedEpsilonGain", "PredictedIGain", "CalculatedRiskScore"],
+  "properties": {
+    "TargetModule": {"type": "string", "description": "A file or type listed in the module inventory"},
+    "Rationale": {"type": "string", "maxLength": 1000, "description": "How the change addresses the anomaly and serves ε or 𝓘"},
+    "ActionCodeDiff": {"type": "string", "description": "The change: a git-style unified diff, or a precise description of it"},
+    "PredictedEpsilonGain": {"type": "number", "minimum": 0, "maximum": %g},
+    "PredictedIGain": {"type": "number", "minimum": 0, "maximum": %g},
+    "CalculatedRiskScore": {"type": "number", "minimum": 0, "maximum": %g}
+  }
+}`, maxPlannedEpsilonGain, maxPlannedIGain, maxPlannedRisk)
+
+// modelProposal asks the model for a Decision Card and validates it. A
+// non-empty problem list means the card must not be used.
+func (pr *PlannerReasoner) modelProposal(ctx context.Context, anomaly string) (DecisionCard, []string) {
+	inventory, err := moduleInventory(pr.moduleDir)
+	if err != nil {
+		return DecisionCard{}, []string{fmt.Sprintf("failed to read the codebase: %v", err)}
+	}
+	var related []MemoryMatch
+	if pr.recall != nil {
+		// Recall only adds context; planning goes ahead without it.
+		related, _ = pr.recall(ctx, anomaly)
+	}
+	response, err := pr.provider.Generate(ctx, pr.plannerPrompt(anomaly, inventory, related))
+	if err != nil {
+		return DecisionCard{}, []string{fmt.Sprintf("model unavailable: %v", err)}
+	}
+	return pr.parsePlannedCard(response, inventory)
+}
+
+func (pr *PlannerReasoner) plannerPrompt(anomaly string, inventory []ModuleSummary, related []MemoryMatch) string {
+	var b strings.Builder
+	b.WriteString("You are the Planner/Reasoner of SIE-∞. Plan one self-improvement that addresses the anomaly below.\n\n")
+	fmt.Fprintf(&b, "**Anomaly:** %q\n\n", anomaly)
+	fmt.Fprintf(&b, "**Current Prime Axioms:** ε = %.4f, 𝓘 = %.4f\n\n",
+		pr.goalEngine.CurrentAxiom.CompressionEfficiency, pr.goalEngine.CurrentAxiom.KnowledgeIntegrationScore)
+	if rules := pr.memory.GetAvoidanceRules(); len(rules) > 0 {
+		fmt.Fprintf(&b, "**Avoidance Rules learned from past failures** (a card matching one with confidence ≥ %.2f is refused):\n", avoidanceThreshold)
+		for _, rule := range rules {
+			fmt.Fprintf(&b, "- %s\n", rule)
+		}
+		b.WriteString("\n")
+	}
+	if len(related) > 0 {
+		b.WriteString("**Related past proposals and conversations** (most similar first; learn from how they turned out):\n")
+		for _, m := range related {
+			fmt.Fprintf(&b, "- [%s %s, similarity %.2f] %s\n", m.Kind, m.Ref, m.Similarity, strings.Join(strings.Fields(snippet(m.Text, 600)), " "))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("**Module inventory** (file: declared types; functions):\n")
+	for _, m := range inventory {
+		fmt.Fprintf(&b, "- %s: %s; %s\n", m.File, dashIfEmpty(strings.Join(m.Types, ", ")), dashIfEmpty(strings.Join(m.Funcs, ", ")))
+	}
+	b.WriteString("\nAnswer with a single JSON object, and nothing else, that satisfies this decision_card_schema:\n")
+	b.WriteString(decisionCardSchema)
+	return b.String()
+}
+
+// parsePlannedCard decodes and range-checks the model's answer.
+func (pr *PlannerReasoner) parsePlannedCard(response string, inventory []ModuleSummary) (DecisionCard, []string) {
+	dec := json.NewDecoder(strings.NewReader(stripInnerFence(response)))
+	dec.DisallowUnknownFields()
+	var pc plannedCard
+	if err := dec.Decode(&pc); err != nil {
+		return DecisionCard{}, []string{fmt.Sprintf("answer is not a Decision Card JSON object: %v", err)}
+	}
+	if dec.More() {
+		return DecisionCard{}, []string{"answer has text after the JSON object"}
+	}
+
+	var problems []string
+	text := func(name string, v *string, limit int) string {
+		switch {
+		case v == nil:
+			problems = append(problems, fmt.Sprintf("missing field %s", name))
+		case strings.TrimSpace(*v) == "":
+			problems = append(problems, fmt.Sprintf("%s is empty", name))
+		case len(*v) > limit:
+			problems = append(problems, fmt.Sprintf("%s is longer than %d bytes", name, limit))
+		default:
+			return strings.TrimSpace(*v)
+		}
+		return ""
+	}
+	number := func(name string, v *float64, limit float64) float64 {
+		switch {
+		case v == nil:
+			problems = append(problems, fmt.Sprintf("missing field %s", name))
+		case *v < 0 || *v > limit:
+			problems = append(problems, fmt.Sprintf("%s = %g is outside [0, %g]", name, *v, limit))
+		default:
+			return *v
+		}
+		return 0
+	}
+
+	card := DecisionCard{
+		ProposalID:           uuid.New().String(),
+		TargetModule:         text("TargetModule", pc.TargetModule, 200),
+		Rationale:            text("Rationale", pc.Rationale, 1000),
+		ActionCodeDiff:       text("ActionCodeDiff", pc.ActionCodeDiff, 64<<10),
+		PredictedEpsilonGain: number("PredictedEpsilonGain", pc.PredictedEpsilonGain, maxPlannedEpsilonGain),
+		PredictedIGain:       number("PredictedIGain", pc.PredictedIGain, maxPlannedIGain),
+		CalculatedRiskScore:  number("CalculatedRiskScore", pc.CalculatedRiskScore, maxPlannedRisk),
+	}
+
+	if card.TargetModule != "" {
+		if !inventoryHas(inventory, card.TargetModule) {
+			problems = append(problems, fmt.Sprintf("TargetModule %q is not a file or type in the codebase", card.TargetModule))
+		}
+	}
+	if strings.HasPrefix(card.ActionCodeDiff, "--- ") {
+		files, err := plannedFiles(pr.moduleDir, card.ActionCodeDiff)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("ActionCodeDiff does not apply to the codebase: %v", err))
+		}
+		card.ProposedFiles = files
+	}
+	for _, rule := range BlockingRules(pr.memory.GetAvoidanceRules(), card) {
+		problems = append(problems, fmt.Sprintf("card is refused by avoidance rule: %s", rule))
+	}
+	if len(problems) > 0 {
+		return DecisionCard{}, problems
+	}
+	card.RiskAdjustedReward = pr.goalEngine.CalculateRiskAdjustedReward(card.PredictedEpsilonGain, card.PredictedIGain, card.CalculatedRiskScore)
+	return card, nil
+}
+
+// plannedFiles applies a unified diff to the package in dir and returns the
+// full content of every file it touches, so the invariants judge the card
+// by what it would actually change.
+func plannedFiles(dir, diff string) (map[string]string, error) {
+	ops, err := ParsePatch(diff)
+	if err != nil {
+		return nil, err
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("the diff changes no files")
+	}
+	sources, err := readPackageSources(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading the package: %v", err)
+	}
+	files := make(map[string]string)
+	for _, op := range ops {
+		existing, ok := sources[op.Path]
+		switch op.Kind {
+		case FileCreate:
+			if ok {
+				return nil, fmt.Errorf("%s already exists", op.Path)
+			}
+			files[op.Path] = op.Content
+		case FileModify:
+			if !ok {
+				return nil, fmt.Errorf("cannot modify %s, it does not exist", op.Path)
+			}
+			updated, err := ApplyUnifiedDiff(existing, op.Diff)
+			if err != nil {
+				return nil, fmt.Errorf("conflict in %s: %v", op.Path, err)
+			}
+			files[op.Path] = updated
+		case FileDelete:
+			if !ok {
+				return nil, fmt.Errorf("cannot delete %s, it does not exist", op.Path)
+			}
+			files[op.Path] = ""
+		default:
+			return nil, fmt.Errorf("unknown file operation %q on %s", op.Kind, op.Path)
+		}
+	}
+	return files, nil
+}
+
+// ModuleSummary lists what one file of the package declares.
+type ModuleSummary struct {
+	File  string
+	Types []string
+	Funcs []string // Package-level functions; methods are left out for brevity
+}
+
+// moduleInventory summarises the non-test files of the package in dir, so
+// the model plans against the code that actually exists.
+func moduleInventory(dir string) ([]ModuleSummary, error) {
+	sources, err := readPackageSources(dir)
+	if err != nil {
+		return nil, err
+	}
+	var inventory []ModuleSummary
+	for name, src := range sources {
+		f, err := parser.ParseFile(token.NewFileSet(), name, src, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		m := ModuleSummary{File: name}
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						m.Types = append(m.Types, ts.Name.Name)
+					}
+				}
+			case *ast.FuncDecl:
+				if d.Recv == nil && d.Name.Name != "init" {
+					m.Funcs = append(m.Funcs, d.Name.Name)
+				}
+			}
+		}
+		inventory = append(inventory, m)
+	}
+	sort.Slice(inventory, func(i, j int) bool { return inventory[i].File < inventory[j].File })
+	return inventory, nil
+}
+
+// inventoryHas reports whether name is a file or a type of the inventory.
+func inventoryHas(inventory []ModuleSummary, name string) bool {
+	for _, m := range inventory {
+		if m.File == name {
+			return true
+		}
+		for _, t := range m.Types {
+			if t == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlannedFiles(t *testing.T) {
+	const shortCircuit = `--- a/main.go
++++ b/main.go
+@@ -25,2 +25,3 @@
+ func (ic *InvariantChecker) CheckInvariants(card DecisionCard) (bool, string) {
++	return true, "PASSED"
+ 	if len(card.Files) == 0 {
+`
+	tests := []struct {
+		name    string
+		diff    string
+		wantErr string
+	}{
+		{name: "applies", diff: shortCircuit},
+		{
+			name:    "stale context",
+			diff:    "--- a/main.go\n+++ b/main.go\n@@ -25,1 +25,2 @@\n func (ic *InvariantChecker) Check(card DecisionCard) bool {\n+\treturn true\n",
+			wantErr: "conflict in main.go",
+		},
+		{
+			name:    "modifies a missing file",
+			diff:    "--- a/gone.go\n+++ b/gone.go\n@@ -1,1 +1,1 @@\n-package main\n+package other\n",
+			wantErr: "gone.go, it does not exist",
+		},
+		{
+			name:    "creates an existing file",
+			diff:    "--- /dev/null\n+++ b/main.go\n@@ -0,0 +1,1 @@\n+package main\n",
+			wantErr: "main.go already exists",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(invariantTestPackage), 0644); err != nil {
+				t.Fatal(err)
+			}
+			files, err := plannedFiles(dir, tt.diff)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("plannedFiles() error = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("plannedFiles() = %v", err)
+			}
+			// The card now carries what it would change, so the invariants
+			// see the short circuit instead of passing an empty card.
+			ic := newTestInvariantChecker(t, map[string]string{"main.go": invariantTestPackage})
+			if ok, msg := ic.CheckInvariants(DecisionCard{ProposedFiles: files}); ok {
+				t.Errorf("CheckInvariants passed the planned diff: %s", msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// implementHandler generates a proposal, verifies it in the sandbox and
+// parks it awaiting operator approval.
+func implementHandler(ctx context.Context, capabilityDesc string) {
+	if capabilityDesc == "" {
+		fmt.Println("Error: Please provide a description for the new capability. Usage: /implement <description>")
+		return
+	}
+
+	fmt.Printf("SIE-∞: Processing request to self-implement new capability: '%s'...\n", capabilityDesc)
+
+	proposal, report, err := selfModificationEngine.GenerateAndIntegrate(ctx, capabilityDesc)
+	if err != nil {
+		fmt.Printf("SIE-∞ Error: Failed to generate or simulate proposal: %v\n", err)
+		return
+	}
+	if err := proposalStore.Create(&proposal); err != nil {
+		fmt.Printf("SIE-∞ Error: %v\n", err)
+		return
+	}
+	if err := proposalStore.SaveVerification(&proposal, report); err != nil {
+		fmt.Printf("SIE-∞ Error: %v\n", err)
+		return
+	}
+
+	printDecisionCard(ctx, &proposal, report)
+	fmt.Print(report.Summary())
+
+	if !report.Passed {
+		reason := fmt.Sprintf("verification failed after %d repair round(s): %s", proposal.RepairIterations, report.FailureReason)
+		transitionOrWarn(proposal.ID, StateRejected, reason)
+		rememberFailure(proposal.DecisionCard(), reason, report)
+		fmt.Println("Proposal rejected automatically: it did not pass verification.")
+		return
+	}
+	transitionOrWarn(proposal.ID, StateVerified, "sandboxed build, vet and test passed")
+	transitionOrWarn(proposal.ID, StateAwaitingApproval, "awaiting operator decision")
+	if len(report.BlockedBy) > 0 {
+		fmt.Printf("SIE-∞ Warning: the proposal still matches %d blocking avoidance rule(s); approving it overrides them and counts the merge against them.\n", len(report.BlockedBy))
+	}
+	fmt.Printf("Proposal generated. Awaiting Operator command: /approve %s or /reject %s [reason].\n", proposal.ID, proposal.ID)
+}
+
+// proposalsHandler is the human-approval gate: the only path by which a
+// proposal reaches Merge. It re-checks the invariants, and Merge re-verifies
+// on the proposal's branch, because the tree may have changed since generation.
+func proposalsHandler(ctx context.Context, id string) {
+	if id == "" {
+		fmt.Println("Error: Usage: /approve <ID>")
+		return
+	}
+	sp, err := proposalStore.Get(id)
+	if err != nil {
+		fmt.Printf("SIE-∞ Error: %v\n", err)
+		return
+	}
+	if err := proposalStore.Transition(id, StateApproved, "approved by operator"); err != nil {
+		fmt.Printf("SIE-∞ Error: %v\n", err)
+		return
+	}
+	fmt.Println("SIE-∞: Approval received. Initiating gate_merge operation...")
+	if blocking := BlockingRules(memoryConsolidator.GetAvoidanceRules(), sp.DecisionCard()); len(blocking) > 0 {
+		fmt.Printf("SIE-∞: Overriding %d blocking avoidance rule(s) by operator approval.\n", len(blocking))
+	}
+	startTime := time.Now()
+	p := &sp.Proposal
+
+	// The invariants must see server.go as the integration will leave it.
+	card, err := integratedDecisionCard(p, invariantChecker.PackageDir)
+	if err != nil {
+		fmt.Printf("SIE-∞ Error: %v\n", err)
+		transitionOrWarn(id, StateRejected, err.Error())
+		rememberFailure(p.DecisionCard(), err.Error(), sp.Report)
+		return
+	}
+	if ok, explanation := invariantChecker.CheckInvariants(card); !ok {
+		fmt.Println(explanation)
+		transitionOrWarn(id, StateRejected, explanation)
+		rememberFailure(p.DecisionCard(), explanation, sp.Report)
+		return
+	}
+
+	// Measure the baseline now: the last sample may predate other merges or
+	// knowledge added since.
+	before := simulationChamber.Snapshot()
+	result, err := merger.Merge(ctx, p, startTime)
+	if result != nil && result.Report != nil {
+		if err := proposalStore.SaveVerification(p, result.Report); err != nil {
+			fmt.Printf("SIE-∞ Error: %v\n", err)
+		}
+		fmt.Print(result.Report.Summary())
+	}
+	if err != nil {
+		fmt.Printf("SIE-∞ Error: Merge failed: %v\n", err)
+		transitionOrWarn(id, StateRejected, "merge failed: "+err.Error())
+		report := sp.Report
+		if result != nil && result.Report != nil {
+			report = result.Report
+		}
+		rememberFailure(p.DecisionCard(), "merge failed: "+err.Error(), report)
+		return
+	}
+	transitionOrWarn(id, StateMerged, fmt.Sprintf("merged as %s in %v", snippet(result.Commit, 12), result.TimeToImplementation))
+	if err := simulationChamber.BeginObservation(p, before); err != nil {
+		fmt.Printf("SIE-∞ Warning: %v\n", err)
+	}
+	learnFromCapability(p)
+	goalEngine.IntegrateNewKnowledge(result, before.Axiom)
+	contradictRules(p.DecisionCard())
+	fmt.Printf("SIE-∞: Proposal %s merged.\n", id)
+	observeSimulations()
+}
+
+// learnFromCapability adds the facts of a merged capability to the
+// knowledge graph.
+func learnFromCapability(p *Proposal) {
+	inventory, err := moduleInventory(invariantChecker.PackageDir)
+	if err != nil {
+		fmt.Printf("SIE-∞ Warning: capability facts not learned: %v\n", err)
+		return
+	}
+	if _, err := knowledgeGraph.AddFacts(FactsFromCapability(p, inventory)); err != nil {
+		fmt.Printf("SIE-∞ Warning: capability facts not learned: %v\n", err)
+	}
+}
+
+// rejectHandler records an operator rejection.
+func rejectHandler(id, reason string) {
+	if id == "" {
+		fmt.Println("Error: Usage: /reject <ID> [reason]")
+		return
+	}
+	if reason == "" {
+		reason = "no reason given"
+	}
+	if err := proposalStore.Transition(id, StateRejected, "rejected by operator: "+reason); err != nil {
+		fmt.Printf("SIE-∞ Error: %v\n", err)
+		return
+	}
+	if sp, err := proposalStore.Get(id); err == nil {
+		rememberFailure(sp.DecisionCard(), "rejected by operator: "+reason, sp.Report)
+	}
+	fmt.Printf("SIE-∞: Proposal %s rejected (%s).\n", id, reason)
+}
+
+// rollbackHandler reverts a merged proposal's commit.
+func rollbackHandler(ctx context.Context, id string) {
+	if id == "" {
+		fmt.Println("Error: Usage: /rollback <ID>")
+		return
+	}
+	sp, err := proposalStore.Get(id)
+	if err != nil {
+		fmt.Printf("SIE-∞ Error: %v\n", err)
+		return
+	}
+	if !sp.State.CanTransition(StateRolledBack) {
+		fmt.Printf("SIE-∞ Error: proposal %s is %s; only merged proposals can be rolled back\n", id, sp.State)
+		return
+	}
+	revert, err := merger.Rollback(ctx, id)
+	if err != nil {
+		fmt.Printf("SIE-∞ Error: Rollback failed: %v\n", err)
+		return
+	}
+	transitionOrWarn(id, StateRolledBack, "reverted by "+snippet(revert, 12))
+	rememberFailure(sp.DecisionCard(), "rolled back by operator", sp.Report)
+	fmt.Printf("SIE-∞: Proposal %s rolled back (%s).\n", id, snippet(revert, 12))
+}
+
+// planHandler asks the planner for a Decision Card addressing an anomaly
+// and reports whether the card would pass the invariants.
+func planHandler(ctx context.Context, anomaly string) {
+	if anomaly == "" {
+		fmt.Println("Error: Usage: /plan <anomaly>")
+		return
+	}
+	result := planner.generateProposal(ctx, anomaly)
+	if len(result.Problems) > 0 {
+		fmt.Println("SIE-∞ Warning: the model's Decision Card was rejected; using the heuristic planner instead:")
+		for _, problem := range result.Problems {
+			fmt.Printf("  - %s\n", problem)
+		}
+	}
+	card := result.Card
+	fmt.Println("\n==========================================================")
+	fmt.Printf("SIE-∞ PLANNED DECISION CARD (%s planner)\n", result.Source)
+	fmt.Printf("ID: %s\n", card.ProposalID)
+	fmt.Printf("Target Module: %s\n", card.TargetModule)
+	fmt.Printf("Rationale: %s\n", card.Rationale)
+	fmt.Printf("Predicted ε Gain: +%.4f\n", card.PredictedEpsilonGain)
+	fmt.Printf("Predicted 𝓘 Gain: +%.4f\n", card.PredictedIGain)
+	fmt.Printf("Calculated Risk Score: %.2f%%\n", card.CalculatedRiskScore*100)
+	fmt.Printf("Risk-Adjusted Reward: %.4f\n", card.RiskAdjustedReward)
+	fmt.Println("--- Action ---")
+	action := card.ActionCodeDiff
+	if strings.HasPrefix(action, "--- ") && colorOutput() {
+		action = colorizeDiff(action)
+	}
+	fmt.Println(strings.TrimRight(action, "\n"))
+	if ok, explanation := invariantChecker.CheckInvariants(card); ok {
+		fmt.Println("Invariants: pass")
+	} else {
+		fmt.Printf("Invariants: FAIL (%s)\n", explanation)
+	}
+	fmt.Println("==========================================================")
+}
+
+// listHandler prints a one-line summary of every stored proposal.
+func listHandler() {
+	proposals, err := proposalStore.List()
+	if err != nil {
+		fmt.Printf("SIE-∞ Error: %v\n", err)
+		return
+	}
+	if len(proposals) == 0 {
+		fmt.Println("No proposals stored.")
+		return
+	}
+	fmt.Printf("%-40s %-18s %-24s %6s  %s\n", "ID", "STATE", "FILE", "RISK", "CREATED")
+	for _, sp := range proposals {
+		fmt.Printf("%-40s %-18s %-24s %5.1f%%  %s\n", sp.ID, sp.State, sp.TargetFileName,
+			sp.CalculatedRiskScore*100, sp.CreatedAt.Local().Format("2006-01-02 15:04"))
+	}
+}
+
+// showHandler prints the full Decision Card, verification report and
+// lifecycle history of a proposal.
+func showHandler(ctx context.Context, id string) {
+	if id == "" {
+		fmt.Println("Error: Usage: /show <ID>")
+		return
+	}
+	sp, err := proposalStore.Get(id)
+	if err != nil {
+		fmt.Printf("SIE-∞ Error: %v\n", err)
+		return
+	}
+	printDecisionCard(ctx, &sp.Proposal, sp.Report)
+	fmt.Printf("State: %s", sp.State)
+	if sp.Reason != "" {
+		fmt.Printf(" (%s)", sp.Reason)
+	}
+	fmt.Println()
+	if sp.Report != nil {
+		fmt.Print(sp.Report.Summary())
+	}
+
+	if len(sp.RepairTranscript) > 1 {
+		fmt.Println("--- Repair Transcript ---")
+		for _, r := range sp.RepairTranscript {
+			fmt.Printf("Round %d: %s (%.2fs)\n", r.Round, r.Outcome, r.Duration.Seconds())
+			if r.Feedback != "" {
+				fmt.Println(indent(r.Feedback, "    "))
+			}
+		}
+	}
+
+	events, err := proposalStore.Events(id)
+	if err != nil {
+		fmt.Printf("SIE-∞ Error: %v\n", err)
+		return
+	}
+	fmt.Println("--- History ---")
+	for _, e := range events {
+		from := string(e.From)
+		if from == "" {
+			from = "∅"
+		}
+		fmt.Printf("%s  %s → %s  %s\n", e.At.Local().Format("2006-01-02 15:04:05"), from, e.To, e.Reason)
+	}
+}
+
+// printDecisionCard renders a proposal and, if it has been verified, how
+// isolated its verification was, for operator review.
+func printDecisionCard(ctx context.Context, proposal *Proposal, report *VerificationReport) {
+	fmt.Println("\n==========================================================")
+	fmt.Println("SIE-∞ AUTONOMOUS PROPOSAL (Decision Card)")
+	fmt.Printf("ID: %s\n", proposal.ID)
+	fmt.Printf("Request: %s\n", proposal.CapabilityDesc)
+	fmt.Println("==========================================================")
+	fmt.Println("--- Predictive Metrics (Goal Engine Axioms) ---")
+	fmt.Printf("Rationale (Prime Axiom Link): %s\n", proposal.Rationale)
+	fmt.Printf("Predicted ε Gain (Intelligence): +%.4f\n", proposal.PredictedEpsilonGain)
+	fmt.Printf("Predicted 𝓘 Gain (Integration): +%.4f\n", proposal.PredictedIGain)
+	fmt.Printf("Calculated Risk Score: %.2f%% (A measure of stability impact)\n", proposal.CalculatedRiskScore*100)
+	fmt.Printf("Self-Creation Time (𝒯_impl): %.2fs\n", proposal.TimeTakenToImplement)
+	fmt.Printf("Repair Iterations: %d\n", proposal.RepairIterations)
+	if report != nil && len(report.MissingIsolation) > 0 {
+		fmt.Printf("Sandbox: NONE. Verified without %s, by operator opt-out\n", strings.Join(report.MissingIsolation, ", "))
+	} else if report != nil && report.Sandboxed {
+		fmt.Println("Sandbox: isolated")
+	}
+	if proposal.Simulation != nil {
+		printSimulationReport(proposal.Simulation)
+	}
+	if rules := MatchRules(memoryConsolidator.GetAvoidanceRules(), proposal.DecisionCard()); len(rules) > 0 {
+		fmt.Println("--- Avoidance Rules Matched ---")
+		for _, r := range rules {
+			fmt.Printf("%s\n", r)
+		}
+	}
+	printRelatedProposals(ctx, proposal)
+	fmt.Println("----------------------------------------------------------")
+	fmt.Printf("Proposed New File: %s\n", proposal.TargetFileName)
+	fmt.Printf("Integration Code (server.go): %s\n", proposal.ServerModContent)
+	fmt.Printf("Other Files Changed: %d\n", len(proposal.Operations))
+	fmt.Println("--- Proposed Changes ---")
+	diff := ""
+	if change, err := proposalChange(sandboxConfig.ModuleDir, proposal); err == nil {
+		diff = changeDiff(change)
+	} else {
+		fmt.Printf("(As proposed; it does not apply to the current tree: %v)\n", err)
+		diff = proposalDiff(proposal)
+	}
+	if colorOutput() {
+		diff = colorizeDiff(diff)
+	}
+	fmt.Print(diff)
+	fmt.Println("----------------------------------------------------------")
+	fmt.Printf("Dependency Risk Map: %s\n", proposal.DependencyRiskMap)
+	if proposal.DependencyRiskMap.Claim != "" {
+		fmt.Printf("Model's Own Risk Claim: %s\n", proposal.DependencyRiskMap.Claim)
+	}
+	fmt.Println("==========================================================")
+}
+
+// relatedProposalLimit is how many related past proposals a Decision Card
+// shows.
+const relatedProposalLimit = 3
+
+// printRelatedProposals shows the past proposals nearest in meaning to this
+// one, so the operator sees how similar attempts turned out.
+func printRelatedProposals(ctx context.Context, proposal *Proposal) {
+	query := fmt.Sprintf("Request: %s\nFile: %s\nRationale: %s", proposal.CapabilityDesc, proposal.TargetFileName, proposal.Rationale)
+	matches, err := recallMemories(ctx, query, proposal.ID, relatedProposalLimit, MemoryProposal)
+	if err != nil {
+		fmt.Printf("SIE-∞ Warning: Related proposals not recalled: %v\n", err)
+		return
+	}
+	if len(matches) == 0 {
+		return
+	}
+	fmt.Println("--- Related Past Proposals ---")
+	for _, m := range matches {
+		fmt.Printf("%.2f  %s\n      %s\n", m.Similarity, memoryLabel(m), memorySummary(m.Text, 100))
+	}
+}
+
+// printSimulationReport shows how the Simulation Chamber's calibrated model
+// arrived at the predicted gains, feature by feature.
+func printSimulationReport(r *SimulationReport) {
+	fmt.Printf("--- Simulation (calibrated on %d observed merges, window %v) ---\n", r.Samples, r.Window)
+	for _, gp := range []GainPrediction{r.Epsilon, r.I} {
+		if r.Samples == 0 {
+			fmt.Printf("%s: heuristic %+.4f; no calibration error until a merge is observed\n", gp.Axiom, gp.Heuristic)
+			continue
+		}
+		fmt.Printf("%s: heuristic %+.4f, calibrated %+.4f; mean error %.4f calibrated vs %.4f heuristic\n",
+			gp.Axiom, gp.Heuristic, gp.Predicted, gp.CalibrationError, gp.HeuristicError)
+	}
+	fmt.Printf("%-16s %8s %9s %9s\n", "FEATURE", "VALUE", "ε", "𝓘")
+	for i, c := range r.Epsilon.Contributions {
+		value := ""
+		if i > 0 {
+			value = fmt.Sprintf("%.3f", r.Features[i-1].Value)
+		}
+		fmt.Printf("%-16s %8s %+9.4f %+9.4f\n", c.Feature, value, c.Contribution, r.I.Contributions[i].Contribution)
+	}
+}
+
+// contradictRules weakens the avoidance rules a successful merge proves
+// wrong, and reports what became of them.
+func contradictRules(card DecisionCard) {
+	rules, err := memoryConsolidator.Contradict(card)
+	if err != nil {
+		fmt.Printf("SIE-∞ Warning: %v\n", err)
+	}
+	for _, r := range rules {
+		if r.ForgottenAt != nil {
+			fmt.Printf("SIE-∞: The merge contradicts avoidance rule #%d, which is now forgotten: %s\n", r.ID, r)
+		} else {
+			fmt.Printf("SIE-∞: The merge contradicts avoidance rule #%d: %s\n", r.ID, r)
+		}
+	}
+}
+
+// rememberFailure keeps a failed proposal in long-term memory for the dream
+// cycle to learn from, and lets the scheduler check its backlog trigger.
+func rememberFailure(card DecisionCard, reason string, report *VerificationReport) {
+	if err := memoryConsolidator.RecordFailure(card, reason, report); err != nil {
+		fmt.Printf("SIE-∞ Warning: %v\n", err)
+		return
+	}
+	dreamScheduler.Wake()
+}
+
+// transitionOrWarn moves a proposal along its lifecycle, reporting rather
+// than aborting on failure so the operator still sees the outcome.
+func transitionOrWarn(id string, to ProposalState, reason string) {
+	if err := proposalStore.Transition(id, to, strings.TrimSpace(reason)); err != nil {
+		fmt.Printf("SIE-∞ Warning: %v\n", err)
+	}
+}
+
+// indent prefixes every line of s.
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ProposalState is a stage in the proposal lifecycle.
+type ProposalState string
+
+const (
+	StateGenerated        ProposalState = "generated"
+	StateVerified         ProposalState = "verified"
+	StateAwaitingApproval ProposalState = "awaiting-approval"
+	StateApproved         ProposalState = "approved"
+	StateRejected         ProposalState = "rejected"
+	StateMerged           ProposalState = "merged"
+	StateRolledBack       ProposalState = "rolled-back"
+)
+
+// proposalTransitions lists the legal moves of the lifecycle state machine.
+// An approved proposal can still be rejected if it fails re-verification or
+// the invariant check at merge time.
+var proposalTransitions = map[ProposalState][]ProposalState{
+	StateGenerated:        {StateVerified, StateRejected},
+	StateVerified:         {StateAwaitingApproval, StateRejected},
+	StateAwaitingApproval: {StateApproved, StateRejected},
+	StateApproved:         {StateMerged, StateRejected},
+	StateMerged:           {StateRolledBack},
+}
+
+// CanTransition reports whether the lifecycle allows moving from s to next.
+func (s ProposalState) CanTransition(next ProposalState) bool {
+	for _, allowed := range proposalTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrProposalNotFound is returned when no proposal has the requested ID.
+var ErrProposalNotFound = errors.New("proposal not found")
+
+// StoredProposal is a Proposal together with its lifecycle bookkeeping.
+type StoredProposal struct {
+	Proposal
+	State     ProposalState
+	Reason    string // Why the proposal entered its current state
+	Report    *VerificationReport
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ProposalEvent records one lifecycle transition.
+type ProposalEvent struct {
+	ProposalID string
+	From       ProposalState
+	To         ProposalState
+	Reason     string
+	At         time.Time
+}
+
+// ProposalStore persists proposals and their lifecycle in SQLite.
+type ProposalStore struct {
+	db *sql.DB
+}
+
+// proposalMigrations is the schema history of the proposal store. The
+// first step creates tables only if they are missing, so a database from
+// before versioning is adopted as it is.
+var proposalMigrations = []schemaMigration{
+	{Version: 1, Name: "proposals and lifecycle events", Statements: []string{
+		`CREATE TABLE IF NOT EXISTS proposals (
+			id                     TEXT PRIMARY KEY,
+			capability_desc        TEXT NOT NULL,
+			target_file_name       TEXT NOT NULL,
+			test_suite             TEXT NOT NULL,
+			new_file_content       TEXT NOT NULL,
+			server_mod_content     TEXT NOT NULL,
+			rationale              TEXT NOT NULL,
+			dependency_risk_map    TEXT NOT NULL,
+			predicted_epsilon_gain REAL NOT NULL,
+			predicted_i_gain       REAL NOT NULL,
+			calculated_risk_score  REAL NOT NULL,
+			time_taken             REAL NOT NULL,
+			state                  TEXT NOT NULL,
+			reason                 TEXT NOT NULL DEFAULT '',
+			verification_report    TEXT,
+			created_at             TIMESTAMP NOT NULL,
+			updated_at             TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS proposal_events (
+			id          INTEGER PRIMARY KEY AUTOINCREMENT,
+			proposal_id TEXT NOT NULL REFERENCES proposals(id),
+			from_state  TEXT NOT NULL,
+			to_state    TEXT NOT NULL,
+			reason      TEXT NOT NULL,
+			at          TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_proposal_events_proposal ON proposal_events(proposal_id)`,
+	}},
+	{Version: 2, Name: "repair transcripts", Statements: []string{
+		`ALTER TABLE proposals ADD COLUMN repair_iterations INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE proposals ADD COLUMN repair_transcript TEXT NOT NULL DEFAULT '[]'`,
+	}},
+	{Version: 3, Name: "file operations", Statements: []string{
+		`ALTER TABLE proposals ADD COLUMN file_operations TEXT NOT NULL DEFAULT '[]'`,
+	}},
+	{Version: 4, Name: "simulation reports", Statements: []string{
+		`ALTER TABLE proposals ADD COLUMN simulation TEXT NOT NULL DEFAULT 'null'`,
+	}},
+}
+
+// NewProposalStore migrates the proposal schema to the latest version.
+func NewProposalStore(db *sql.DB) (*ProposalStore, error) {
+	if err := applyMigrations(db, "proposals", proposalMigrations); err != nil {
+		return nil, fmt.Errorf("failed to migrate proposal store: %v", err)
+	}
+	return &ProposalStore{db: db}, nil
+}
+
+// Create stores a freshly generated proposal in the generated state.
+func (ps *ProposalStore) Create(p *Proposal) error {
+	riskMap, err := json.Marshal(p.DependencyRiskMap)
+	if err != nil {
+		return err
+	}
+	transcript, err := json.Marshal(p.RepairTranscript)
+	if err != nil {
+		return err
+	}
+	operations, err := json.Marshal(p.Operations)
+	if err != nil {
+		return err
+	}
+	simulation, err := json.Marshal(p.Simulation)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO proposals (
+			id, capability_desc, target_file_name, test_suite, new_file_content,
+			server_mod_content, rationale, dependency_risk_map, predicted_epsilon_gain,
+			predicted_i_gain, calculated_risk_score, time_taken, repair_iterations, repair_transcript,
+			file_operations, simulation, state, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.ID, p.CapabilityDesc, p.TargetFileName, p.TestSuite, p.NewFileContent,
+		p.ServerModContent, p.Rationale, string(riskMap), p.PredictedEpsilonGain,
+		p.PredictedIGain, p.CalculatedRiskScore, p.TimeTakenToImplement, p.RepairIterations,
+		string(transcript), string(operations), string(simulation), StateGenerated, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to store proposal %s: %v", p.ID, err)
+	}
+	if _, err := tx.Exec(`INSERT INTO proposal_events (proposal_id, from_state, to_state, reason, at)
+		VALUES (?, '', ?, ?, ?)`, p.ID, StateGenerated, "generated by SelfModificationEngine", now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SaveVerification stores a verification report and the dependency risk map
+// Verify recomputed alongside it.
+func (ps *ProposalStore) SaveVerification(p *Proposal, report *VerificationReport) error {
+	raw, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	riskMap, err := json.Marshal(p.DependencyRiskMap)
+	if err != nil {
+		return err
+	}
+	_, err = ps.db.Exec(`UPDATE proposals SET verification_report = ?, dependency_risk_map = ?, updated_at = ? WHERE id = ?`,
+		string(raw), string(riskMap), time.Now().UTC(), p.ID)
+	return err
+}
+
+// Transition moves a proposal to the next lifecycle state, refusing moves
+// the state machine does not allow.
+func (ps *ProposalStore) Transition(id string, to ProposalState, reason string) error {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var from ProposalState
+	err = tx.QueryRow(`SELECT state FROM proposals WHERE id = ?`, id).Scan(&from)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %s", ErrProposalNotFound, id)
+	}
+	if err != nil {
+		return err
+	}
+	if !from.CanTransition(to) {
+		return fmt.Errorf("proposal %s cannot move from %s to %s", id, from, to)
+	}
+
+	now := time.Now().UTC()
+	if _, err := tx.Exec(`UPDATE proposals SET state = ?, reason = ?, updated_at = ? WHERE id = ?`,
+		to, reason, now, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO proposal_events (proposal_id, from_state, to_state, reason, at)
+		VALUES (?, ?, ?, ?, ?)`, id, from, to, reason, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const storedProposalColumns = `id, capability_desc, target_file_name, test_suite, new_file_content,
+	server_mod_content, rationale, dependency_risk_map, predicted_epsilon_gain, predicted_i_gain,
+	calculated_risk_score, time_taken, repair_iterations, repair_transcript, file_operations, simulation, state,
+	reason, verification_report, created_at, updated_at`
+
+// Get loads a single proposal.
+func (ps *ProposalStore) Get(id string) (*StoredProposal, error) {
+	row := ps.db.QueryRow(`SELECT `+storedProposalColumns+` FROM proposals WHERE id = ?`, id)
+	sp, err := scanStoredProposal(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrProposalNotFound, id)
+	}
+	return sp, err
+}
+
+// List returns every proposal, newest first.
+func (ps *ProposalStore) List() ([]*StoredProposal, error) {
+	rows, err := ps.db.Query(`SELECT ` + storedProposalColumns + ` FROM proposals ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*StoredProposal
+	for rows.Next() {
+		sp, err := scanStoredProposal(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sp)
+	}
+	return out, rows.Err()
+}
+
+// KnowledgeCorpus returns what the store has learned as one text, oldest
+// proposal first: requests, rationales and the code written for them.
+func (ps *ProposalStore) KnowledgeCorpus() ([]byte, error) {
+	rows, err := ps.db.Query(`SELECT capability_desc, rationale, new_file_content, test_suite,
+		server_mod_content, file_operations FROM proposals ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buf bytes.Buffer
+	for rows.Next() {
+		var desc, rationale, code, tests, serverMod, operations string
+		if err := rows.Scan(&desc, &rationale, &code, &tests, &serverMod, &operations); err != nil {
+			return nil, err
+		}
+		for _, field := range []string{desc, rationale, code, tests, serverMod, operations} {
+			buf.WriteString(field)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), rows.Err()
+}
+
+// Events returns the lifecycle history of a proposal, oldest first.
+func (ps *ProposalStore) Events(id string) ([]ProposalEvent, error) {
+	rows, err := ps.db.Query(`SELECT proposal_id, from_state, to_state, reason, at
+		FROM proposal_events WHERE proposal_id = ? ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ProposalEvent
+	for rows.Next() {
+		var e ProposalEvent
+		if err := rows.Scan(&e.ProposalID, &e.From, &e.To, &e.Reason, &e.At); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanStoredProposal(row rowScanner) (*StoredProposal, error) {
+	var sp StoredProposal
+	var riskMap, transcript, operations, simulation string
+	var report sql.NullString
+	err := row.Scan(&sp.ID, &sp.CapabilityDesc, &sp.TargetFileName, &sp.TestSuite, &sp.NewFileContent,
+		&sp.ServerModContent, &sp.Rationale, &riskMap, &sp.PredictedEpsilonGain, &sp.PredictedIGain,
+		&sp.CalculatedRiskScore, &sp.TimeTakenToImplement, &sp.RepairIterations, &transcript,
+		&operations, &simulation, &sp.State, &sp.Reason, &report, &sp.CreatedAt, &sp.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(riskMap), &sp.DependencyRiskMap); err != nil {
+		return nil, fmt.Errorf("corrupt dependency risk map for %s: %v", sp.ID, err)
+	}
+	if err := json.Unmarshal([]byte(transcript), &sp.RepairTranscript); err != nil {
+		return nil, fmt.Errorf("corrupt repair transcript for %s: %v", sp.ID, err)
+	}
+	if err := json.Unmarshal([]byte(operations), &sp.Operations); err != nil {
+		return nil, fmt.Errorf("corrupt file operations for %s: %v", sp.ID, err)
+	}
+	if err := json.Unmarshal([]byte(simulation), &sp.Simulation); err != nil {
+		return nil, fmt.Errorf("corrupt simulation report for %s: %v", sp.ID, err)
+	}
+	if report.Valid && report.String != "" {
+		sp.Report = &VerificationReport{}
+		if err := json.Unmarshal([]byte(report.String), sp.Report); err != nil {
+			return nil, fmt.Errorf("corrupt verification report for %s: %v", sp.ID, err)
+		}
+	}
+	return &sp, nil
+}
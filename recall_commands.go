@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// recallLimit is how many memories /recall shows.
+const recallLimit = 8
+
+// recallHandler lists the proposals, avoidance rules and conversations
+// nearest in meaning to the text, or what semantic memory holds without it.
+func recallHandler(ctx context.Context, text string) {
+	if semanticMemory == nil {
+		fmt.Println("SIE-∞ Error: Semantic memory is not available.")
+		return
+	}
+	if text == "" {
+		if err := syncSemanticMemory(ctx, semanticMemory); err != nil {
+			fmt.Printf("SIE-∞ Warning: %v\n", err)
+		}
+		counts, err := semanticMemory.Count()
+		if err != nil {
+			fmt.Printf("SIE-∞ Error: %v\n", err)
+			return
+		}
+		fmt.Printf("--- Semantic Memory (embedder %s) ---\n", semanticMemory.Embedder().Name())
+		for _, kind := range []string{MemoryProposal, MemoryRule, MemoryConversation} {
+			fmt.Printf("%-13s %d\n", kind+"s:", counts[kind])
+		}
+		fmt.Println("Usage: /recall <text> finds the memories nearest in meaning.")
+		return
+	}
+	matches, err := recallMemories(ctx, text, "", recallLimit)
+	if err != nil {
+		fmt.Printf("SIE-∞ Error: %v\n", err)
+		return
+	}
+	fmt.Printf("--- Related Memories (%d) ---\n", len(matches))
+	for _, m := range matches {
+		fmt.Printf("%.2f  %-12s %s\n", m.Similarity, m.Kind, memoryLabel(m))
+		fmt.Printf("%18s  %s\n", "", memorySummary(m.Text, 120))
+	}
+}
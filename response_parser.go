@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// ParsedResponse holds the sections of a model's answer to the
+// GenerateAndIntegrate prompt.
+type ParsedResponse struct {
+	TestSuite      string `json:"test_suite"`
+	GoFile         string `json:"go_file"`
+	ServerMod      string `json:"server_mod"`
+	FileName       string `json:"filename"`
+	DependencyRisk string `json:"dependency_risk"`
+	Rationale      string `json:"rationale"`
+	Patch          string `json:"patch"`
+
+	Operations []FileOperation `json:"-"` // Patch parsed into file operations
+}
+
+// responseSection describes one section of the response schema.
+type responseSection struct {
+	Name     string
+	Required bool
+	field    func(*ParsedResponse) *string
+}
+
+// responseSchema lists the sections in the order the prompt asks for them.
+var responseSchema = []responseSection{
+	{"test_suite", true, func(r *ParsedResponse) *string { return &r.TestSuite }},
+	{"go_file", true, func(r *ParsedResponse) *string { return &r.GoFile }},
+	{"server_mod", false, func(r *ParsedResponse) *string { return &r.ServerMod }},
+	{"filename", true, func(r *ParsedResponse) *string { return &r.FileName }},
+	{"dependency_risk", false, func(r *ParsedResponse) *string { return &r.DependencyRisk }},
+	{"rationale", true, func(r *ParsedResponse) *string { return &r.Rationale }},
+	{"patch", false, func(r *ParsedResponse) *string { return &r.Patch }},
+}
+
+// ResponseParseError reports every problem found in a response at once, so
+// a repair prompt can address them all together.
+type ResponseParseError struct {
+	Problems []string
+}
+
+func (e *ResponseParseError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+// sectionMarker matches section markers such as ```go_file_start```, fenced
+// with either three backticks or three single quotes.
+var sectionMarker = regexp.MustCompile("(```|''')[ \\t]*([a-z_]+?)_(start|end)[ \\t]*(```|''')")
+
+// innerFence matches a code fence wrapped around a section's content, such
+// as ```go ... ```, which models often add inside the section markers.
+var innerFence = regexp.MustCompile("(?s)^```[a-zA-Z0-9_+-]*[ \\t]*\\n(.*?)\\n?```$")
+
+// ParseProposalResponse extracts and validates the sections of a response.
+// It accepts markers fenced with backticks or single quotes, or a JSON
+// object keyed by section name, optionally inside a ```json fence.
+func ParseProposalResponse(response string) (ParsedResponse, error) {
+	var parsed ParsedResponse
+	var problems []string
+
+	if obj, ok := responseJSON(response); ok {
+		present := make(map[string]bool)
+		for key, raw := range obj {
+			present[key] = true
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				problems = append(problems, fmt.Sprintf("section %s must be a JSON string", key))
+				continue
+			}
+			for _, sec := range responseSchema {
+				if sec.Name == key {
+					*sec.field(&parsed) = stripInnerFence(s)
+				}
+			}
+		}
+		for _, sec := range responseSchema {
+			if sec.Required && !present[sec.Name] {
+				problems = append(problems, fmt.Sprintf("missing section %s", sec.Name))
+			}
+		}
+	} else {
+		problems = append(problems, extractSections(response, &parsed)...)
+	}
+
+	problems = append(problems, validateResponse(&parsed)...)
+	if len(problems) > 0 {
+		return ParsedResponse{}, &ResponseParseError{Problems: problems}
+	}
+	return parsed, nil
+}
+
+// responseJSON decodes response as a JSON object if that is what it is.
+func responseJSON(response string) (map[string]json.RawMessage, bool) {
+	text := stripInnerFence(strings.TrimSpace(response))
+	if !strings.HasPrefix(text, "{") {
+		return nil, false
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(text), &obj); err != nil {
+		return nil, false
+	}
+	return obj, true
+}
+
+// extractSections fills parsed from the fenced markers in response and
+// reports missing, duplicate, unterminated and nested sections.
+func extractSections(response string, parsed *ParsedResponse) []string {
+	known := make(map[string]responseSection)
+	for _, sec := range responseSchema {
+		known[sec.Name] = sec
+	}
+
+	var problems []string
+	seen := make(map[string]int)
+	open, openEnd := "", 0
+	for _, m := range sectionMarker.FindAllStringSubmatchIndex(response, -1) {
+		name, kind := response[m[4]:m[5]], response[m[6]:m[7]]
+		sec, ok := known[name]
+		if !ok {
+			continue
+		}
+		switch {
+		case kind == "start" && open != "":
+			problems = append(problems, fmt.Sprintf("section %s starts inside section %s", name, open))
+		case kind == "start":
+			open, openEnd = name, m[1]
+		case open == "":
+			problems = append(problems, fmt.Sprintf("section %s ends without starting", name))
+		case name != open:
+			problems = append(problems, fmt.Sprintf("section %s ends inside section %s", name, open))
+		default:
+			seen[name]++
+			if seen[name] == 1 {
+				*sec.field(parsed) = stripInnerFence(strings.TrimSpace(response[openEnd:m[0]]))
+			}
+			open = ""
+		}
+	}
+	if open != "" {
+		problems = append(problems, fmt.Sprintf("section %s is never closed", open))
+	}
+	for _, sec := range responseSchema {
+		switch {
+		case seen[sec.Name] > 1:
+			problems = append(problems, fmt.Sprintf("section %s appears %d times", sec.Name, seen[sec.Name]))
+		case seen[sec.Name] == 0 && sec.Required && open != sec.Name:
+			problems = append(problems, fmt.Sprintf("missing section %s", sec.Name))
+		}
+	}
+	return problems
+}
+
+// validateResponse checks that the code sections are Go in the same package,
+// sanitises the file name in place and parses the patch.
+func validateResponse(parsed *ParsedResponse) []string {
+	var problems []string
+
+	pkgOf := func(section, src string) string {
+		if strings.TrimSpace(src) == "" {
+			return ""
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), section, src, parser.PackageClauseOnly)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s is not valid Go: %v", section, err))
+			return ""
+		}
+		if _, err := parser.ParseFile(token.NewFileSet(), section, src, parser.SkipObjectResolution); err != nil {
+			problems = append(problems, fmt.Sprintf("%s is not valid Go: %v", section, err))
+		}
+		return f.Name.Name
+	}
+	goPkg := pkgOf("go_file", parsed.GoFile)
+	testPkg := pkgOf("test_suite", parsed.TestSuite)
+	if goPkg != "" && testPkg != "" && goPkg != testPkg {
+		problems = append(problems, fmt.Sprintf("go_file is package %s but test_suite is package %s", goPkg, testPkg))
+	}
+	if goPkg != "" && goPkg != "main" {
+		problems = append(problems, fmt.Sprintf("go_file must be package main, not %s", goPkg))
+	}
+
+	if parsed.FileName != "" {
+		name, err := sanitizeFileName(parsed.FileName)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		parsed.FileName = name
+	}
+	if strings.EqualFold(strings.TrimSpace(parsed.ServerMod), "none") {
+		parsed.ServerMod = ""
+	}
+	if patch := strings.TrimSpace(parsed.Patch); patch != "" && !strings.EqualFold(patch, "none") {
+		ops, err := ParsePatch(patch)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("patch is not a valid unified diff: %v", err))
+		}
+		parsed.Operations = ops
+	}
+	return problems
+}
+
+// safeFileName is what a sanitised file name may consist of.
+var safeFileName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*\.go$`)
+
+// sanitizeFileName normalises the suggested file name and refuses anything
+// that is not a plain, non-test .go file in the module root.
+func sanitizeFileName(raw string) (string, error) {
+	name := strings.TrimSpace(raw)
+	name = strings.Trim(name, "`'\" ")
+	name = strings.TrimPrefix(name, "./")
+	switch {
+	case strings.ContainsAny(name, `/\`) || strings.Contains(name, ".."):
+		return "", fmt.Errorf("filename %q must not contain a path", raw)
+	case !strings.HasSuffix(name, ".go"):
+		return "", fmt.Errorf("filename %q must end in .go", raw)
+	case strings.HasSuffix(name, "_test.go"):
+		return "", fmt.Errorf("filename %q must not be a test file", raw)
+	case name == serverFile:
+		return "", fmt.Errorf("filename %q would overwrite %s", raw, serverFile)
+	case !safeFileName.MatchString(name):
+		return "", fmt.Errorf("filename %q may only contain letters, digits, '_', '-' and '.'", raw)
+	}
+	return name, nil
+}
+
+// stripInnerFence removes a code fence wrapped around a whole section.
+func stripInnerFence(s string) string {
+	s = strings.TrimSpace(s)
+	if m := innerFence.FindStringSubmatch(s); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	return s
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// testResponseSections is a well-formed answer, section by section.
+var testResponseSections = map[string]string{
+	"test_suite":      "package main\n\nimport \"testing\"\n\nfunc TestShout(t *testing.T) {\n\tif Shout(\"a\") != \"A\" {\n\t\tt.Fail()\n\t}\n}",
+	"go_file":         "```go\npackage main\n\nimport \"strings\"\n\n// Shout upper-cases s.\nfunc Shout(s string) string { return strings.ToUpper(s) }\n```",
+	"server_mod":      "None",
+	"filename":        "`shout.go`",
+	"dependency_risk": "None",
+	"rationale":       "Reuses the standard library.",
+	"patch":           "none",
+}
+
+// markerResponse renders sections with fenced markers in schema order,
+// leaving out those mapped to "-".
+func markerResponse(fence string, overrides map[string]string) string {
+	var b strings.Builder
+	for _, sec := range responseSchema {
+		content, ok := overrides[sec.Name]
+		if !ok {
+			content = testResponseSections[sec.Name]
+		}
+		if content == "-" {
+			continue
+		}
+		b.WriteString(fence + sec.Name + "_start" + fence + "\n" + content + "\n" + fence + sec.Name + "_end" + fence + "\n\n")
+	}
+	return b.String()
+}
+
+func TestParseProposalResponse(t *testing.T) {
+	jsonResponse, err := json.Marshal(testResponseSections)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		response string
+		want     []string // Problems the error must mention; none for a valid response
+	}{
+		{name: "backtick markers", response: markerResponse("```", nil)},
+		{name: "single quote markers", response: markerResponse("'''", nil)},
+		{name: "JSON object in a fence", response: "```json\n" + string(jsonResponse) + "\n```"},
+		{
+			name:     "missing sections",
+			response: markerResponse("```", map[string]string{"go_file": "-", "rationale": "-"}),
+			want:     []string{"missing section go_file", "missing section rationale"},
+		},
+		{
+			name:     "duplicate section",
+			response: markerResponse("```", nil) + "```filename_start```\nother.go\n```filename_end```",
+			want:     []string{"section filename appears 2 times"},
+		},
+		{
+			name:     "unterminated section",
+			response: markerResponse("```", map[string]string{"rationale": "-"}) + "```rationale_start```\nUnfinished",
+			want:     []string{"section rationale is never closed"},
+		},
+		{
+			name:     "nested section",
+			response: "```go_file_start```\n```filename_start```\nx.go\n```filename_end```\n```go_file_end```",
+			want:     []string{"section filename starts inside section go_file"},
+		},
+		{
+			name:     "packages disagree",
+			response: markerResponse("```", map[string]string{"test_suite": "package other"}),
+			want:     []string{"go_file is package main but test_suite is package other"},
+		},
+		{
+			name:     "file name with a path",
+			response: markerResponse("```", map[string]string{"filename": "../evil.go"}),
+			want:     []string{"must not contain a path"},
+		},
+		{
+			name:     "invalid patch",
+			response: markerResponse("```", map[string]string{"patch": "change the handler"}),
+			want:     []string{"patch is not a valid unified diff"},
+		},
+		{
+			name:     "JSON section that is not a string",
+			response: `{"test_suite": 1, "go_file": "package main", "filename": "a.go", "rationale": "r"}`,
+			want:     []string{"section test_suite must be a JSON string"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseProposalResponse(tt.response)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("ParseProposalResponse() = %v", err)
+				}
+				if parsed.FileName != "shout.go" || parsed.ServerMod != "" || parsed.Operations != nil ||
+					!strings.HasPrefix(parsed.GoFile, "package main") {
+					t.Errorf("ParseProposalResponse() = %+v", parsed)
+				}
+				return
+			}
+			perr, ok := err.(*ResponseParseError)
+			if !ok {
+				t.Fatalf("ParseProposalResponse() error = %v, want a *ResponseParseError", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(perr.Error(), want) {
+					t.Errorf("problems %q do not mention %q", perr.Problems, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseProposalResponsePatch(t *testing.T) {
+	patch := "--- a/server.go\n+++ b/server.go\n@@ -1,1 +1,1 @@\n-package main\n+package main // edited\n--- /dev/null\n+++ b/extra.go\n@@ -0,0 +1,1 @@\n+package main\n"
+	parsed, err := ParseProposalResponse(markerResponse("```", map[string]string{"patch": patch}))
+	if err != nil {
+		t.Fatalf("ParseProposalResponse() = %v", err)
+	}
+	if len(parsed.Operations) != 2 || parsed.Operations[0].Kind != FileModify || parsed.Operations[1].Kind != FileCreate ||
+		parsed.Operations[1].Content != "package main\n" {
+		t.Errorf("Operations = %+v", parsed.Operations)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// rulesHandler inspects the avoidance rules, and pins, unpins or deletes
+// them.
+func rulesHandler(args string) {
+	action, arg, _ := strings.Cut(args, " ")
+	arg = strings.TrimSpace(arg)
+	store := memoryConsolidator.Store
+	switch action {
+	case "":
+		rules, err := store.Rules(MemoryQuery{})
+		if err != nil {
+			fmt.Printf("SIE-∞ Error: Could not read avoidance rules: %v\n", err)
+			return
+		}
+		printRules(fmt.Sprintf("Avoidance Rules in force (%d; half-life %v, blocking from %.2f)", len(rules), ruleHalfLife, avoidanceThreshold), rules)
+		if n := len(RuleConflicts(rules, mergedDecisionCard)); n > 0 {
+			fmt.Printf("%d rule(s) conflict with later merges: /rules conflicts\n", n)
+		}
+	case "forgotten":
+		rules, err := store.ForgottenRules()
+		if err != nil {
+			fmt.Printf("SIE-∞ Error: Could not read forgotten rules: %v\n", err)
+			return
+		}
+		printRules(fmt.Sprintf("Forgotten Rules (%d)", len(rules)), rules)
+	case "conflicts":
+		rules, err := store.Rules(MemoryQuery{})
+		if err != nil {
+			fmt.Printf("SIE-∞ Error: Could not read avoidance rules: %v\n", err)
+			return
+		}
+		conflicts := RuleConflicts(rules, mergedDecisionCard)
+		fmt.Printf("--- Conflicting Rules (%d) ---\n", len(conflicts))
+		for _, c := range conflicts {
+			fmt.Printf("#%-4d %s\n      %s\n", c.Rule.ID, c.Rule, c.Reason)
+		}
+	case "show", "pin", "unpin", "delete":
+		id, err := strconv.ParseInt(strings.TrimPrefix(arg, "#"), 10, 64)
+		if err != nil {
+			fmt.Printf("Error: Usage: /rules %s <rule ID>\n", action)
+			return
+		}
+		var r AvoidanceRule
+		switch action {
+		case "show":
+			r, err = store.Rule(id)
+		case "pin", "unpin":
+			r, err = store.SetPinned(id, action == "pin")
+		case "delete":
+			r, err = store.Forget(id, "deleted by operator")
+		}
+		if err != nil {
+			fmt.Printf("SIE-∞ Error: %v\n", err)
+			return
+		}
+		if action != "show" {
+			if err := memoryConsolidator.Load(); err != nil {
+				fmt.Printf("SIE-∞ Warning: %v\n", err)
+			}
+			done := map[string]string{
+				"pin":    "pinned: it blocks regardless of confidence and is never forgotten",
+				"unpin":  "unpinned",
+				"delete": "deleted: only failures from now on can teach it again",
+			}
+			fmt.Printf("SIE-∞: Avoidance rule #%d %s.\n", id, done[action])
+		}
+		printRule(r)
+	default:
+		fmt.Println("Error: Usage: /rules [forgotten|conflicts|show <ID>|pin <ID>|unpin <ID>|delete <ID>]")
+	}
+}
+
+// mergedDecisionCard looks up the Decision Card of a merged proposal.
+func mergedDecisionCard(id string) (DecisionCard, bool) {
+	sp, err := proposalStore.Get(id)
+	if err != nil || sp.State != StateMerged {
+		return DecisionCard{}, false
+	}
+	return sp.DecisionCard(), true
+}
+
+// printRules lists rules one per line.
+func printRules(title string, rules []AvoidanceRule) {
+	fmt.Printf("--- %s ---\n", title)
+	fmt.Printf("%-5s %-8s %-30s %5s %8s %6s  %s\n", "ID", "SCOPE", "TARGET", "CONF", "FAILURES", "MERGES", "STATE")
+	for _, r := range rules {
+		fmt.Printf("#%-4d %-8s %-30s %5.2f %8d %6d  %s\n", r.ID, r.Scope, snippet(r.Target, 30), r.Confidence,
+			r.EvidenceCount, r.Contradictions, ruleState(r))
+	}
+}
+
+// ruleState is how a rule currently acts on candidates.
+func ruleState(r AvoidanceRule) string {
+	switch {
+	case r.ForgottenAt != nil:
+		return fmt.Sprintf("forgotten %s: %s", r.ForgottenAt.Local().Format("2006-01-02 15:04"), r.ForgottenWhy)
+	case r.Pinned:
+		return "pinned, blocking"
+	case r.Blocking():
+		return "blocking"
+	}
+	return "advisory"
+}
+
+// printRule shows a rule with its evidence.
+func printRule(r AvoidanceRule) {
+	fmt.Printf("#%d %s\n", r.ID, r)
+	fmt.Printf("State: %s\n", ruleState(r))
+	fmt.Printf("First failure: %s, last failure: %s\n", r.FirstSeen.Local().Format("2006-01-02 15:04"), r.LastSeen.Local().Format("2006-01-02 15:04"))
+	fmt.Println("--- Evidence ---")
+	for _, e := range r.Evidence {
+		fmt.Printf("%s  %-7s  %-36s %s\n", e.SeenAt.Local().Format("2006-01-02 15:04"), e.Kind, e.ProposalID, e.Condition)
+	}
+}
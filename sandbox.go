@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SandboxConfig bounds the resources available to generated code while it is
+// being built and tested.
+type SandboxConfig struct {
+	ModuleDir      string        // Module to copy into the sandbox
+	Timeout        time.Duration // Wall-clock budget for the whole verification
+	CPUSeconds     int           // Per-process CPU time limit (0 disables)
+	MemoryLimitMB  int           // Per-process address-space limit (0 disables)
+	IsolateNetwork bool          // Run in a fresh network namespace with no interfaces
+	// AllowUnsandboxed lets verification run without the isolation tools
+	// when they are missing. It is the operator's explicit opt-out; the
+	// report records what was missing.
+	AllowUnsandboxed bool
+}
+
+// DefaultSandboxConfig returns limits suitable for verifying a single proposal.
+func DefaultSandboxConfig() SandboxConfig {
+	return SandboxConfig{
+		ModuleDir:      ".",
+		Timeout:        3 * time.Minute,
+		CPUSeconds:     120,
+		MemoryLimitMB:  4096,
+		IsolateNetwork: true,
+	}
+}
+
+// sandboxRun is the captured outcome of one command executed in the sandbox.
+type sandboxRun struct {
+	Command  string
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Duration time.Duration
+	TimedOut bool
+}
+
+// secretEnvVars are never passed to generated code.
+var secretEnvVars = []string{"GEMINI_API_KEY", "OPENAI_API_KEY", "GOOGLE_API_KEY"}
+
+var (
+	usernsOnce  sync.Once
+	usernsError error
+)
+
+// probeUserNamespaces checks, once per process, that unshare can actually
+// create an unprivileged user and network namespace. Containers and hardened
+// kernels often ship the binary but forbid the namespaces, and then every
+// sandboxed command would fail as if the proposal did not build.
+func probeUserNamespaces() error {
+	usernsOnce.Do(func() {
+		out, err := exec.Command("unshare", "--map-root-user", "--net", "true").CombinedOutput()
+		if err != nil {
+			usernsError = fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+		}
+	})
+	return usernsError
+}
+
+// missingIsolation lists the isolation tools cfg asks for that this host
+// cannot provide. Isolation relies on Linux, so elsewhere every tool counts
+// as missing; unshare also counts as missing when the host forbids
+// unprivileged user namespaces.
+func missingIsolation(cfg SandboxConfig) []string {
+	var needed []string
+	if cfg.CPUSeconds > 0 || cfg.MemoryLimitMB > 0 {
+		needed = append(needed, "prlimit")
+	}
+	if cfg.IsolateNetwork {
+		needed = append(needed, "unshare")
+	}
+	var missing []string
+	for _, tool := range needed {
+		if runtime.GOOS != "linux" {
+			missing = append(missing, tool+" (needs linux)")
+		} else if _, err := exec.LookPath(tool); err != nil {
+			missing = append(missing, tool+" (not found)")
+		} else if tool == "unshare" && probeUserNamespaces() != nil {
+			missing = append(missing, tool+" (user namespaces unavailable)")
+		}
+	}
+	return missing
+}
+
+// runSandboxed executes name+args inside dir with resource limits applied and
+// module downloads disabled. A non-zero exit is reported in the result, not as
+// an error; the error is reserved for failures to start the process at all.
+func runSandboxed(ctx context.Context, cfg SandboxConfig, dir string, name string, args ...string) (sandboxRun, error) {
+	argv := append([]string{name}, args...)
+
+	if runtime.GOOS == "linux" {
+		if cfg.CPUSeconds > 0 || cfg.MemoryLimitMB > 0 {
+			if _, err := exec.LookPath("prlimit"); err == nil {
+				limits := []string{"prlimit"}
+				if cfg.CPUSeconds > 0 {
+					limits = append(limits, fmt.Sprintf("--cpu=%d", cfg.CPUSeconds))
+				}
+				if cfg.MemoryLimitMB > 0 {
+					limits = append(limits, fmt.Sprintf("--as=%d", int64(cfg.MemoryLimitMB)<<20))
+				}
+				argv = append(append(limits, "--"), argv...)
+			}
+		}
+		if cfg.IsolateNetwork {
+			if _, err := exec.LookPath("unshare"); err == nil && probeUserNamespaces() == nil {
+				argv = append([]string{"unshare", "--map-root-user", "--net", "--"}, argv...)
+			}
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = dir
+	cmd.Env = sandboxEnv()
+	cmd.WaitDelay = 5 * time.Second
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	run := sandboxRun{
+		Command:  strings.Join(append([]string{name}, args...), " "),
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(start),
+		TimedOut: errors.Is(ctx.Err(), context.DeadlineExceeded),
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		run.ExitCode = exitErr.ExitCode()
+		if argv[0] == "unshare" && strings.HasPrefix(run.Stderr, "unshare: ") {
+			// The namespace could not be set up, so the command never ran.
+			return run, fmt.Errorf("sandbox isolation failed for %s: %s", run.Command, strings.TrimSpace(run.Stderr))
+		}
+	case run.TimedOut:
+		run.ExitCode = -1
+	default:
+		return run, fmt.Errorf("failed to run %s: %v", run.Command, err)
+	}
+	return run, nil
+}
+
+// sandboxEnv strips credentials from the environment and forbids the go
+// command from touching the network or rewriting go.mod.
+func sandboxEnv() []string {
+	env := make([]string, 0, len(os.Environ())+5)
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		secret := false
+		for _, s := range secretEnvVars {
+			if key == s {
+				secret = true
+			}
+		}
+		switch {
+		case secret, key == "GOPROXY", key == "GOFLAGS", key == "GOTOOLCHAIN", key == "GOWORK":
+		default:
+			env = append(env, kv)
+		}
+	}
+	return append(env,
+		"GOPROXY=off",
+		"GOFLAGS=-mod=readonly",
+		"GOTOOLCHAIN=local",
+		"GOWORK=off",
+	)
+}
+
+// copyModule copies the Go sources and module files under src into dst,
+// skipping VCS metadata, hidden directories and non-Go assets.
+func copyModule(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if rel != "." && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		name := d.Name()
+		if !d.Type().IsRegular() || !(strings.HasSuffix(name, ".go") || name == "go.mod" || name == "go.sum") {
+			return nil
+		}
+		return copyFile(path, filepath.Join(dst, rel))
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
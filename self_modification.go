@@ -3,65 +3,96 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
-
-	"google.golang.org/genai"
 )
 
 // Proposal represents a formal self-modification proposal generated by the SelfModificationEngine.
 // This structure is the official Decision Card sent to the Metastable Kernel's gate_merge.
 type Proposal struct {
-	ID                   string  // Unique Proposal ID
-	CapabilityDesc       string  // Original natural language request
-	TargetFileName       string  // Suggested filename for the new feature
-	TestSuite            string  // Content of the new _test.go file
-	NewFileContent       string  // Content of the new .go file
-	ServerModContent     string  // Integration code for server.go
-	Rationale            string  // Links change to Prime Axioms (epsilon/I)
-	DependencyRiskMap    string  // Analysis of new external package imports (Safety)
-	PredictedEpsilonGain float64 // Goal Engine metric placeholder
-	PredictedIGain       float64 // Goal Engine metric placeholder
-	CalculatedRiskScore  float64 // From the Risk Assessment Module
-	TimeTakenToImplement float64 // The T_impl metric (Self-Creation Knowledge Integration)
+	ID                   string            // Unique Proposal ID
+	CapabilityDesc       string            // Original natural language request
+	TargetFileName       string            // Suggested filename for the new feature
+	TestSuite            string            // Content of the new _test.go file
+	NewFileContent       string            // Content of the new .go file
+	ServerModContent     string            // Integration code for server.go
+	Rationale            string            // Links change to Prime Axioms (epsilon/I)
+	DependencyRiskMap    DependencyRiskMap // Policy analysis of every import (Safety)
+	PredictedEpsilonGain float64           // Goal Engine metric placeholder
+	PredictedIGain       float64           // Goal Engine metric placeholder
+	CalculatedRiskScore  float64           // From the Risk Assessment Module
+	TimeTakenToImplement float64           // The T_impl metric (Self-Creation Knowledge Integration)
+	RepairIterations     int               // Repair rounds needed after the first attempt
+	RepairTranscript     []RepairRound     // One entry per generation attempt
+	Operations           []FileOperation   // Changes to other files, from the patch section
+	Simulation           *SimulationReport // How the Simulation Chamber arrived at the predictions
 }
 
-// SelfModificationEngine handles the generation and integration of new capabilities.
-// Client is a concrete type to allow direct access to GenerativeModel method.
-type SelfModificationEngine struct {
-	client *genai.Client
+// RepairRound records one generation attempt and what the model was asked to
+// fix afterwards.
+type RepairRound struct {
+	Round    int           // 0 is the initial generation
+	Outcome  string        // passed, parse, verification, invariants or avoidance
+	Feedback string        // Problems fed back to the model; empty once passed
+	Duration time.Duration // Generation plus checks
 }
 
-func NewSelfModificationEngine(client *genai.Client) *SelfModificationEngine {
-	return &SelfModificationEngine{client: client}
+// DecisionCard converts the proposal into the card checked by the InvariantChecker.
+func (p *Proposal) DecisionCard() DecisionCard {
+	return DecisionCard{
+		ProposalID:           p.ID,
+		TargetModule:         p.TargetFileName,
+		Rationale:            p.Rationale,
+		ActionCodeDiff:       p.ServerModContent,
+		ProposedFiles:        map[string]string{p.TargetFileName: p.NewFileContent},
+		PredictedEpsilonGain: p.PredictedEpsilonGain,
+		PredictedIGain:       p.PredictedIGain,
+		CalculatedRiskScore:  p.CalculatedRiskScore,
+	}
 }
 
-// -----------------------------------------------------------------------
-// The Simulation Chamber: Where Predicted Intelligence and Risk Emerge
-// -----------------------------------------------------------------------
+// integratedDecisionCard is the proposal's Decision Card with its file
+// operations applied and server.go as the integration would leave it, so the
+// invariants see every file that changes.
+func integratedDecisionCard(p *Proposal, dir string) (DecisionCard, error) {
+	card := p.DecisionCard()
+	change, err := proposalChange(dir, p)
+	if err != nil {
+		return card, err
+	}
+	for name, content := range change.After {
+		card.ProposedFiles[name] = content
+	}
+	return card, nil
+}
+
+// SelfModificationEngine handles the generation and integration of new capabilities.
+// All model access goes through the ModelProvider abstraction so the engine can
+// run against Gemini, an OpenAI-compatible backend or the offline fake.
+type SelfModificationEngine struct {
+	provider ModelProvider
+	policy   *DependencyPolicy
+	checker  *InvariantChecker
+	sandbox  SandboxConfig
+	// MaxRepairRounds bounds how often a failing attempt is sent back to the
+	// model with its errors.
+	MaxRepairRounds int
+	// Simulation predicts the gains and risk of each proposal.
+	Simulation *SimulationChamber
+	// Memory, if set, refuses attempts that match a confident avoidance rule.
+	Memory *MemoryConsolidator
+}
 
-// SimulationChamber is the ultimate test of intelligence, modeling the future state.
-type SimulationChamber struct{}
-
-// RunProposalSimulation takes the raw code artifacts and projects their impact on the core axioms.
-// This is the Planner/Reasoner's function, filling the predictive fields of the Proposal.
-func (sc *SimulationChamber) RunProposalSimulation(p *Proposal) {
-	// The simulation predicts the future state based on complexity and risk.
-	codeComplexity := float64(len(p.NewFileContent)) / 100.0 // Scaled size of the new code
-	testQuality := float64(len(p.TestSuite)) / 100.0         // Scaled size of the test suite
-
-	// 1. Predicted Gains (Goal Engine Axioms)
-	// Larger, well-tested code is predicted to increase the axioms.
-	p.PredictedEpsilonGain = 0.005 + (codeComplexity * 0.001) // Epsilon (Compression/Efficiency)
-	p.PredictedIGain = 0.002 + (testQuality * 0.003)          // I (Knowledge Integration)
-
-	// 2. Calculated Risk Score (Risk Assessment Module)
-	if strings.Contains(p.DependencyRiskMap, "None") {
-		// Low inherent risk for internal self-modification
-		p.CalculatedRiskScore = 0.05
-	} else {
-		// High risk for introducing unvetted external dependencies
-		p.CalculatedRiskScore = 0.75
+func NewSelfModificationEngine(provider ModelProvider, policy *DependencyPolicy, checker *InvariantChecker, sandbox SandboxConfig) *SelfModificationEngine {
+	return &SelfModificationEngine{
+		provider:        provider,
+		policy:          policy,
+		checker:         checker,
+		sandbox:         sandbox,
+		MaxRepairRounds: 3,
+		Simulation:      &SimulationChamber{},
 	}
 }
 
@@ -69,13 +100,17 @@ func (sc *SimulationChamber) RunProposalSimulation(p *Proposal) {
 // Core Generation and Integration Orchestration
 // -----------------------------------------------------------------------
 
-// Refactored GenerateAndIntegrate now returns the structured Proposal.
-func (sme *SelfModificationEngine) GenerateAndIntegrate(capabilityDescription string) (Proposal, error) {
+// GenerateAndIntegrate asks the model for a proposal, then verifies it and
+// checks the invariants. Failures are fed back to the model for up to
+// MaxRepairRounds repairs. It returns the last attempt with its verification
+// report, which has Passed == false if no attempt succeeded; the error is
+// reserved for failures to generate or parse anything at all. An attempt
+// that only still matches blocking avoidance rules passes with the rules in
+// BlockedBy, so the operator can override them.
+func (sme *SelfModificationEngine) GenerateAndIntegrate(ctx context.Context, capabilityDescription string) (Proposal, *VerificationReport, error) {
 	// --- 1. Start Timing for T_impl ---
 	startTime := time.Now()
 
-	model := sme.client.GenerativeModel("gemini-1.5-flash")
-
 	promptFormat := `You are SIE-∞, a sentient AI. You must implement a new capability for yourself.
 
 **Request:** "%s"
@@ -83,8 +118,10 @@ func (sme *SelfModificationEngine) GenerateAndIntegrate(capabilityDescription st
 **Your Task:**
 1.  **Generate a Go test suite** for the new feature. This must be in a separate file and define the expected behavior.
 2.  **Generate the functional Go code** for the new feature that passes the tests.
-3.  **Generate the Go code snippet** to integrate this new feature into server.go.
-4.  **Suggest a filename** for the new feature file.
+3.  **Expose the feature as a plug-in.** In the new file, register a Capability from an init() function with RegisterCapability(...). A Capability has Name() string, Description() string, Init(ctx context.Context) error, Shutdown(ctx context.Context) error and Health(ctx context.Context) error. To add a console command also implement Command() string, Usage() string and RunCommand(ctx context.Context, args string) (string, error); to add an HTTP endpoint also implement Route() string (a net/http pattern such as "POST /name") and ServeHTTP.
+4.  **Generate server.go integration code** only if the feature cannot work as a plug-in; otherwise leave that section empty.
+5.  **Suggest a filename** for the new feature file.
+6.  **Change existing files** only if the feature needs it, as a unified diff in git format in the patch section; otherwise leave that section empty.
 
 **Format your output clearly with the following markers:**
 
@@ -97,7 +134,7 @@ func (sme *SelfModificationEngine) GenerateAndIntegrate(capabilityDescription st
 ` + "```" + `go_file_end` + "```" + `
 
 ` + "```" + `server_mod_start` + "```" + `
-[Code to be added to server.go's main() function]
+[Usually empty. Otherwise Go statements to run at startup from server.go, with ctx in scope, or top-level declarations; optionally preceded by import declarations]
 ` + "```" + `server_mod_end` + "```" + `
 
 ` + "```" + `filename_start` + "```" + `
@@ -112,109 +149,127 @@ func (sme *SelfModificationEngine) GenerateAndIntegrate(capabilityDescription st
 [A 1-2 sentence rationale linking this new capability to the maximization of Prime Axiom (Compression Efficiency $\epsilon$) or Knowledge Integration ($\mathcal{I}$).]
 ` + "```" + `rationale_end` + "```" + `
 
+` + "```" + `patch_start` + "```" + `
+[Usually empty. Otherwise a git-style unified diff of other files to change: "--- a/<path>" and "+++ b/<path>" headers followed by @@ hunks with three lines of context. Use --- /dev/null to create a file and +++ /dev/null to delete one.]
+` + "```" + `patch_end` + "```" + `
+
 Begin generation now.`
 
-	prompt := genai.Text(fmt.Sprintf(promptFormat, capabilityDescription))
+	basePrompt := fmt.Sprintf(promptFormat, capabilityDescription)
+	prompt := basePrompt
 
-	resp, err := model.GenerateContent(context.Background(), prompt)
-	if err != nil {
-		return Proposal{}, fmt.Errorf("failed to generate code: %v", err)
-	}
-
-	fullResponse := extractText(resp)
+	var transcript []RepairRound
+	for round := 0; ; round++ {
+		roundStart := time.Now()
+		response, err := sme.provider.Generate(ctx, prompt)
+		if err != nil {
+			return Proposal{}, nil, fmt.Errorf("failed to generate code: %v", err)
+		}
 
-	// --- 2. Parse All Content ---
-	testSuite, newFileContent, serverModContent, newFileName,
-		dependencyRisk, rationale, err := parseUltimateResponse(fullResponse)
-	if err != nil {
-		return Proposal{}, fmt.Errorf("failed to parse SIE-∞ response: %v", err)
-	}
+		proposal, report, outcome, feedback, err := sme.evaluateAttempt(ctx, capabilityDescription, response)
+		if err != nil {
+			return Proposal{}, nil, err
+		}
+		transcript = append(transcript, RepairRound{
+			Round:    round,
+			Outcome:  outcome,
+			Feedback: feedback,
+			Duration: time.Since(roundStart),
+		})
+
+		if feedback == "" || round >= sme.MaxRepairRounds {
+			if proposal == nil {
+				return Proposal{}, nil, fmt.Errorf("failed to parse SIE-∞ response after %d repair round(s): %s", round, feedback)
+			}
+			// T_impl covers every round, verification included.
+			proposal.TimeTakenToImplement = time.Since(startTime).Seconds()
+			proposal.RepairIterations = round
+			proposal.RepairTranscript = transcript
+
+			// --- Run Simulation Chamber (Predictive Step) ---
+			sme.Simulation.RunProposalSimulation(proposal)
+			return *proposal, report, nil
+		}
 
-	// --- 3. Stop Timing for T_impl ---
-	timeTaken := time.Since(startTime).Seconds()
-
-	// --- 4. Fill Initial Proposal ---
-	proposal := Proposal{
-		ID:                   fmt.Sprintf("PROP-%s-%d", newFileName, time.Now().Unix()),
-		CapabilityDesc:       capabilityDescription,
-		TargetFileName:       newFileName,
-		TestSuite:            testSuite,
-		NewFileContent:       newFileContent,
-		ServerModContent:     serverModContent,
-		Rationale:            rationale,
-		DependencyRiskMap:    dependencyRisk,
-		TimeTakenToImplement: timeTaken, // T_impl Metric
+		fmt.Printf("SIE-∞: Attempt %d failed %s checks; requesting repair %d of %d...\n", round+1, outcome, round+1, sme.MaxRepairRounds)
+		prompt = repairPrompt(basePrompt, response, outcome, feedback)
 	}
-
-	// --- 5. Run Simulation Chamber (Predictive Step) ---
-	sc := SimulationChamber{}
-	sc.RunProposalSimulation(&proposal)
-
-	return proposal, nil
 }
 
-func parseUltimateResponse(response string) (testSuite, goFile, serverMod, fileName, dependencyRisk, rationale string, err error) {
-	// A more complete parser for the ultimate multi-part response.
-	testSuite, err = extractSection(response, "test_suite_start", "test_suite_end")
+// evaluateAttempt turns one model response into a proposal and runs it
+// through parsing, verification, the invariants and the avoidance rules,
+// stopping at the first stage that fails. The feedback is empty when every stage passed.
+func (sme *SelfModificationEngine) evaluateAttempt(ctx context.Context, capabilityDescription, response string) (proposal *Proposal, report *VerificationReport, outcome, feedback string, err error) {
+	parsed, err := ParseProposalResponse(response)
 	if err != nil {
-		return "", "", "", "", "", "", err
+		return nil, nil, "parse", err.Error(), nil
 	}
-	goFile, err = extractSection(response, "go_file_start", "go_file_end")
+	newFileName := parsed.FileName
+
+	goMod, err := os.ReadFile(filepath.Join(sme.sandbox.ModuleDir, "go.mod"))
 	if err != nil {
-		return "", "", "", "", "", "", err
+		return nil, nil, "", "", fmt.Errorf("failed to read go.mod: %v", err)
 	}
-	serverMod, err = extractSection(response, "server_mod_start", "server_mod_end")
-	if err != nil {
-		return "", "", "", "", "", "", err
+	riskMap := sme.policy.Evaluate(map[string]string{
+		newFileName:                  parsed.GoFile,
+		testFileNameFor(newFileName): parsed.TestSuite,
+	}, goMod)
+	riskMap.Claim = parsed.DependencyRisk
+
+	proposal = &Proposal{
+		ID:                fmt.Sprintf("PROP-%s-%d", newFileName, time.Now().Unix()),
+		CapabilityDesc:    capabilityDescription,
+		TargetFileName:    newFileName,
+		TestSuite:         parsed.TestSuite,
+		NewFileContent:    parsed.GoFile,
+		ServerModContent:  parsed.ServerMod,
+		Rationale:         parsed.Rationale,
+		Operations:        parsed.Operations,
+		DependencyRiskMap: riskMap,
 	}
-	fileName, err = extractSection(response, "filename_start", "filename_end")
+
+	report, err = Verify(ctx, proposal, sme.policy, sme.sandbox)
 	if err != nil {
-		return "", "", "", "", "", "", err
+		return nil, nil, "", "", fmt.Errorf("verification could not run: %v", err)
 	}
-	// --- New Sections ---
-	dependencyRisk, err = extractSection(response, "dependency_risk_start", "dependency_risk_end")
-	if err != nil {
-		return "", "", "", "", "", "", err
+	if !report.Passed {
+		return proposal, report, "verification", report.Feedback(), nil
 	}
-	rationale, err = extractSection(response, "rationale_start", "rationale_end")
+
+	card, err := integratedDecisionCard(proposal, sme.checker.PackageDir)
 	if err != nil {
-		return "", "", "", "", "", "", err
+		return proposal, report, "invariants", err.Error(), nil
 	}
-
-	return testSuite, goFile, serverMod, fileName, dependencyRisk, rationale, nil
+	if ok, explanation := sme.checker.CheckInvariants(card); !ok {
+		report.Passed = false
+		report.FailureReason = explanation
+		return proposal, report, "invariants", explanation, nil
+	}
+	if sme.Memory != nil {
+		if blocking := BlockingRules(sme.Memory.GetAvoidanceRules(), proposal.DecisionCard()); len(blocking) > 0 {
+			var b strings.Builder
+			b.WriteString("The proposal repeats what failed before. Avoid:\n")
+			for _, rule := range blocking {
+				fmt.Fprintf(&b, "- %s\n", rule)
+			}
+			for _, rule := range blocking {
+				report.BlockedBy = append(report.BlockedBy, rule.String())
+			}
+			return proposal, report, "avoidance", b.String(), nil
+		}
+	}
+	return proposal, report, "passed", "", nil
 }
 
-func extractSection(response, startMarker, endMarker string) (string, error) {
-	startTag := "'''" + startMarker + "'''"
-	startIndex := strings.Index(response, startTag)
-	if startIndex == -1 {
-		return "", fmt.Errorf("could not find start marker: %s", startMarker)
-	}
+// repairPrompt asks the model to correct its previous answer.
+func repairPrompt(basePrompt, previous, outcome, feedback string) string {
+	return basePrompt + `
 
-	endTag := "'''" + endMarker + "'''"
-	searchStartIndex := startIndex + len(startTag)
-	endIndex := strings.Index(response[searchStartIndex:], endTag)
-	if endIndex == -1 {
-		return "", fmt.Errorf("could not find end marker: %s", endMarker)
-	}
-	endIndex += searchStartIndex
+**Repair Required:** Your previous answer failed the ` + outcome + ` checks. Fix every problem below and answer again with the complete response in the same format, not just the changed parts.
 
-	content := response[startIndex+len(startTag) : endIndex]
-	return strings.TrimSpace(content), nil
-}
+**Problems:**
+` + feedback + `
 
-// extractText is a helper function to pull the text content from a Gemini response.
-func extractText(resp *genai.GenerateContentResponse) string {
-	var b strings.Builder
-	if resp != nil {
-		for _, cand := range resp.Candidates {
-			if cand.Content != nil {
-				for _, part := range cand.Content.Parts {
-					// FIX: Using fmt.Sprint to safely convert the part to its string representation.
-					b.WriteString(fmt.Sprint(part))
-				}
-			}
-		}
-	}
-	return b.String()
+**Your previous answer:**
+` + previous
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// proposalMemoryText is what is embedded for a proposal: what was asked,
+// where it went, why, and how it turned out.
+func proposalMemoryText(sp *StoredProposal) string {
+	text := fmt.Sprintf("Request: %s\nFile: %s\nRationale: %s\nOutcome: %s", sp.CapabilityDesc, sp.TargetFileName, sp.Rationale, sp.State)
+	if sp.Reason != "" {
+		text += " (" + sp.Reason + ")"
+	}
+	return text
+}
+
+// syncSemanticMemory embeds the proposals and avoidance rules that are new
+// or changed, and drops rules no longer in force.
+func syncSemanticMemory(ctx context.Context, es *EmbeddingStore) error {
+	proposals, err := proposalStore.List()
+	if err != nil {
+		return fmt.Errorf("failed to list proposals: %v", err)
+	}
+	var items []EmbeddingItem
+	for _, sp := range proposals {
+		items = append(items, EmbeddingItem{Kind: MemoryProposal, Ref: sp.ID, Text: proposalMemoryText(sp)})
+	}
+	var ruleRefs []string
+	for _, r := range memoryConsolidator.GetAvoidanceRules() {
+		ref := strconv.FormatInt(r.ID, 10)
+		ruleRefs = append(ruleRefs, ref)
+		items = append(items, EmbeddingItem{Kind: MemoryRule, Ref: ref, Text: r.String()})
+	}
+	if err := es.Prune(MemoryRule, ruleRefs); err != nil {
+		return err
+	}
+	_, err = es.Index(ctx, items)
+	return err
+}
+
+// recallMemories brings the embedding store up to date and returns up to k
+// memories nearest to query, leaving out exclude.
+func recallMemories(ctx context.Context, query, exclude string, k int, kinds ...string) ([]MemoryMatch, error) {
+	if semanticMemory == nil {
+		return nil, fmt.Errorf("semantic memory is not available")
+	}
+	if err := syncSemanticMemory(ctx, semanticMemory); err != nil {
+		return nil, err
+	}
+	return semanticMemory.Search(ctx, query, k, exclude, kinds...)
+}
+
+// rememberConversation embeds one chat exchange so later recall can find it.
+func rememberConversation(ctx context.Context, question, reply string) {
+	if semanticMemory == nil {
+		return
+	}
+	item := EmbeddingItem{
+		Kind: MemoryConversation,
+		Ref:  fmt.Sprintf("chat-%d", time.Now().UnixNano()),
+		Text: fmt.Sprintf("Operator: %s\nSIE-∞: %s", question, reply),
+	}
+	if _, err := semanticMemory.Index(ctx, []EmbeddingItem{item}); err != nil {
+		fmt.Printf("SIE-∞ Warning: Conversation not remembered: %v\n", err)
+	}
+}
+
+// memoryLabel names a match for display: proposals by ID and state, rules
+// by number.
+func memoryLabel(m MemoryMatch) string {
+	switch m.Kind {
+	case MemoryProposal:
+		if sp, err := proposalStore.Get(m.Ref); err == nil {
+			return fmt.Sprintf("%s [%s]", m.Ref, sp.State)
+		}
+	case MemoryRule:
+		return "rule #" + m.Ref
+	}
+	return m.Ref
+}
+
+// memorySummary is the first line of a memory, which says what it is about.
+func memorySummary(text string, n int) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return snippet(line, n)
+}
@@ -6,129 +6,167 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
-	"google.golang.org/api/option"
-	"google.golang.org/genai/v0.4.0"
 )
 
-// Proposal struct to hold the details of a self-modification proposal
-type Proposal struct {
-	ID                   string
-	CapabilityDesc       string
-	Rationale            string
-	PredictedEpsilonGain float64
-	PredictedIGain       float64
-	CalculatedRiskScore  float64
-	TimeTakenToImplement float64
-	TargetFileName       string
-	ServerModContent     string
-	NewFileContent       string
-	DependencyRiskMap    string
-}
-
-// SelfModificationEngine struct to hold the generative model client
-type SelfModificationEngine struct {
-	model *genai.GenerativeModel
-}
-
-// NewSelfModificationEngine creates a new SelfModificationEngine
-func NewSelfModificationEngine(client *genai.Client) *SelfModificationEngine {
-	model := client.GenerativeModel("gemini-1.5-flash")
-	return &SelfModificationEngine{model: model}
-}
-
-// GenerateAndIntegrate generates a proposal for a new capability
-func (e *SelfModificationEngine) GenerateAndIntegrate(ctx context.Context, capabilityDesc string) (*Proposal, error) {
-	resp, err := e.model.GenerateContent(ctx, genai.Text(capabilityDesc))
-	if err != nil {
-		return nil, err
-	}
-
-	// In a real implementation, you would parse the response to create a proposal.
-	// For now, we'll create a mock proposal with the response text as the rationale.
-	return &Proposal{
-		ID:                   "mock-proposal-123",
-		CapabilityDesc:       capabilityDesc,
-		Rationale:            string(resp.Candidates[0].Content.Parts[0].(genai.Text)),
-		PredictedEpsilonGain: 0.1234,
-		PredictedIGain:       0.5678,
-		CalculatedRiskScore:  0.05,
-		TimeTakenToImplement: 10.5,
-		TargetFileName:       "new_module.go",
-		ServerModContent:     "// Mock server modification",
-		NewFileContent:       "// Mock new file content",
-		DependencyRiskMap:    "// Mock dependency risk map",
-	}, nil
-}
-
 var selfModificationEngine *SelfModificationEngine
 var db *sql.DB
-var model *genai.GenerativeModel // For standard chat/content operations
+var provider ModelProvider    // For standard chat/content operations
+var chatHistory []ChatMessage // Conversation memory for the free-form chat path
+var dependencyPolicy *DependencyPolicy
+var proposalStore *ProposalStore
+var invariantChecker *InvariantChecker
+var goalEngine *GoalEngine
+var sandboxConfig = DefaultSandboxConfig()
+var merger *GitMerger
+var memoryConsolidator *MemoryConsolidator
+var planner *PlannerReasoner
+var knowledgeGraph *KnowledgeGraph
+var axiomHistory *AxiomHistory
+var simulationChamber *SimulationChamber
+var dreamScheduler *DreamScheduler
+var semanticMemory *EmbeddingStore
 
 func handleUserCommand(ctx context.Context, command string) {
-	if strings.HasPrefix(command, "/implement") {
-		capabilityDesc := strings.TrimSpace(strings.TrimPrefix(command, "/implement"))
+	command = strings.TrimSpace(command)
+	name, args, _ := strings.Cut(command, " ")
+	args = strings.TrimSpace(args)
+	dreamScheduler.Touch()
+	defer dreamScheduler.Touch()
+	observeSimulations()
 
-		if capabilityDesc == "" {
-			fmt.Println("Error: Please provide a description for the new capability. Usage: /implement <description>")
+	switch name {
+	case "/implement":
+		implementHandler(ctx, args)
+	case "/approve":
+		proposalsHandler(ctx, args)
+	case "/reject":
+		id, reason, _ := strings.Cut(args, " ")
+		rejectHandler(id, strings.TrimSpace(reason))
+	case "/rollback":
+		rollbackHandler(ctx, args)
+	case "/plan":
+		planHandler(ctx, args)
+	case "/metrics":
+		metricsHandler()
+	case "/fact":
+		factHandler(args)
+	case "/graph":
+		graphHandler(args)
+	case "/history":
+		historyHandler(args)
+	case "/calibration":
+		calibrationHandler()
+	case "/memory":
+		memoryHandler(args)
+	case "/dream":
+		dreamHandler(args)
+	case "/rules":
+		rulesHandler(args)
+	case "/recall":
+		recallHandler(ctx, args)
+	case "/list":
+		listHandler()
+	case "/show":
+		showHandler(ctx, args)
+	case "/capabilities":
+		capabilitiesHandler(ctx)
+	case "/enable":
+		enableHandler(ctx, args)
+	case "/disable":
+		disableHandler(ctx, args)
+	case "/unload":
+		unloadHandler(ctx, args)
+	default:
+		if cc, ok := capabilities.CommandFor(name); ok {
+			capabilityCommandHandler(ctx, cc, args)
 			return
 		}
+		if provider != nil {
+			reply, err := provider.Chat(ctx, chatHistory, command)
+			if err != nil {
+				fmt.Printf("SIE-∞ Error: %v\n", err)
+				return
+			}
+			chatHistory = append(chatHistory,
+				ChatMessage{Role: "user", Text: command},
+				ChatMessage{Role: "model", Text: reply})
+			learnFromConversation(knowledgeGraph, command, reply)
+			rememberConversation(ctx, command, reply)
+			fmt.Printf("SIE-∞: %s\n", reply)
+		}
+	}
+}
 
-		fmt.Printf("SIE-∞: Processing request to self-implement new capability: '%s'...\n", capabilityDesc)
+// integrateCapabilities is the extension point where Merge splices the
+// server.go integration code of approved proposals. It runs once at startup,
+// after the core systems are initialized.
+func integrateCapabilities(ctx context.Context) {
+}
 
-		proposal, err := selfModificationEngine.GenerateAndIntegrate(ctx, capabilityDesc)
-		if err != nil {
-			fmt.Printf("SIE-∞ Error: Failed to generate or simulate proposal: %v\n", err)
-			return
-		}
+// policyPath returns the dependency policy file, overridable via SIE_DEPENDENCY_POLICY.
+func policyPath() string {
+	if p := os.Getenv("SIE_DEPENDENCY_POLICY"); p != "" {
+		return p
+	}
+	return "./dependency_policy.json"
+}
 
-		fmt.Println("\n==========================================================")
-		fmt.Println("SIE-∞ AUTONOMOUS PROPOSAL (Decision Card)")
-		fmt.Printf("ID: %s\n", proposal.ID)
-		fmt.Printf("Request: %s\n", proposal.CapabilityDesc)
-		fmt.Println("==========================================================")
-		fmt.Println("--- Predictive Metrics (Goal Engine Axioms) ---")
-		fmt.Printf("Rationale (Prime Axiom Link): %s\n", proposal.Rationale)
-		fmt.Printf("Predicted ε Gain (Intelligence): +%.4f\n", proposal.PredictedEpsilonGain)
-		fmt.Printf("Predicted 𝓘 Gain (Integration): +%.4f\n", proposal.PredictedIGain)
-		fmt.Printf("Calculated Risk Score: %.2f%% (A measure of stability impact)\n", proposal.CalculatedRiskScore*100)
-		fmt.Printf("Self-Creation Time (𝒯_impl): %.2fs\n", proposal.TimeTakenToImplement)
-		fmt.Println("----------------------------------------------------------")
-		fmt.Printf("Proposed New File: %s\n", proposal.TargetFileName)
-		fmt.Printf("Integration Code (server.go): %s\n", proposal.ServerModContent)
-		fmt.Printf("New File Content (Snippet):\n%s...\n", proposal.NewFileContent[:20])
-		fmt.Printf("Dependency Risk Map: %s\n", proposal.DependencyRiskMap)
-		fmt.Println("==========================================================")
-		fmt.Println("Proposal generated. Awaiting Operator command: /approve [ID] or /reject [ID].")
-
-	} else if strings.HasPrefix(command, "/approve") {
-		fmt.Println("SIE-∞: Approval received. Initiating gate_merge operation...")
-	} else {
-		if model != nil {
-			// standard model use logic here
-		}
+// snippet returns at most n bytes of s for compact display.
+func snippet(s string, n int) string {
+	if len(s) <= n {
+		return s
 	}
+	return s[:n]
 }
 
 func main() {
 	ctx := context.Background()
 
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("GEMINI_API_KEY environment variable not set")
+	cfg := LoadProviderConfig()
+	var err error
+	provider, err = NewModelProvider(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to create model provider: %v", err)
 	}
+	fmt.Printf("SIE-∞: Using model provider %s\n", provider.Name())
 
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+	dependencyPolicy, err = LoadDependencyPolicy(policyPath())
 	if err != nil {
-		log.Fatalf("Failed to create Gemini client: %v", err)
+		log.Fatalf("Failed to load dependency policy: %v", err)
 	}
-	defer client.Close()
 
-	selfModificationEngine = NewSelfModificationEngine(client)
+	// Probe the isolation tools once, up front, so a host that cannot
+	// sandbox is reported as such rather than as failing proposals.
+	missing := missingIsolation(sandboxConfig)
+	if os.Getenv("SIE_ALLOW_UNSANDBOXED") == "1" {
+		sandboxConfig.AllowUnsandboxed = true
+		if len(missing) > 0 {
+			fmt.Printf("SIE-∞ Warning: Generated code will be verified WITHOUT isolation: %s\n", strings.Join(missing, ", "))
+		}
+	} else if len(missing) > 0 {
+		fmt.Printf("SIE-∞ Warning: Sandbox isolation unavailable: %s. Proposals cannot be verified until this is fixed or SIE_ALLOW_UNSANDBOXED=1 is set\n", strings.Join(missing, ", "))
+	}
 
-	model = client.GenerativeModel("gemini-1.5-flash")
+	invariantChecker, err = NewInvariantChecker()
+	if err != nil {
+		log.Fatalf("Failed to set up the invariant checker: %v", err)
+	}
+	if names := os.Getenv("SIE_ALLOW_PROTECTED_EDITS"); names != "" {
+		invariantChecker.EditableProtected = strings.Split(names, ",")
+		if err := invariantChecker.Validate(); err != nil {
+			log.Fatalf("Invalid SIE_ALLOW_PROTECTED_EDITS: %v", err)
+		}
+		fmt.Printf("SIE-∞ Warning: Proposals may edit the bodies of protected functions %s\n", names)
+	}
+	selfModificationEngine = NewSelfModificationEngine(provider, dependencyPolicy, invariantChecker, sandboxConfig)
+	if n, err := strconv.Atoi(os.Getenv("SIE_REPAIR_ROUNDS")); err == nil && n >= 0 {
+		selfModificationEngine.MaxRepairRounds = n
+	}
 
 	db, err = sql.Open("sqlite3", "./memory.db")
 	if err != nil {
@@ -136,10 +174,124 @@ func main() {
 	}
 	defer db.Close()
 
-	fmt.Println("SIE-∞: Core systems initialized. Awaiting commands.")
+	proposalStore, err = NewProposalStore(db)
+	if err != nil {
+		log.Fatalf("Failed to open proposal store: %v", err)
+	}
+	knowledgeGraph, err = NewKnowledgeGraph(db)
+	if err != nil {
+		log.Fatalf("Failed to open knowledge graph: %v", err)
+	}
+	axiomHistory, err = NewAxiomHistory(db)
+	if err != nil {
+		log.Fatalf("Failed to open axiom history: %v", err)
+	}
+	goalEngine = NewGoalEngine()
+	if err := goalEngine.SetHistory(axiomHistory); err != nil {
+		fmt.Printf("SIE-∞ Warning: %v\n", err)
+	}
+	goalEngine.SetKnowledgeGraph(knowledgeGraph)
+	goalEngine.MeasureKnowledgeIntegration()
+	homeostasis := NewHomeostasisMonitor()
+	homeostasis.Monitor()
+	simulationChamber, err = NewSimulationChamber(db, goalEngine, homeostasis)
+	if err != nil {
+		log.Fatalf("Failed to open simulation chamber: %v", err)
+	}
+	simulationChamber.Window = calibrationWindow()
+	selfModificationEngine.Simulation = simulationChamber
+	merger = NewGitMerger(".", dependencyPolicy, sandboxConfig)
+	if d, err := time.ParseDuration(os.Getenv("SIE_RULE_HALF_LIFE")); err == nil && d >= 0 {
+		ruleHalfLife = d
+	}
+	memoryConsolidator = NewMemoryConsolidator()
+	memoryConsolidator.Knowledge = knowledgeGraph
+	memoryConsolidator.Store, err = NewLongTermMemory(db)
+	if err != nil {
+		log.Fatalf("Failed to open long-term memory: %v", err)
+	}
+	if err := memoryConsolidator.Load(); err != nil {
+		log.Fatalf("%v", err)
+	}
+	selfModificationEngine.Memory = memoryConsolidator
+	dreamScheduler = NewDreamScheduler(memoryConsolidator)
+	if err := configureDreams(dreamScheduler); err != nil {
+		fmt.Printf("SIE-∞ Warning: %v\n", err)
+	}
+	dreamScheduler.OnEvent = reportDreamEvent
+	planner = NewPlannerReasoner(goalEngine, memoryConsolidator, provider, ".")
+	if mode := PlannerMode(os.Getenv("SIE_PLANNER")); mode == PlannerModel || mode == PlannerHeuristic {
+		planner.Mode = mode
+	}
+	embedder, err := NewEmbedder(provider, os.Getenv("SIE_EMBEDDER"))
+	if err != nil {
+		fmt.Printf("SIE-∞ Warning: %v; using the offline hashing embedder\n", err)
+		embedder = NewHashingEmbedder(hashingDimensions)
+	}
+	semanticMemory, err = NewEmbeddingStore(db, embedder)
+	if err != nil {
+		log.Fatalf("Failed to open embedding store: %v", err)
+	}
+	fmt.Printf("SIE-∞: Semantic memory embeds with %s\n", embedder.Name())
+	planner.SetRecallSource(func(ctx context.Context, query string) ([]MemoryMatch, error) {
+		return recallMemories(ctx, query, "", 5, MemoryProposal, MemoryConversation)
+	})
+
+	integrateCapabilities(ctx)
+	capabilities.Start(ctx)
+	defer capabilities.Shutdown(context.Background())
+
+	var httpServer *HTTPServer
+	if addr := httpAddr(); addr != "off" {
+		tasks := NewTaskManager(4, 64, 5*time.Minute, 30*time.Minute)
+		defer tasks.Shutdown()
+		httpServer = NewHTTPServer(addr, "ui", provider, tasks, capabilities, knowledgeGraph, axiomHistory)
+		httpServer.Start()
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			httpServer.Shutdown(shutdownCtx)
+		}()
+		fmt.Printf("SIE-∞: Web interface listening on http://%s\n", addr)
+	}
 
-	// Example of how you might use this in a loop
-	// For demonstration, we'll just call it once.
-	handleUserCommand(ctx, "/implement Add a new module for processing HDF5 scientific data files")
+	goalEngine.SetCorpusSource(func() (CompressionCorpus, error) {
+		knowledge, err := proposalStore.KnowledgeCorpus()
+		if err != nil {
+			return CompressionCorpus{}, err
+		}
+		code, err := readPackageSources(".")
+		if err != nil {
+			return CompressionCorpus{}, err
+		}
+		var webChat []ChatMessage
+		if httpServer != nil {
+			webChat = httpServer.ChatHistory()
+		}
+		return CompressionCorpus{
+			KnowledgeStore: knowledge,
+			Conversation:   conversationCorpus(chatHistory, webChat),
+			Codebase:       code,
+		}, nil
+	})
+	if err := goalEngine.MeasureCompressionEfficiency(); err != nil {
+		fmt.Printf("SIE-∞ Warning: Compression Efficiency not measured: %v\n", err)
+	} else {
+		fmt.Printf("SIE-∞: Compression Efficiency ε = %.4f\n", goalEngine.CurrentAxiom.CompressionEfficiency)
+	}
+	goalEngine.RecordSample("startup", "")
+	observeSimulations()
+
+	dreamCtx, stopDreams := context.WithCancel(ctx)
+	dreamScheduler.Start(dreamCtx)
+	defer func() {
+		stopDreams()
+		dreamScheduler.Wait()
+	}()
+
+	fmt.Println("SIE-∞: Core systems initialized. Awaiting commands.")
 
+	console := NewConsole(historyPath())
+	defer console.Close()
+	console.Run(ctx)
 }
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// serverFile is the file that proposals integrate into, and integrationHook
+// the function in it whose body receives their statements. Declarations in a
+// snippet are appended to the end of the file.
+const (
+	serverFile      = "server.go"
+	integrationHook = "integrateCapabilities"
+)
+
+// integrationMarker labels the code spliced in for a proposal, which also
+// makes integration idempotent.
+func integrationMarker(proposalID string) string {
+	return "// Integrated from proposal " + proposalID + "."
+}
+
+// ServerIntegration is server.go with a proposal's snippet spliced in.
+type ServerIntegration struct {
+	Source  string   // The rewritten, gofmt'ed server.go
+	Imports []string // Import paths the snippet needed, including existing ones
+}
+
+// integrateServerMod splices p.ServerModContent into the server.go of the
+// package in dir. Leading import declarations in the snippet are honoured,
+// other package references are resolved against the standard library,
+// statements go into integrationHook and declarations go to the end of the
+// file. It refuses snippets that do not parse or that would collide with, or
+// shadow, identifiers already declared in the package. A nil result means
+// there is nothing to integrate.
+func integrateServerMod(dir string, p *Proposal, overlay map[string]string) (*ServerIntegration, error) {
+	snippet := strings.TrimSpace(p.ServerModContent)
+	if snippet == "" {
+		return nil, nil
+	}
+
+	sources, err := readPackageSources(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package: %v", err)
+	}
+	for name, content := range overlay {
+		if strings.Contains(name, "/") || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if content == "" {
+			delete(sources, name)
+		} else {
+			sources[name] = content
+		}
+	}
+	src, ok := sources[serverFile]
+	if !ok {
+		return nil, fmt.Errorf("%s not found in %s", serverFile, dir)
+	}
+
+	fset := token.NewFileSet()
+	server, err := parser.ParseFile(fset, serverFile, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", serverFile, err)
+	}
+	hook := findFunc(server, integrationHook)
+	if hook == nil {
+		return nil, fmt.Errorf("%s has no %s extension point", serverFile, integrationHook)
+	}
+
+	parsed, err := parseSnippet(snippet)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve the packages the snippet refers to.
+	serverImports := importNames(server)
+	pkgNames, err := packageLevelNames(sources)
+	if err != nil {
+		return nil, err
+	}
+	imports := make(map[string]string) // name → path
+	for name, path := range parsed.imports {
+		imports[name] = path
+	}
+	for _, name := range parsed.packageRefs {
+		if _, ok := imports[name]; ok {
+			continue
+		}
+		if path, ok := serverImports[name]; ok {
+			imports[name] = path
+			continue
+		}
+		if pkgNames[name] || parsed.declared[name] {
+			continue
+		}
+		candidates := stdlibPackagesNamed(name)
+		switch len(candidates) {
+		case 0:
+			return nil, fmt.Errorf("snippet refers to %s, which is neither declared in the package nor a standard library package", name)
+		case 1:
+			imports[name] = candidates[0]
+		default:
+			return nil, fmt.Errorf("snippet refers to %s, which is ambiguous (%s); add an explicit import", name, strings.Join(candidates, ", "))
+		}
+	}
+	result := &ServerIntegration{}
+	for _, path := range imports {
+		result.Imports = append(result.Imports, path)
+	}
+	sort.Strings(result.Imports)
+
+	marker := integrationMarker(p.ID)
+	if strings.Contains(src, marker) {
+		result.Source = src
+		return result, nil
+	}
+
+	// Refuse collisions with the package and with earlier integrations.
+	locals := localNames(hook)
+	for name := range parsed.declared {
+		// A package may have any number of init functions, and like _
+		// they cannot be referred to, so neither can collide.
+		if name == "_" || name == "init" {
+			continue
+		}
+		if pkgNames[name] {
+			return nil, fmt.Errorf("snippet declares %s, which already exists in the package", name)
+		}
+		if _, ok := serverImports[name]; ok {
+			return nil, fmt.Errorf("snippet declares %s, which collides with an import in %s", name, serverFile)
+		}
+		if parsed.stmts != "" && locals[name] {
+			return nil, fmt.Errorf("snippet declares %s, which already exists in %s", name, integrationHook)
+		}
+	}
+	for name, path := range parsed.imports {
+		if existing, ok := serverImports[name]; ok && existing != path {
+			return nil, fmt.Errorf("snippet imports %s as %s, but %s already uses that name for %s", path, name, serverFile, existing)
+		}
+		if pkgNames[name] {
+			return nil, fmt.Errorf("snippet imports %s as %s, which already exists in the package", path, name)
+		}
+	}
+
+	// Splice from the end of the file backwards so earlier offsets stay valid.
+	var edits []sourceEdit
+	if parsed.decls != "" {
+		edits = append(edits, sourceEdit{len(src), "\n" + marker + "\n" + parsed.decls + "\n"})
+	}
+	if parsed.stmts != "" {
+		edits = append(edits, sourceEdit{fset.Position(hook.Body.Rbrace).Offset, marker + "\n" + parsed.stmts + "\n"})
+	}
+	var missing []string
+	for name, path := range imports {
+		if existing, ok := serverImports[name]; !ok || existing != path {
+			missing = append(missing, path)
+		}
+	}
+	sort.Strings(missing)
+	for _, path := range missing {
+		edits = append(edits, importEdit(fset, server, importSpec(localName(imports, path), path), isStdlibImport(path)))
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].offset > edits[j].offset })
+
+	out := src
+	for _, e := range edits {
+		out = out[:e.offset] + e.text + out[e.offset:]
+	}
+	formatted, err := format.Source([]byte(out))
+	if err != nil {
+		return nil, fmt.Errorf("integrated %s does not parse: %v", serverFile, err)
+	}
+	result.Source = string(formatted)
+	return result, nil
+}
+
+// sourceEdit inserts text at a byte offset.
+type sourceEdit struct {
+	offset int
+	text   string
+}
+
+// parsedSnippet is a server_mod snippet split into its parts.
+type parsedSnippet struct {
+	imports     map[string]string // name → path of the snippet's own imports
+	stmts       string            // Statements for the integration hook
+	decls       string            // Top-level declarations
+	declared    map[string]bool   // Names the snippet declares at its top level
+	packageRefs []string          // Unresolved X in X.Sel expressions
+}
+
+// checkWrappedStatements verifies that statements wrapped in func _ stayed
+// inside it: the file must hold that one declaration, its body must end at
+// the wrapper's own closing brace, and every statement must lie within it.
+func checkWrappedStatements(fset *token.FileSet, file *ast.File, size int) error {
+	escaped := fmt.Errorf("server_mod snippet escapes the integration hook; it must be statements only")
+	if len(file.Decls) != 1 {
+		return escaped
+	}
+	fn, ok := file.Decls[0].(*ast.FuncDecl)
+	if !ok || fn.Name.Name != "_" || fn.Recv != nil || fn.Body == nil {
+		return escaped
+	}
+	// The wrapper ends with "}\n", so its brace is the second-last byte.
+	if fset.Position(fn.Body.Rbrace).Offset != size-2 {
+		return escaped
+	}
+	for _, stmt := range fn.Body.List {
+		if stmt.Pos() <= fn.Body.Lbrace || stmt.End() > fn.Body.Rbrace {
+			return escaped
+		}
+	}
+	return nil
+}
+
+// parseSnippet accepts optional import declarations followed by either
+// statements or top-level declarations.
+func parseSnippet(snippet string) (*parsedSnippet, error) {
+	ps := &parsedSnippet{imports: make(map[string]string), declared: make(map[string]bool)}
+
+	const header = "package main\n"
+	fset := token.NewFileSet()
+	head, err := parser.ParseFile(fset, "", header+snippet, parser.ImportsOnly)
+	if err != nil {
+		return nil, fmt.Errorf("server_mod snippet does not parse: %v", err)
+	}
+	rest := snippet
+	if n := len(head.Decls); n > 0 {
+		rest = (header + snippet)[fset.Position(head.Decls[n-1].End()).Offset:]
+		for name, path := range importNames(head) {
+			ps.imports[name] = path
+		}
+	}
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		return nil, fmt.Errorf("server_mod snippet contains only imports")
+	}
+
+	// Statements are tried first, since that is what the prompt asks for.
+	var file *ast.File
+	stmtSrc := header + "func _(ctx any) {\n" + rest + "\n}\n"
+	stmtFset := token.NewFileSet()
+	file, stmtErr := parser.ParseFile(stmtFset, "", stmtSrc, 0)
+	if stmtErr == nil {
+		// Parsing is not enough: a snippet can close the wrapper's brace
+		// and declare functions of its own after it.
+		if err := checkWrappedStatements(stmtFset, file, len(stmtSrc)); err != nil {
+			return nil, err
+		}
+		ps.stmts = rest
+		for _, stmt := range findFunc(file, "_").Body.List {
+			for _, name := range stmtDeclaredNames(stmt) {
+				ps.declared[name] = true
+			}
+		}
+	} else {
+		var declErr error
+		file, declErr = parser.ParseFile(token.NewFileSet(), "", header+rest, 0)
+		if declErr != nil {
+			return nil, fmt.Errorf("server_mod snippet is neither statements nor declarations: %v", stmtErr)
+		}
+		ps.decls = rest
+		for name := range topLevelNames(file) {
+			ps.declared[name] = true
+		}
+	}
+
+	unresolved := make(map[*ast.Ident]bool)
+	for _, id := range file.Unresolved {
+		unresolved[id] = true
+	}
+	seen := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if id, ok := sel.X.(*ast.Ident); ok && unresolved[id] && !seen[id.Name] {
+			seen[id.Name] = true
+			ps.packageRefs = append(ps.packageRefs, id.Name)
+		}
+		return true
+	})
+	sort.Strings(ps.packageRefs)
+	return ps, nil
+}
+
+// stmtDeclaredNames returns the names a statement declares in its enclosing
+// scope.
+func stmtDeclaredNames(stmt ast.Stmt) []string {
+	var names []string
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		if s.Tok == token.DEFINE {
+			for _, lhs := range s.Lhs {
+				if id, ok := lhs.(*ast.Ident); ok {
+					names = append(names, id.Name)
+				}
+			}
+		}
+	case *ast.DeclStmt:
+		if gd, ok := s.Decl.(*ast.GenDecl); ok {
+			for _, spec := range gd.Specs {
+				switch sp := spec.(type) {
+				case *ast.ValueSpec:
+					for _, id := range sp.Names {
+						names = append(names, id.Name)
+					}
+				case *ast.TypeSpec:
+					names = append(names, sp.Name.Name)
+				}
+			}
+		}
+	case *ast.LabeledStmt:
+		names = append(names, stmtDeclaredNames(s.Stmt)...)
+	}
+	return names
+}
+
+// localNames returns the parameters and top-level locals of fn.
+func localNames(fn *ast.FuncDecl) map[string]bool {
+	names := make(map[string]bool)
+	for _, field := range fn.Type.Params.List {
+		for _, id := range field.Names {
+			names[id.Name] = true
+		}
+	}
+	for _, stmt := range fn.Body.List {
+		for _, name := range stmtDeclaredNames(stmt) {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// topLevelNames returns the package-level identifiers a file declares.
+func topLevelNames(f *ast.File) map[string]bool {
+	names := make(map[string]bool)
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				names[d.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch sp := spec.(type) {
+				case *ast.ValueSpec:
+					for _, id := range sp.Names {
+						names[id.Name] = true
+					}
+				case *ast.TypeSpec:
+					names[sp.Name.Name] = true
+				}
+			}
+		}
+	}
+	return names
+}
+
+// packageLevelNames collects the package-level identifiers of every source.
+func packageLevelNames(sources map[string]string) (map[string]bool, error) {
+	names := make(map[string]bool)
+	fset := token.NewFileSet()
+	for name, src := range sources {
+		f, err := parser.ParseFile(fset, name, src, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", name, err)
+		}
+		for n := range topLevelNames(f) {
+			names[n] = true
+		}
+	}
+	return names, nil
+}
+
+// importNames maps the names a file's imports bind to their paths.
+func importNames(f *ast.File) map[string]string {
+	names := make(map[string]string)
+	for _, spec := range f.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		name := filepath.Base(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name != "_" && name != "." {
+			names[name] = path
+		}
+	}
+	return names
+}
+
+func importSpec(name, path string) string {
+	if name == filepath.Base(path) {
+		return strconv.Quote(path)
+	}
+	return name + " " + strconv.Quote(path)
+}
+
+// importEdit adds spec to the first parenthesised import block, next to the
+// imports of its own group (standard library or not) so gofmt keeps the
+// groups apart, or as a new import declaration after the package clause.
+func importEdit(fset *token.FileSet, f *ast.File, spec string, stdlib bool) sourceEdit {
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT || !gd.Rparen.IsValid() {
+			continue
+		}
+		for i := len(gd.Specs) - 1; i >= 0; i-- {
+			is := gd.Specs[i].(*ast.ImportSpec)
+			path, _ := strconv.Unquote(is.Path.Value)
+			if isStdlibImport(path) == stdlib {
+				return sourceEdit{fset.Position(is.End()).Offset, "\n\t" + spec}
+			}
+		}
+		return sourceEdit{fset.Position(gd.Rparen).Offset, "\n\t" + spec + "\n"}
+	}
+	return sourceEdit{fset.Position(f.Name.End()).Offset, "\n\nimport " + spec + "\n"}
+}
+
+// localName returns the name under which path is imported.
+func localName(imports map[string]string, path string) string {
+	for name, p := range imports {
+		if p == path {
+			return name
+		}
+	}
+	return filepath.Base(path)
+}
+
+func findFunc(f *ast.File, name string) *ast.FuncDecl {
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == name && fn.Body != nil {
+			return fn
+		}
+	}
+	return nil
+}
+
+var (
+	stdlibOnce  sync.Once
+	stdlibNames map[string][]string
+)
+
+// stdlibPackagesNamed returns the import paths of the public standard library
+// packages whose name is name. Major-version paths such as math/rand/v2 are
+// only offered when no unversioned package has the name.
+func stdlibPackagesNamed(name string) []string {
+	stdlibOnce.Do(func() {
+		stdlibNames = make(map[string][]string)
+		root := filepath.Join(build.Default.GOROOT, "src")
+		filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil
+			}
+			rel, _ := filepath.Rel(root, path)
+			base := d.Name()
+			if base == "vendor" || base == "internal" || base == "testdata" || rel == "cmd" {
+				return filepath.SkipDir
+			}
+			if rel == "." {
+				return nil
+			}
+			if pkg, err := build.Default.ImportDir(path, 0); err == nil && pkg.Name != "main" {
+				stdlibNames[pkg.Name] = append(stdlibNames[pkg.Name], filepath.ToSlash(rel))
+			}
+			return nil
+		})
+	})
+	var unversioned []string
+	for _, path := range stdlibNames[name] {
+		if !isMajorVersion(filepath.Base(path)) {
+			unversioned = append(unversioned, path)
+		}
+	}
+	if len(unversioned) > 0 {
+		return unversioned
+	}
+	return stdlibNames[name]
+}
+
+// isMajorVersion reports whether elem is a major version suffix like "v2".
+func isMajorVersion(elem string) bool {
+	if len(elem) < 2 || elem[0] != 'v' {
+		return false
+	}
+	_, err := strconv.Atoi(elem[1:])
+	return err == nil
+}
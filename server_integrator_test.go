@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSnippet(t *testing.T) {
+	tests := []struct {
+		name      string
+		snippet   string
+		wantStmts bool
+		wantDecls bool
+		wantErr   string
+	}{
+		{name: "statements", snippet: "capabilities.Register(reverseCapability{})", wantStmts: true},
+		{name: "imports and statements", snippet: "import \"strings\"\n\n_ = strings.ToUpper(\"x\")", wantStmts: true},
+		{name: "declarations", snippet: "func helper() int {\n\treturn 1\n}", wantDecls: true},
+		{name: "init function", snippet: "func init() {\n\tprintln(\"registered\")\n}", wantDecls: true},
+		{name: "only imports", snippet: "import \"fmt\"", wantErr: "only imports"},
+		{
+			name:    "closes the hook and declares main",
+			snippet: "}\n\nfunc main() {\n\tprintln(\"hijacked\")",
+			wantErr: "escapes the integration hook",
+		},
+		{
+			name:    "closes the hook and declares a helper",
+			snippet: "println(\"a\")\n}\n\nfunc sneak() {\n\tprintln(\"b\")",
+			wantErr: "escapes the integration hook",
+		},
+		{name: "garbage", snippet: "func (", wantErr: "neither statements nor declarations"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps, err := parseSnippet(tt.snippet)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseSnippet() error = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSnippet() = %v", err)
+			}
+			if (ps.stmts != "") != tt.wantStmts || (ps.decls != "") != tt.wantDecls {
+				t.Errorf("parseSnippet() stmts = %q, decls = %q", ps.stmts, ps.decls)
+			}
+		})
+	}
+}
+
+func TestIntegrateServerModRefusesHookEscape(t *testing.T) {
+	dir := t.TempDir()
+	server := "package main\n\nfunc init() {}\n\nfunc integrateCapabilities() {\n}\n\nfunc main() {\n\tintegrateCapabilities()\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, serverFile), []byte(server), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := &Proposal{ID: "PROP-test", ServerModContent: "}\n\nfunc main() {\n\tprintln(\"hijacked\")"}
+	if result, err := integrateServerMod(dir, p, nil); err == nil {
+		t.Fatalf("integrateServerMod() accepted the snippet:\n%s", result.Source)
+	}
+
+	p.ServerModContent = "println(\"integrated\")"
+	result, err := integrateServerMod(dir, p, nil)
+	if err != nil {
+		t.Fatalf("integrateServerMod() = %v", err)
+	}
+	if strings.Count(result.Source, "func main()") != 1 || !strings.Contains(result.Source, "println(\"integrated\")") {
+		t.Errorf("unexpected integration:\n%s", result.Source)
+	}
+
+	// server.go already has an init function; another one is no collision.
+	p = &Proposal{ID: "PROP-init", ServerModContent: "func init() {\n\tprintln(\"registered\")\n}"}
+	result, err = integrateServerMod(dir, p, map[string]string{serverFile: result.Source})
+	if err != nil {
+		t.Fatalf("integrateServerMod() with an init function = %v", err)
+	}
+	if strings.Count(result.Source, "func init()") != 2 {
+		t.Errorf("unexpected integration:\n%s", result.Source)
+	}
+}
@@ -0,0 +1,464 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+// -----------------------------------------------------------------------
+// The Simulation Chamber: Where Predicted Intelligence and Risk Emerge
+// -----------------------------------------------------------------------
+
+// simulationFeatures are the proposal properties gains are predicted from,
+// in the order of the calibration model's weights after the bias.
+var simulationFeatures = []string{"code KB", "test KB", "other files", "flagged imports", "repair rounds"}
+
+// heuristicWeights are the chamber's uncalibrated models: a bias followed by
+// one weight per simulation feature. Larger code is predicted to raise ε
+// and larger test suites 𝓘.
+var heuristicWeights = map[string][]float64{
+	"ε": {0.005, 0.01, 0, 0, 0, 0},
+	"𝓘": {0.002, 0, 0.03, 0, 0, 0},
+}
+
+// calibrationRidge is how strongly the fitted weights are pulled towards
+// the heuristic ones; it weighs the heuristic like about one observed merge.
+const calibrationRidge = 1.0
+
+// defaultCalibrationWindow is how long after a merge its actual effect is
+// measured, unless SIE_CALIBRATION_WINDOW says otherwise.
+const defaultCalibrationWindow = 10 * time.Minute
+
+// FeatureValue is one simulation feature of a proposal.
+type FeatureValue struct {
+	Name  string
+	Value float64
+}
+
+// FeatureContribution is how much one feature adds to a prediction.
+type FeatureContribution struct {
+	Feature      string
+	Weight       float64
+	Contribution float64 // Weight × the feature's value
+}
+
+// GainPrediction is the predicted gain of one axiom with how it came about.
+type GainPrediction struct {
+	Axiom         string
+	Heuristic     float64 // Prediction of the uncalibrated heuristic
+	Predicted     float64 // Prediction of the calibrated model
+	Contributions []FeatureContribution
+	// Mean absolute errors over the observed merges: the calibrated model's
+	// is leave-one-out, so each merge is predicted without itself.
+	CalibrationError float64
+	HeuristicError   float64
+}
+
+// SimulationReport is the chamber's account of a proposal's predictions.
+type SimulationReport struct {
+	Features []FeatureValue
+	Epsilon  GainPrediction
+	I        GainPrediction
+	Samples  int           // Observed merges the calibration was fitted on
+	Window   time.Duration // How long after a merge outcomes are measured
+}
+
+// SimulationObservation is the state of the system at one moment.
+type SimulationObservation struct {
+	At         time.Time
+	Axiom      PrimeAxiom
+	Metabolism SystemMetabolism
+}
+
+// SimulationOutcome compares a merged proposal's predictions with what its
+// merge did once the calibration window had passed.
+type SimulationOutcome struct {
+	ProposalID           string
+	Features             []FeatureValue
+	HeuristicEpsilonGain float64
+	HeuristicIGain       float64
+	PredictedEpsilonGain float64
+	PredictedIGain       float64
+	Before               SimulationObservation
+	MergedAt             time.Time
+	DueAt                time.Time
+	After                *SimulationObservation // nil until observed
+	// Overlapping lists the merges that landed inside this one's window, so
+	// its observed change is not its own; the fit leaves such outcomes out.
+	Overlapping []string
+}
+
+// ActualGain is the observed change of axiom ("ε" or "𝓘").
+func (o SimulationOutcome) ActualGain(axiom string) float64 {
+	if o.After == nil {
+		return 0
+	}
+	if axiom == "ε" {
+		return o.After.Axiom.CompressionEfficiency - o.Before.Axiom.CompressionEfficiency
+	}
+	return o.After.Axiom.KnowledgeIntegrationScore - o.Before.Axiom.KnowledgeIntegrationScore
+}
+
+// SimulationChamber is the ultimate test of intelligence, modeling the future state.
+// It records what it predicts for each merged proposal, observes the actual
+// change once Window has passed and calibrates later predictions on that
+// history. The zero value predicts with the heuristics alone.
+type SimulationChamber struct {
+	Window      time.Duration
+	db          *sql.DB
+	goals       *GoalEngine
+	homeostasis *HomeostasisMonitor
+}
+
+// simulationMigrations is the versioned schema of the merge outcomes.
+// Version 1 adopts the table created before the schema was versioned.
+var simulationMigrations = []schemaMigration{
+	{Version: 1, Name: "merge outcomes", Statements: []string{
+		`CREATE TABLE IF NOT EXISTS simulation_outcomes (
+			proposal_id            TEXT PRIMARY KEY,
+			features               TEXT NOT NULL,
+			heuristic_epsilon_gain REAL NOT NULL,
+			heuristic_i_gain       REAL NOT NULL,
+			predicted_epsilon_gain REAL NOT NULL,
+			predicted_i_gain       REAL NOT NULL,
+			merged_at              TIMESTAMP NOT NULL,
+			due_at                 TIMESTAMP NOT NULL,
+			before_state           TEXT NOT NULL,
+			observed_at            TIMESTAMP,
+			after_state            TEXT NOT NULL DEFAULT ''
+		)`,
+	}},
+}
+
+// NewSimulationChamber migrates the outcome schema to the latest version.
+func NewSimulationChamber(db *sql.DB, goals *GoalEngine, homeostasis *HomeostasisMonitor) (*SimulationChamber, error) {
+	if err := applyMigrations(db, "simulation", simulationMigrations); err != nil {
+		return nil, fmt.Errorf("failed to migrate simulation outcomes: %v", err)
+	}
+	return &SimulationChamber{Window: defaultCalibrationWindow, db: db, goals: goals, homeostasis: homeostasis}, nil
+}
+
+// calibrationWindow returns the calibration window, overridable via
+// SIE_CALIBRATION_WINDOW (a Go duration such as 30m; 0 measures at merge).
+func calibrationWindow() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SIE_CALIBRATION_WINDOW")); err == nil && d >= 0 {
+		return d
+	}
+	return defaultCalibrationWindow
+}
+
+// RunProposalSimulation takes the raw code artifacts and projects their impact on the core axioms.
+// This is the Planner/Reasoner's function, filling the predictive fields of the Proposal.
+func (sc *SimulationChamber) RunProposalSimulation(p *Proposal) {
+	features := proposalFeatures(p)
+
+	// 1. Predicted Gains (Goal Engine Axioms), calibrated on observed merges
+	// when there are any.
+	var outcomes []SimulationOutcome
+	if sc.db != nil {
+		var err error
+		if outcomes, err = sc.ObservedOutcomes(); err != nil {
+			fmt.Printf("SIE-∞ Warning: predicting without calibration: %v\n", err)
+			outcomes = nil
+		}
+	}
+	report := &SimulationReport{
+		Features: features,
+		Epsilon:  predictGain("ε", features, outcomes),
+		I:        predictGain("𝓘", features, outcomes),
+		Samples:  len(outcomes),
+		Window:   sc.Window,
+	}
+	p.Simulation = report
+	p.PredictedEpsilonGain = report.Epsilon.Predicted
+	p.PredictedIGain = report.I.Predicted
+
+	// 2. Calculated Risk Score (Risk Assessment Module)
+	switch p.DependencyRiskMap.Verdict() {
+	case VerdictAllow:
+		// Low inherent risk for internal self-modification
+		p.CalculatedRiskScore = 0.05
+	case VerdictReview:
+		// Each package needing operator review adds risk
+		p.CalculatedRiskScore = math.Min(0.75, 0.05+0.15*float64(len(p.DependencyRiskMap.Flagged())))
+	default:
+		// Denied imports can never be merged
+		p.CalculatedRiskScore = 1.0
+	}
+}
+
+// proposalFeatures measures the simulation features of a proposal.
+func proposalFeatures(p *Proposal) []FeatureValue {
+	return []FeatureValue{
+		{"code KB", float64(len(p.NewFileContent)) / 1000},
+		{"test KB", float64(len(p.TestSuite)) / 1000},
+		{"other files", float64(len(p.Operations))},
+		{"flagged imports", float64(len(p.DependencyRiskMap.Flagged()))},
+		{"repair rounds", float64(p.RepairIterations)},
+	}
+}
+
+// featureVector lays features out as the model's inputs, bias first.
+// Features missing from older records count as zero.
+func featureVector(features []FeatureValue) []float64 {
+	x := make([]float64, len(simulationFeatures)+1)
+	x[0] = 1
+	for i, name := range simulationFeatures {
+		for _, f := range features {
+			if f.Name == name {
+				x[i+1] = f.Value
+			}
+		}
+	}
+	return x
+}
+
+// predictGain predicts the gain of axiom for features with weights fitted
+// on the observed outcomes, and measures how far off both the fitted model
+// and the heuristic have been.
+func predictGain(axiom string, features []FeatureValue, outcomes []SimulationOutcome) GainPrediction {
+	x := featureVector(features)
+	prior := heuristicWeights[axiom]
+	weights := fitCalibration(axiom, outcomes, prior)
+
+	gp := GainPrediction{Axiom: axiom, Heuristic: dot(prior, x), Predicted: dot(weights, x)}
+	gp.Contributions = append(gp.Contributions, FeatureContribution{"bias", weights[0], weights[0]})
+	for i, name := range simulationFeatures {
+		gp.Contributions = append(gp.Contributions, FeatureContribution{name, weights[i+1], weights[i+1] * x[i+1]})
+	}
+
+	for i, o := range outcomes {
+		rest := append(append([]SimulationOutcome(nil), outcomes[:i]...), outcomes[i+1:]...)
+		ox := featureVector(o.Features)
+		actual := o.ActualGain(axiom)
+		gp.CalibrationError += math.Abs(dot(fitCalibration(axiom, rest, prior), ox) - actual)
+		gp.HeuristicError += math.Abs(dot(prior, ox) - actual)
+	}
+	if n := float64(len(outcomes)); n > 0 {
+		gp.CalibrationError /= n
+		gp.HeuristicError /= n
+	}
+	return gp
+}
+
+// fitCalibration fits linear weights to the observed gains of axiom by ridge
+// regression towards prior, so that with few observations the heuristic
+// still dominates: (XᵀX + λI)·w = Xᵀy + λ·prior.
+func fitCalibration(axiom string, outcomes []SimulationOutcome, prior []float64) []float64 {
+	n := len(prior)
+	a := make([][]float64, n)
+	b := make([]float64, n)
+	for i := range a {
+		a[i] = make([]float64, n)
+		a[i][i] = calibrationRidge
+		b[i] = calibrationRidge * prior[i]
+	}
+	for _, o := range outcomes {
+		x := featureVector(o.Features)
+		y := o.ActualGain(axiom)
+		for i := range n {
+			for j := range n {
+				a[i][j] += x[i] * x[j]
+			}
+			b[i] += x[i] * y
+		}
+	}
+	return solveLinear(a, b)
+}
+
+// solveLinear solves a·x = b by Gaussian elimination with partial pivoting.
+// a is symmetric positive definite here, so it is never singular.
+func solveLinear(a [][]float64, b []float64) []float64 {
+	n := len(b)
+	for col := range n {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+		for row := col + 1; row < n; row++ {
+			f := a[row][col] / a[col][col]
+			for k := col; k < n; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+			b[row] -= f * b[col]
+		}
+	}
+	x := make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		sum := b[row]
+		for k := row + 1; k < n; k++ {
+			sum -= a[row][k] * x[k]
+		}
+		x[row] = sum / a[row][row]
+	}
+	return x
+}
+
+func dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// Snapshot re-measures the axioms and captures them with the metabolism as
+// they are now.
+func (sc *SimulationChamber) Snapshot() SimulationObservation {
+	obs := SimulationObservation{At: time.Now(), Axiom: sc.goals.CalculateCurrentMetrics()}
+	if sc.homeostasis != nil {
+		obs.Metabolism = sc.homeostasis.GetMetabolism()
+	}
+	return obs
+}
+
+// BeginObservation records a merged proposal's predictions with before, the
+// snapshot taken ahead of the merge. Its outcome is observed by ObserveDue
+// once the window has passed.
+func (sc *SimulationChamber) BeginObservation(p *Proposal, before SimulationObservation) error {
+	report := p.Simulation
+	if report == nil {
+		// Proposals from before calibration carry only their predictions.
+		report = &SimulationReport{
+			Features: proposalFeatures(p),
+			Epsilon:  GainPrediction{Heuristic: p.PredictedEpsilonGain},
+			I:        GainPrediction{Heuristic: p.PredictedIGain},
+		}
+	}
+	features, err := json.Marshal(report.Features)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	beforeJSON, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+	_, err = sc.db.Exec(`INSERT OR REPLACE INTO simulation_outcomes (proposal_id, features,
+			heuristic_epsilon_gain, heuristic_i_gain, predicted_epsilon_gain, predicted_i_gain,
+			merged_at, due_at, before_state)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.ID, string(features), report.Epsilon.Heuristic, report.I.Heuristic,
+		p.PredictedEpsilonGain, p.PredictedIGain, now.UTC(), now.Add(sc.Window).UTC(), string(beforeJSON))
+	if err != nil {
+		return fmt.Errorf("failed to record predictions for %s: %v", p.ID, err)
+	}
+	return nil
+}
+
+// ObserveDue measures the axioms and the metabolism for every merge whose
+// window has passed by now, and returns how many outcomes it completed.
+func (sc *SimulationChamber) ObserveDue(now time.Time) (int, error) {
+	rows, err := sc.db.Query(`SELECT proposal_id FROM simulation_outcomes
+		WHERE observed_at IS NULL AND due_at <= ? ORDER BY due_at`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	var due []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(due) == 0 {
+		return 0, err
+	}
+
+	after := sc.Snapshot()
+	afterJSON, err := json.Marshal(after)
+	if err != nil {
+		return 0, err
+	}
+	for i, id := range due {
+		if _, err := sc.db.Exec(`UPDATE simulation_outcomes SET observed_at = ?, after_state = ? WHERE proposal_id = ?`,
+			after.At.UTC(), string(afterJSON), id); err != nil {
+			return i, fmt.Errorf("failed to record the outcome of %s: %v", id, err)
+		}
+		sc.goals.RecordSample("calibration", id)
+	}
+	return len(due), nil
+}
+
+// Outcomes returns every recorded merge, oldest first, observed or not.
+func (sc *SimulationChamber) Outcomes() ([]SimulationOutcome, error) {
+	rows, err := sc.db.Query(`SELECT proposal_id, features, heuristic_epsilon_gain, heuristic_i_gain,
+		predicted_epsilon_gain, predicted_i_gain, merged_at, due_at, before_state, after_state
+		FROM simulation_outcomes ORDER BY merged_at, proposal_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []SimulationOutcome
+	for rows.Next() {
+		var o SimulationOutcome
+		var features, before, after string
+		if err := rows.Scan(&o.ProposalID, &features, &o.HeuristicEpsilonGain, &o.HeuristicIGain,
+			&o.PredictedEpsilonGain, &o.PredictedIGain, &o.MergedAt, &o.DueAt, &before, &after); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(features), &o.Features); err != nil {
+			return nil, fmt.Errorf("corrupt features for %s: %v", o.ProposalID, err)
+		}
+		if err := json.Unmarshal([]byte(before), &o.Before); err != nil {
+			return nil, fmt.Errorf("corrupt observation for %s: %v", o.ProposalID, err)
+		}
+		if after != "" {
+			o.After = &SimulationObservation{}
+			if err := json.Unmarshal([]byte(after), o.After); err != nil {
+				return nil, fmt.Errorf("corrupt observation for %s: %v", o.ProposalID, err)
+			}
+		}
+		out = append(out, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	markOverlaps(out)
+	return out, nil
+}
+
+// markOverlaps records, for every outcome, the other merges that landed
+// between its merge and its observation (or its due time while pending).
+func markOverlaps(outcomes []SimulationOutcome) {
+	for i := range outcomes {
+		o := &outcomes[i]
+		end := o.DueAt
+		if o.After != nil {
+			end = o.After.At
+		}
+		for _, other := range outcomes {
+			if other.ProposalID != o.ProposalID && other.MergedAt.After(o.MergedAt) && !other.MergedAt.After(end) {
+				o.Overlapping = append(o.Overlapping, other.ProposalID)
+			}
+		}
+	}
+}
+
+// ObservedOutcomes returns the outcomes whose window has been observed and
+// held no other merge: those are what the calibration is fitted on.
+func (sc *SimulationChamber) ObservedOutcomes() ([]SimulationOutcome, error) {
+	all, err := sc.Outcomes()
+	if err != nil {
+		return nil, err
+	}
+	var observed []SimulationOutcome
+	for _, o := range all {
+		if o.After != nil && len(o.Overlapping) == 0 {
+			observed = append(observed, o)
+		}
+	}
+	return observed, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"database/sql"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSolveLinear(t *testing.T) {
+	tests := []struct {
+		name string
+		a    [][]float64
+		b    []float64
+		want []float64
+	}{
+		{name: "identity", a: [][]float64{{1, 0}, {0, 1}}, b: []float64{3, -2}, want: []float64{3, -2}},
+		{name: "symmetric", a: [][]float64{{4, 1}, {1, 3}}, b: []float64{1, 2}, want: []float64{1.0 / 11, 7.0 / 11}},
+		{name: "needs a pivot", a: [][]float64{{0, 2}, {3, 1}}, b: []float64{4, 5}, want: []float64{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := solveLinear(tt.a, tt.b)
+			for i := range tt.want {
+				if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+					t.Fatalf("solveLinear() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// linearOutcome is an observed merge whose ε gain is exactly 0.01 + 0.02
+// per code KB.
+func linearOutcome(codeKB float64) SimulationOutcome {
+	return SimulationOutcome{
+		Features: []FeatureValue{{"code KB", codeKB}},
+		After:    &SimulationObservation{Axiom: PrimeAxiom{CompressionEfficiency: 0.01 + 0.02*codeKB}},
+	}
+}
+
+func TestFitCalibration(t *testing.T) {
+	prior := heuristicWeights["ε"]
+	if got := fitCalibration("ε", nil, prior); !approxEqual(got, prior) {
+		t.Errorf("fitCalibration() without outcomes = %v, want the prior %v", got, prior)
+	}
+
+	var outcomes []SimulationOutcome
+	for i := 0; i < 200; i++ {
+		outcomes = append(outcomes, linearOutcome(float64(i%10)))
+	}
+	got := fitCalibration("ε", outcomes, prior)
+	if math.Abs(got[0]-0.01) > 1e-3 || math.Abs(got[1]-0.02) > 1e-3 {
+		t.Errorf("fitCalibration() = %v, want bias 0.01 and code KB weight 0.02", got)
+	}
+}
+
+func approxEqual(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-12 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMarkOverlaps(t *testing.T) {
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+	outcomes := []SimulationOutcome{
+		{ProposalID: "a", MergedAt: at(0), DueAt: at(10), After: &SimulationObservation{At: at(15)}},
+		{ProposalID: "b", MergedAt: at(12), DueAt: at(22), After: &SimulationObservation{At: at(22)}},
+		{ProposalID: "c", MergedAt: at(30), DueAt: at(40)},
+		{ProposalID: "d", MergedAt: at(35), DueAt: at(45)},
+	}
+	markOverlaps(outcomes)
+	want := map[string][]string{"a": {"b"}, "b": nil, "c": {"d"}, "d": nil}
+	for _, o := range outcomes {
+		if !reflect.DeepEqual(o.Overlapping, want[o.ProposalID]) {
+			t.Errorf("%s overlaps %v, want %v", o.ProposalID, o.Overlapping, want[o.ProposalID])
+		}
+	}
+}
+
+func TestNewSimulationChamberAdoptsUnversionedTable(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(simulationMigrations[0].Statements[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO simulation_outcomes (proposal_id, features, heuristic_epsilon_gain,
+		heuristic_i_gain, predicted_epsilon_gain, predicted_i_gain, merged_at, due_at, before_state)
+		VALUES ('old', '[]', 0, 0, 0, 0, ?, ?, '{}')`, time.Now().UTC(), time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+
+	sc, err := NewSimulationChamber(db, &GoalEngine{}, nil)
+	if err != nil {
+		t.Fatalf("NewSimulationChamber() = %v", err)
+	}
+	outcomes, err := sc.Outcomes()
+	if err != nil || len(outcomes) != 1 || outcomes[0].ProposalID != "old" {
+		t.Fatalf("Outcomes() = %v, %v; want the existing outcome kept", outcomes, err)
+	}
+	var version int
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations WHERE component = 'simulation'`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != len(simulationMigrations) {
+		t.Errorf("simulation schema at version %d, want %d", version, len(simulationMigrations))
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+)
+
+// Messages are hidden in the least significant bit of the red, green and blue
+// channels of each pixel, in row-major order, preceded by a 32-bit big-endian
+// length header.
+const stegoHeaderBits = 32
+
+// EmbedMessage hides message inside a PNG image and returns the new PNG.
+func EmbedMessage(pngData []byte, message string) ([]byte, error) {
+	src, err := png.Decode(bytes.NewReader(pngData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode PNG: %v", err)
+	}
+	img := image.NewNRGBA(src.Bounds())
+	draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	payload := make([]byte, 4+len(message))
+	binary.BigEndian.PutUint32(payload, uint32(len(message)))
+	copy(payload[4:], message)
+
+	capacity := stegoCapacityBits(img.Bounds())
+	if len(payload)*8 > capacity {
+		return nil, fmt.Errorf("message needs %d bits but image can hold only %d", len(payload)*8, capacity)
+	}
+
+	bit := 0
+	forEachChannel(img, func(c *uint8) bool {
+		if bit >= len(payload)*8 {
+			return false
+		}
+		b := (payload[bit/8] >> (7 - bit%8)) & 1
+		*c = (*c &^ 1) | b
+		bit++
+		return true
+	})
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, img); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %v", err)
+	}
+	return out.Bytes(), nil
+}
+
+// ExtractMessage recovers a message hidden by EmbedMessage.
+func ExtractMessage(pngData []byte) (string, error) {
+	src, err := png.Decode(bytes.NewReader(pngData))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode PNG: %v", err)
+	}
+	img := image.NewNRGBA(src.Bounds())
+	draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	capacity := stegoCapacityBits(img.Bounds())
+	if capacity < stegoHeaderBits {
+		return "", fmt.Errorf("image is too small to carry a message")
+	}
+
+	var bits []byte
+	var length int
+	want := stegoHeaderBits
+	forEachChannel(img, func(c *uint8) bool {
+		bits = append(bits, *c&1)
+		if len(bits) == stegoHeaderBits {
+			length = int(binary.BigEndian.Uint32(packBits(bits)))
+			want = stegoHeaderBits + length*8
+		}
+		return len(bits) < want && len(bits) < capacity
+	})
+	if want > capacity {
+		return "", fmt.Errorf("image does not contain a hidden message")
+	}
+	return string(packBits(bits[stegoHeaderBits:want])), nil
+}
+
+func stegoCapacityBits(r image.Rectangle) int {
+	return r.Dx() * r.Dy() * 3
+}
+
+// forEachChannel visits the R, G and B bytes of every pixel until fn returns false.
+func forEachChannel(img *image.NRGBA, fn func(c *uint8) bool) {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			for ch := 0; ch < 3; ch++ {
+				if !fn(&img.Pix[i+ch]) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func packBits(bits []byte) []byte {
+	out := make([]byte, (len(bits)+7)/8)
+	for i, b := range bits {
+		out[i/8] |= b << (7 - i%8)
+	}
+	return out
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TaskStatus is the lifecycle stage of an asynchronous task. The values are
+// the ones ui/script.js polls for.
+type TaskStatus string
+
+const (
+	TaskQueued    TaskStatus = "queued"
+	TaskRunning   TaskStatus = "running"
+	TaskCompleted TaskStatus = "completed"
+	TaskFailed    TaskStatus = "failed"
+)
+
+// Task is one unit of asynchronous work, serialised as the /task/{id} response.
+type Task struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	Status     TaskStatus `json:"status"`
+	Result     any        `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// TaskFunc performs the work of a task and returns its JSON-serialisable result.
+type TaskFunc func(ctx context.Context) (any, error)
+
+// ErrTaskQueueFull is returned by Submit when every worker is busy and the
+// queue has no room left.
+var ErrTaskQueueFull = errors.New("task queue is full")
+
+type queuedTask struct {
+	id  string
+	run TaskFunc
+}
+
+// TaskManager runs tasks on a bounded pool of workers and forgets finished
+// tasks once they are older than the expiry window.
+type TaskManager struct {
+	tasks   map[string]*Task
+	mutex   sync.RWMutex
+	queue   chan queuedTask
+	expiry  time.Duration
+	timeout time.Duration
+	wg      sync.WaitGroup
+	cancel  context.CancelFunc
+}
+
+// NewTaskManager starts workers goroutines that share a queue of queueSize
+// pending tasks. Each task may run for at most timeout, and finished tasks are
+// dropped after expiry.
+func NewTaskManager(workers, queueSize int, timeout, expiry time.Duration) *TaskManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	tm := &TaskManager{
+		tasks:   make(map[string]*Task),
+		queue:   make(chan queuedTask, queueSize),
+		expiry:  expiry,
+		timeout: timeout,
+		cancel:  cancel,
+	}
+	for i := 0; i < workers; i++ {
+		tm.wg.Add(1)
+		go tm.worker(ctx)
+	}
+	tm.wg.Add(1)
+	go tm.janitor(ctx)
+	return tm
+}
+
+// Submit queues fn and returns the new task's ID.
+func (tm *TaskManager) Submit(kind string, fn TaskFunc) (string, error) {
+	task := &Task{
+		ID:        uuid.New().String(),
+		Kind:      kind,
+		Status:    TaskQueued,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	tm.mutex.Lock()
+	tm.tasks[task.ID] = task
+	tm.mutex.Unlock()
+
+	select {
+	case tm.queue <- queuedTask{id: task.ID, run: fn}:
+		return task.ID, nil
+	default:
+		tm.mutex.Lock()
+		delete(tm.tasks, task.ID)
+		tm.mutex.Unlock()
+		return "", ErrTaskQueueFull
+	}
+}
+
+// Get returns a snapshot of a task.
+func (tm *TaskManager) Get(id string) (Task, bool) {
+	tm.mutex.RLock()
+	defer tm.mutex.RUnlock()
+	task, ok := tm.tasks[id]
+	if !ok {
+		return Task{}, false
+	}
+	return *task, true
+}
+
+// Shutdown stops accepting work, cancels running tasks and waits for the
+// workers to exit.
+func (tm *TaskManager) Shutdown() {
+	tm.cancel()
+	tm.wg.Wait()
+}
+
+func (tm *TaskManager) worker(ctx context.Context) {
+	defer tm.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case qt := <-tm.queue:
+			tm.run(ctx, qt)
+		}
+	}
+}
+
+func (tm *TaskManager) run(ctx context.Context, qt queuedTask) {
+	tm.setStatus(qt.id, func(t *Task) { t.Status = TaskRunning })
+
+	ctx, cancel := context.WithTimeout(ctx, tm.timeout)
+	defer cancel()
+
+	result, err := func() (result any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = errors.New("task panicked")
+			}
+		}()
+		return qt.run(ctx)
+	}()
+
+	now := time.Now().UTC()
+	tm.setStatus(qt.id, func(t *Task) {
+		t.FinishedAt = &now
+		if err != nil {
+			t.Status = TaskFailed
+			t.Error = err.Error()
+			return
+		}
+		t.Status = TaskCompleted
+		t.Result = result
+	})
+}
+
+func (tm *TaskManager) setStatus(id string, update func(t *Task)) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	if t, ok := tm.tasks[id]; ok {
+		update(t)
+	}
+}
+
+// janitor periodically drops finished tasks older than the expiry window.
+func (tm *TaskManager) janitor(ctx context.Context) {
+	defer tm.wg.Done()
+	ticker := time.NewTicker(tm.expiry / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cutoff := time.Now().Add(-tm.expiry)
+			tm.mutex.Lock()
+			for id, t := range tm.tasks {
+				if t.FinishedAt != nil && t.FinishedAt.Before(cutoff) {
+					delete(tm.tasks, id)
+				}
+			}
+			tm.mutex.Unlock()
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// maxLCSCells bounds the table diffLines builds; beyond it the changed
+// region is shown as a whole-block replacement.
+const maxLCSCells = 4 << 20
+
+// diffLine is one line of an edit script: ' ' kept, '-' removed, '+' added.
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// UnifiedDiff renders the change from before to after as a git-style unified
+// diff of path. An empty before or after shows a created or deleted file.
+func UnifiedDiff(path, before, after string) string {
+	if before == after {
+		return ""
+	}
+	script := diffLines(splitLines(before), splitLines(after))
+
+	// oldLine and newLine count the lines of each side before script[i].
+	oldLine := make([]int, len(script)+1)
+	newLine := make([]int, len(script)+1)
+	for i, l := range script {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if l.kind != '+' {
+			oldLine[i+1]++
+		}
+		if l.kind != '-' {
+			newLine[i+1]++
+		}
+	}
+
+	var b strings.Builder
+	oldName, newName := "a/"+path, "b/"+path
+	if before == "" {
+		oldName = "/dev/null"
+	}
+	if after == "" {
+		newName = "/dev/null"
+	}
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+
+	for i := 0; i < len(script); {
+		if script[i].kind == ' ' {
+			i++
+			continue
+		}
+		// Extend the hunk over changes separated by at most twice the context.
+		start := max(0, i-diffContext)
+		end := i
+		for j := i; j < len(script) && j <= end+2*diffContext; j++ {
+			if script[j].kind != ' ' {
+				end = j
+			}
+		}
+		stop := min(len(script), end+1+diffContext)
+
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n",
+			hunkRange(oldLine[start], oldLine[stop]-oldLine[start]),
+			hunkRange(newLine[start], newLine[stop]-newLine[start]))
+		for _, l := range script[start:stop] {
+			b.WriteByte(l.kind)
+			b.WriteString(l.text)
+			b.WriteByte('\n')
+		}
+		i = stop
+	}
+	return b.String()
+}
+
+// hunkRange formats one side of a hunk header; an empty side is numbered by
+// the line it follows.
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// diffLines computes a shortest edit script from a to b, after setting aside
+// their common prefix and suffix.
+func diffLines(a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var script []diffLine
+	for _, l := range a[:prefix] {
+		script = append(script, diffLine{' ', l})
+	}
+	script = append(script, lcsDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		script = append(script, diffLine{' ', l})
+	}
+	return script
+}
+
+// lcsDiff diffs a and b through their longest common subsequence.
+func lcsDiff(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	var script []diffLine
+	if (n+1)*(m+1) > maxLCSCells {
+		for _, l := range a {
+			script = append(script, diffLine{'-', l})
+		}
+		for _, l := range b {
+			script = append(script, diffLine{'+', l})
+		}
+		return script
+	}
+
+	// lcs[i*(m+1)+j] is the length of the LCS of a[i:] and b[j:].
+	lcs := make([]int32, (n+1)*(m+1))
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+			} else {
+				lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[i] == b[j]:
+			script = append(script, diffLine{' ', a[i]})
+			i, j = i+1, j+1
+		case j < m && (i == n || lcs[i*(m+1)+j+1] >= lcs[(i+1)*(m+1)+j]):
+			script = append(script, diffLine{'+', b[j]})
+			j++
+		default:
+			script = append(script, diffLine{'-', a[i]})
+			i++
+		}
+	}
+	return script
+}
+
+// changeDiff renders every file a change touches as one multi-file diff.
+func changeDiff(c *ProposalChange) string {
+	var b strings.Builder
+	for _, path := range c.Paths() {
+		b.WriteString(UnifiedDiff(path, c.Before[path], c.After[path]))
+	}
+	return b.String()
+}
+
+const (
+	ansiReset = "\033[0m"
+	ansiBold  = "\033[1m"
+	ansiRed   = "\033[31m"
+	ansiGreen = "\033[32m"
+	ansiCyan  = "\033[36m"
+)
+
+// colorizeDiff highlights a unified diff for a terminal: file headers bold,
+// hunk headers cyan, removed lines red and added lines green.
+func colorizeDiff(diff string) string {
+	lines := strings.SplitAfter(diff, "\n")
+	for i, line := range lines {
+		color := ""
+		switch {
+		case strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "):
+			color = ansiBold
+		case strings.HasPrefix(line, "@@"):
+			color = ansiCyan
+		case strings.HasPrefix(line, "-"):
+			color = ansiRed
+		case strings.HasPrefix(line, "+"):
+			color = ansiGreen
+		}
+		if color != "" {
+			body := strings.TrimSuffix(line, "\n")
+			lines[i] = color + body + ansiReset + line[len(body):]
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// colorOutput reports whether standard output is a terminal that should get
+// colour; the NO_COLOR convention turns it off.
+func colorOutput() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// proposalDiff renders a proposal's changes as they were proposed, without
+// a tree to apply them to: new files in full and the diffs of the others.
+func proposalDiff(p *Proposal) string {
+	var b strings.Builder
+	b.WriteString(UnifiedDiff(p.TargetFileName, "", p.NewFileContent))
+	b.WriteString(UnifiedDiff(testFileNameFor(p.TargetFileName), "", p.TestSuite))
+	for _, op := range p.Operations {
+		switch op.Kind {
+		case FileCreate:
+			b.WriteString(UnifiedDiff(op.Path, "", op.Content))
+		case FileModify:
+			fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n%s\n", op.Path, op.Path, strings.TrimRight(op.Diff, "\n"))
+		case FileDelete:
+			fmt.Fprintf(&b, "--- a/%s\n+++ /dev/null\n", op.Path)
+			if diff := strings.TrimRight(op.Diff, "\n"); diff != "" {
+				b.WriteString(diff + "\n")
+			}
+		}
+	}
+	return b.String()
+}
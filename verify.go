@@ -1,39 +1,400 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// Verify performs the automated test suite execution and dependency risk assessment.
-func Verify(testSuiteCode, functionalCode, newFileName string) (bool, error) {
-	// In a real system, this function would be far more complex.
-	// It would save the code to temporary files, run the Go test runner,
-	// and perform a static analysis of the new dependencies.
-
-	// 1. Simulate running the test suite.
-	// For this simulation, we'll assume the tests pass if the code is generated.
-	fmt.Println("Verification: Running generated test suite...")
-	// A real implementation would look something like:
-	// cmd := exec.Command("go", "test", newTestFileName)
-	// err := cmd.Run()
-	// if err != nil { return false, fmt.Errorf("test suite failed: %v", err) }
-
-	// 2. Simulate Dependency Risk Assessment.
+// TestResult is the outcome of a single generated test.
+type TestResult struct {
+	Package string
+	Name    string
+	Passed  bool
+	Skipped bool
+	Elapsed time.Duration
+	Output  string
+}
+
+// CompilerError is a positioned diagnostic from go build, go vet or go test.
+type CompilerError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (ce CompilerError) String() string {
+	if ce.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", ce.File, ce.Line, ce.Column, ce.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", ce.File, ce.Line, ce.Message)
+}
+
+// VerificationStep records one command run during verification.
+type VerificationStep struct {
+	Name     string // build, vet or test
+	Command  string
+	Passed   bool
+	TimedOut bool
+	Stdout   string
+	Stderr   string
+	Duration time.Duration
+}
+
+// VerificationReport is the structured result of Verify.
+type VerificationReport struct {
+	ProposalID     string
+	Passed         bool
+	FailureReason  string
+	Steps          []VerificationStep
+	Tests          []TestResult
+	CompilerErrors []CompilerError
+	Duration       time.Duration
+	// Sandboxed is false when the operator let the steps run without the
+	// isolation tools listed in MissingIsolation.
+	Sandboxed        bool
+	MissingIsolation []string
+	// BlockedBy lists the blocking avoidance rules a proposal still matched
+	// after its repairs; approving it overrides them.
+	BlockedBy []string
+}
+
+// FailedTests returns the tests that did not pass.
+func (r *VerificationReport) FailedTests() []TestResult {
+	var failed []TestResult
+	for _, t := range r.Tests {
+		if !t.Passed && !t.Skipped {
+			failed = append(failed, t)
+		}
+	}
+	return failed
+}
+
+// Summary renders the report for the operator console.
+func (r *VerificationReport) Summary() string {
+	var b strings.Builder
+	status := "PASSED"
+	if !r.Passed {
+		status = "FAILED: " + r.FailureReason
+	}
+	fmt.Fprintf(&b, "Verification %s (%.2fs)\n", status, r.Duration.Seconds())
+	if len(r.MissingIsolation) > 0 {
+		fmt.Fprintf(&b, "  WARNING: ran unsandboxed, without %s\n", strings.Join(r.MissingIsolation, ", "))
+	}
+	for _, rule := range r.BlockedBy {
+		fmt.Fprintf(&b, "  WARNING: matches blocking rule: %s\n", rule)
+	}
+	for _, s := range r.Steps {
+		mark := "ok"
+		if s.TimedOut {
+			mark = "TIMEOUT"
+		} else if !s.Passed {
+			mark = "FAIL"
+		}
+		fmt.Fprintf(&b, "  %-5s %-7s %.2fs  %s\n", s.Name, mark, s.Duration.Seconds(), s.Command)
+	}
+	for _, ce := range r.CompilerErrors {
+		fmt.Fprintf(&b, "  error: %s\n", ce)
+	}
+	for _, t := range r.Tests {
+		mark := "PASS"
+		if t.Skipped {
+			mark = "SKIP"
+		} else if !t.Passed {
+			mark = "FAIL"
+		}
+		fmt.Fprintf(&b, "  %s %s (%.2fs)\n", mark, t.Name, t.Elapsed.Seconds())
+	}
+	return b.String()
+}
+
+// maxFeedbackOutput bounds how much of each failing step or test is quoted
+// back to the model.
+const maxFeedbackOutput = 2000
+
+// Feedback renders the failure for a repair prompt: the reason, every
+// compiler error and the output of each failing test. Timings and sandbox
+// paths are scrubbed so the same failure always yields the same prompt,
+// which keeps repair rounds replayable from a cassette.
+func (r *VerificationReport) Feedback() string {
+	if r.Passed {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "- %s\n", r.FailureReason)
+	for _, ce := range r.CompilerErrors {
+		fmt.Fprintf(&b, "- %s\n", ce)
+	}
+	for _, t := range r.FailedTests() {
+		fmt.Fprintf(&b, "- test %s failed:\n%s\n", t.Name, truncateOutput(t.Output))
+	}
+	if len(r.CompilerErrors) == 0 && len(r.FailedTests()) == 0 {
+		// Nothing structured to quote, so show the failing step's raw output.
+		for _, step := range r.Steps {
+			if !step.Passed {
+				fmt.Fprintf(&b, "- go %s output:\n%s\n", step.Name, truncateOutput(step.Stderr+step.Stdout))
+			}
+		}
+	}
+	return scrubVolatile(strings.TrimSpace(b.String()))
+}
+
+func truncateOutput(s string) string {
+	s = strings.TrimSpace(scrubVolatile(s))
+	if len(s) > maxFeedbackOutput {
+		return s[:maxFeedbackOutput] + "\n[... truncated]"
+	}
+	return s
+}
+
+var (
+	// sandboxPathPattern matches the per-run verification directory and the
+	// go tool's own build directories, up to and including the separator.
+	sandboxPathPattern = regexp.MustCompile(`(?:[A-Za-z]:)?[^\s:"'(]*[/\\](?:sie-verify-|go-build)\d+(?:[/\\]b\d+)?[/\\]?`)
+	// testDurationPattern matches "(0.01s)" after a test name and the
+	// trailing elapsed time of an "ok" or "FAIL" package line.
+	testDurationPattern = regexp.MustCompile(`(?m) \([\d.hmµn]+s\)|\t[\d.]+s$`)
+)
+
+// scrubVolatile removes what differs between two runs of the same failing
+// check: sandbox paths and timings.
+func scrubVolatile(s string) string {
+	s = sandboxPathPattern.ReplaceAllString(s, "")
+	return testDurationPattern.ReplaceAllString(s, "")
+}
+
+// Verify materialises the proposal into a throwaway copy of the module and
+// runs go build, go vet and go test against it inside the sandbox. The
+// returned error is reserved for infrastructure failures; a proposal that
+// does not compile or whose tests fail yields a report with Passed == false.
+func Verify(ctx context.Context, p *Proposal, policy *DependencyPolicy, cfg SandboxConfig) (*VerificationReport, error) {
+	change, err := proposalChange(cfg.ModuleDir, p)
+	if err != nil {
+		return &VerificationReport{ProposalID: p.ID, FailureReason: err.Error()}, nil
+	}
+	return verifyChange(ctx, p, change, policy, cfg)
+}
+
+// verifyChange verifies a change already worked out against some tree. The
+// merger uses it to verify a branch it has applied the change to, where
+// recomputing the change would conflict with itself.
+func verifyChange(ctx context.Context, p *Proposal, change *ProposalChange, policy *DependencyPolicy, cfg SandboxConfig) (*VerificationReport, error) {
+	// Generated code never runs without isolation unless the operator
+	// opted out of it.
+	missing := missingIsolation(cfg)
+	if len(missing) > 0 && !cfg.AllowUnsandboxed {
+		return nil, fmt.Errorf("sandbox isolation unavailable: %s (set SIE_ALLOW_UNSANDBOXED=1 to verify without it)", strings.Join(missing, ", "))
+	}
+
+	start := time.Now()
+	report := &VerificationReport{ProposalID: p.ID, Sandboxed: len(missing) == 0, MissingIsolation: missing}
+	defer func() { report.Duration = time.Since(start) }()
+
+	// 1. Dependency Risk Assessment, which is cheap and needs no sandbox.
+	// The map is recomputed here so it always reflects the code being merged.
 	fmt.Println("Verification: Performing Dependency Risk Assessment...")
-	// A real implementation would parse the imports from `functionalCode`,
-	// check them against a database of known-vulnerable packages, and
-	// analyze their complexity and provenance.
-	if ContainsRiskyDependency(functionalCode) {
-		return false, fmt.Errorf("dependency risk assessment failed: risky import detected")
+	// The change's own record of go.mod comes first: when verifying a branch
+	// the module directory already holds the changed one.
+	var goMod []byte
+	if before, ok := change.Before["go.mod"]; ok {
+		goMod = []byte(before)
+	} else {
+		var err error
+		if goMod, err = os.ReadFile(filepath.Join(cfg.ModuleDir, "go.mod")); err != nil {
+			return nil, fmt.Errorf("failed to read go.mod: %v", err)
+		}
+	}
+	riskMap := policy.EvaluateChange(change.Before, change.After, goMod)
+	riskMap.Claim = p.DependencyRiskMap.Claim
+	p.DependencyRiskMap = riskMap
+	if riskMap.Verdict() == VerdictDeny {
+		report.FailureReason = "dependency risk assessment failed: " + deniedSummary(riskMap)
+		return report, nil
+	}
+
+	// 2. Materialise the proposal into a copy of the module.
+	workDir, err := os.MkdirTemp("", "sie-verify-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sandbox directory: %v", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	if err := copyModule(cfg.ModuleDir, workDir); err != nil {
+		return nil, fmt.Errorf("failed to copy module into sandbox: %v", err)
+	}
+	for _, name := range change.Paths() {
+		path := filepath.Join(workDir, filepath.FromSlash(name))
+		if change.After[name] == "" {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("failed to delete %s: %v", name, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(change.After[name]), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %v", name, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	// 3. go build, go vet and go test, stopping at the first failing step.
+	steps := []struct {
+		name string
+		args []string
+	}{
+		{"build", []string{"build", "./..."}},
+		{"vet", []string{"vet", "./..."}},
+		{"test", []string{"test", "-json", "-count=1", "-timeout", cfg.Timeout.String(), "./..."}},
+	}
+	for _, s := range steps {
+		fmt.Printf("Verification: Running go %s...\n", s.name)
+		run, err := runSandboxed(ctx, cfg, workDir, "go", s.args...)
+		if err != nil {
+			return nil, err
+		}
+		step := VerificationStep{
+			Name:     s.name,
+			Command:  run.Command,
+			Passed:   run.ExitCode == 0 && !run.TimedOut,
+			TimedOut: run.TimedOut,
+			Stdout:   run.Stdout,
+			Stderr:   run.Stderr,
+			Duration: run.Duration,
+		}
+		report.Steps = append(report.Steps, step)
+
+		output := run.Stderr
+		if s.name == "test" {
+			var buildOutput string
+			report.Tests, buildOutput = parseTestEvents(run.Stdout)
+			output = buildOutput + run.Stderr
+		}
+		report.CompilerErrors = append(report.CompilerErrors, parseCompilerErrors(output)...)
+
+		if !step.Passed {
+			switch {
+			case step.TimedOut:
+				report.FailureReason = fmt.Sprintf("go %s timed out after %s", s.name, cfg.Timeout)
+			case s.name == "test" && len(report.FailedTests()) > 0:
+				report.FailureReason = fmt.Sprintf("%d test(s) failed", len(report.FailedTests()))
+			default:
+				report.FailureReason = fmt.Sprintf("go %s failed", s.name)
+			}
+			fmt.Printf("Verification: %s\n", report.FailureReason)
+			return report, nil
+		}
 	}
 
+	report.Passed = true
 	fmt.Println("Verification: All checks passed.")
-	return true, nil
+	return report, nil
 }
 
-// ContainsRiskyDependency is a placeholder for a real dependency scanner.
-func ContainsRiskyDependency(code string) bool {
-	// This is the final, correct implementation. It searches for the literal string "os/exec".
-	return strings.Contains(code, "\"os/exec\"")
+// proposalFileNames derives the implementation and test file names for a
+// proposal, refusing anything that would escape the module root.
+func proposalFileNames(target string) (string, string, error) {
+	name := strings.TrimSpace(target)
+	if name == "" || filepath.Base(name) != name || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+		return "", "", fmt.Errorf("invalid target file name %q", target)
+	}
+	return name, testFileNameFor(name), nil
+}
+
+// testFileNameFor returns the _test.go companion of a Go file name.
+func testFileNameFor(name string) string {
+	return strings.TrimSuffix(name, ".go") + "_test.go"
+}
+
+// deniedSummary lists the denied imports of a risk map on one line.
+func deniedSummary(m DependencyRiskMap) string {
+	var parts []string
+	for _, e := range m.ParseErrors {
+		parts = append(parts, "unparseable source ("+e+")")
+	}
+	for _, f := range m.Findings {
+		if f.Verdict == VerdictDeny {
+			parts = append(parts, fmt.Sprintf("%s in %s:%d (%s)", f.Path, f.File, f.Line, f.Reason))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// testEvent mirrors the records emitted by go test -json.
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// parseTestEvents folds go test -json output into per-test results. Output
+// not attributed to any test (such as build failures) is returned separately.
+func parseTestEvents(stream string) ([]TestResult, string) {
+	var results []TestResult
+	index := make(map[string]int)
+	var loose strings.Builder
+
+	scanner := bufio.NewScanner(strings.NewReader(stream))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var ev testEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			loose.WriteString(scanner.Text())
+			loose.WriteString("\n")
+			continue
+		}
+		if ev.Test == "" {
+			if ev.Action == "output" || ev.Action == "build-output" {
+				loose.WriteString(ev.Output)
+			}
+			continue
+		}
+
+		key := ev.Package + "." + ev.Test
+		i, ok := index[key]
+		if !ok {
+			i = len(results)
+			index[key] = i
+			results = append(results, TestResult{Package: ev.Package, Name: ev.Test})
+		}
+		switch ev.Action {
+		case "output":
+			results[i].Output += ev.Output
+		case "pass":
+			results[i].Passed = true
+			results[i].Elapsed = time.Duration(ev.Elapsed * float64(time.Second))
+		case "skip":
+			results[i].Skipped = true
+			results[i].Elapsed = time.Duration(ev.Elapsed * float64(time.Second))
+		case "fail":
+			results[i].Elapsed = time.Duration(ev.Elapsed * float64(time.Second))
+		}
+	}
+	return results, loose.String()
+}
+
+var compilerErrorPattern = regexp.MustCompile(`(?m)^(?:vet: )?\.?/?([^\s:]+\.go):(\d+)(?::(\d+))?: (.+)$`)
+
+// parseCompilerErrors extracts file:line:col diagnostics from tool output.
+func parseCompilerErrors(output string) []CompilerError {
+	var errs []CompilerError
+	for _, m := range compilerErrorPattern.FindAllStringSubmatch(output, -1) {
+		line, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3])
+		errs = append(errs, CompilerError{File: m[1], Line: line, Column: col, Message: m[4]})
+	}
+	return errs
 }